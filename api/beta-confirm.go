@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/confirm"
+	"goalhero-emailer/pkg/i18n"
+	"goalhero-emailer/pkg/pages"
+	"goalhero-emailer/pkg/registration"
+)
+
+// BetaConfirmHandler is the target of the double opt-in link. GET shows a
+// confirmation button so link scanners can't confirm anyone; POST
+// activates the pending registration named by the signed token and queues
+// the welcome email.
+func BetaConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		locale := negotiateLocale(r.Header.Get("Accept-Language"))
+		l := i18n.New(locale)
+		pages.Render(w, http.StatusInternalServerError, pages.Page{
+			Locale:  locale,
+			Title:   l.T("confirm.title"),
+			Heading: l.T("confirm.title"),
+			Text:    l.T("confirm.error_text"),
+		})
+		return
+	}
+	handleBetaConfirm(w, r, a)
+}
+
+// handleBetaConfirm serves a confirmation with a, which tests build
+// themselves.
+func handleBetaConfirm(w http.ResponseWriter, r *http.Request, a *app.App) {
+	locale := negotiateLocale(r.Header.Get("Accept-Language"))
+	l := i18n.New(locale)
+	page := pages.Page{Locale: locale, Title: l.T("confirm.title")}
+
+	render := func(status int, name string) {
+		page.Heading = l.T("confirm." + name + "_heading")
+		page.Text = l.T("confirm." + name + "_text")
+		if name == "error" {
+			page.Heading = l.T("confirm.title")
+		}
+		pages.Render(w, status, page)
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" && r.Method != "POST" {
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		render(http.StatusMethodNotAllowed, "invalid")
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if a.Signer == nil || token == "" {
+		render(http.StatusBadRequest, "invalid")
+		return
+	}
+
+	now := time.Now().UTC()
+	key, err := confirm.Verify(a.Signer, token, now)
+	if errors.Is(err, confirm.ErrExpired) {
+		render(http.StatusGone, "expired")
+		return
+	}
+	if err != nil {
+		render(http.StatusBadRequest, "invalid")
+		return
+	}
+
+	reg, err := a.Registrations.Get(r.Context(), key)
+	switch {
+	case errors.Is(err, registration.ErrNotFound):
+		// Expired registrations are purged, so a valid token for a
+		// missing registration means the window passed.
+		render(http.StatusGone, "expired")
+		return
+	case err != nil:
+		log.Printf("Error loading registration: %v", err)
+		render(http.StatusInternalServerError, "error")
+		return
+	case reg.State == registration.StateActive:
+		render(http.StatusOK, "already")
+		return
+	case reg.Expired(now):
+		render(http.StatusGone, "expired")
+		return
+	}
+
+	if r.Method != "POST" {
+		page.Heading = l.T("confirm.intro_heading")
+		page.Text = l.Format("confirm.prompt_text", "email", reg.Email)
+		page.Button = l.T("confirm.button")
+		page.Action = confirm.Path + "?token=" + url.QueryEscape(token)
+		pages.Render(w, http.StatusOK, page)
+		return
+	}
+
+	if err := a.Registrations.Confirm(r.Context(), key, now, registration.JobWelcome); err != nil {
+		log.Printf("Error confirming registration %s: %v", reg.ID, err)
+		render(http.StatusInternalServerError, "error")
+		return
+	}
+	a.Outbox.Dispatch(r.Context(), key)
+
+	render(http.StatusOK, "done")
+}
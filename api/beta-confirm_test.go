@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/confirm"
+	"goalhero-emailer/pkg/i18n"
+	"goalhero-emailer/pkg/registration"
+)
+
+// newConfirmApp builds an App with double opt-in and a pending signup for
+// jane@example.com, returning the link from her confirmation email.
+func newConfirmApp(t *testing.T) (*app.App, *fakeMailer, string) {
+	t.Helper()
+	a, fake := newTestApp(t,
+		"DOUBLE_OPT_IN=true",
+		"PUBLIC_BASE_URL=https://goalhero.eu",
+		"SIGNING_SECRET=0123456789abcdef0123456789abcdef",
+	)
+	postSignup(t, a, `{"email": "jane@example.com"}`)
+	reg, err := a.Registrations.Get(context.Background(), "jane@example.com")
+	if err != nil || reg.State != registration.StatePending {
+		t.Fatalf("signup: %+v, %v", reg, err)
+	}
+	return a, fake, confirm.Path + "?token=" + confirm.Token(a.Signer, reg.Key, *reg.ExpiresAt)
+}
+
+func confirmRequest(a *app.App, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handleBetaConfirm(w, httptest.NewRequest(method, target, nil), a)
+	return w
+}
+
+func TestBetaConfirm(t *testing.T) {
+	a, fake, target := newConfirmApp(t)
+	ctx := context.Background()
+
+	// Opening the link, as a scanner would, only shows the button.
+	w := confirmRequest(a, "GET", target)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<form method="post"`) {
+		t.Fatalf("GET: %d %s", w.Code, w.Body)
+	}
+	if reg, _ := a.Registrations.Get(ctx, "jane@example.com"); reg.State != registration.StatePending {
+		t.Fatalf("GET confirmed the registration")
+	}
+
+	w = confirmRequest(a, "POST", target)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST: %d %s", w.Code, w.Body)
+	}
+	reg, err := a.Registrations.Get(ctx, "jane@example.com")
+	if err != nil || reg.State != registration.StateActive {
+		t.Fatalf("after POST: %+v, %v", reg, err)
+	}
+
+	// The welcome email went out through the outbox, which is now empty.
+	if to := fake.recipients(); len(to) != 2 || to[1] != "jane@example.com" {
+		t.Fatalf("sent to %v, want the confirmation and welcome emails", to)
+	}
+	if reg.EmailStatus != registration.EmailSent {
+		t.Errorf("email status %q, want sent", reg.EmailStatus)
+	}
+	if jobs, _ := a.Registrations.Jobs(ctx); len(jobs) != 0 {
+		t.Errorf("outbox left %+v", jobs)
+	}
+
+	// Confirming again says so, and sends nothing more.
+	w = confirmRequest(a, "POST", target)
+	already := i18n.New(i18n.DefaultLocale).T("confirm.already_heading")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), already) {
+		t.Errorf("second POST: %d %s", w.Code, w.Body)
+	}
+	if to := fake.recipients(); len(to) != 2 {
+		t.Errorf("sent to %v after confirming again", to)
+	}
+}
+
+func TestBetaConfirmExpired(t *testing.T) {
+	t.Run("token", func(t *testing.T) {
+		a, _, _ := newConfirmApp(t)
+		token := confirm.Token(a.Signer, "jane@example.com", time.Now().Add(-time.Minute))
+		if w := confirmRequest(a, "POST", confirm.Path+"?token="+token); w.Code != http.StatusGone {
+			t.Errorf("status %d, want 410", w.Code)
+		}
+	})
+
+	t.Run("registration", func(t *testing.T) {
+		// The token is still good but the registration's window has passed.
+		a, _, target := newConfirmApp(t)
+		ctx := context.Background()
+		if err := a.Registrations.Renew(ctx, "jane@example.com", time.Now().UTC().Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if w := confirmRequest(a, "POST", target); w.Code != http.StatusGone {
+			t.Errorf("status %d, want 410", w.Code)
+		}
+		if reg, _ := a.Registrations.Get(ctx, "jane@example.com"); reg != nil && reg.State == registration.StateActive {
+			t.Errorf("expired registration confirmed")
+		}
+	})
+
+	t.Run("job", func(t *testing.T) {
+		// A confirmation claimed after the window would carry a dead link.
+		a, fake, _ := newConfirmApp(t)
+		ctx := context.Background()
+		if err := a.Registrations.Renew(ctx, "jane@example.com", time.Now().UTC().Add(-time.Minute), registration.JobConfirm); err != nil {
+			t.Fatal(err)
+		}
+		a.Outbox.Dispatch(ctx, "jane@example.com")
+		if to := fake.recipients(); len(to) != 1 {
+			t.Errorf("sent to %v, want only the first confirmation", to)
+		}
+		if jobs, _ := a.Registrations.Jobs(ctx); len(jobs) != 0 {
+			t.Errorf("Jobs = %+v, want the job dropped", jobs)
+		}
+	})
+
+	t.Run("purged", func(t *testing.T) {
+		a, _, _ := newConfirmApp(t)
+		token := confirm.Token(a.Signer, "john@example.com", time.Now().Add(time.Hour))
+		if w := confirmRequest(a, "POST", confirm.Path+"?token="+token); w.Code != http.StatusGone {
+			t.Errorf("status %d, want 410", w.Code)
+		}
+	})
+}
+
+func TestBetaConfirmInvalid(t *testing.T) {
+	a, _, target := newConfirmApp(t)
+	for _, tt := range []struct {
+		method, target string
+		status         int
+	}{
+		{"POST", confirm.Path, http.StatusBadRequest},
+		{"POST", confirm.Path + "?token=x", http.StatusBadRequest},
+		{"DELETE", target, http.StatusMethodNotAllowed},
+	} {
+		if w := confirmRequest(a, tt.method, tt.target); w.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, w.Code, tt.status)
+		}
+	}
+}
@@ -1,933 +1,441 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
-	"github.com/sendgrid/sendgrid-go"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"goalhero-emailer/pkg/apierror"
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/cors"
+	"goalhero-emailer/pkg/emailaddr"
+	"goalhero-emailer/pkg/formtoken"
+	"goalhero-emailer/pkg/i18n"
+	"goalhero-emailer/pkg/idempotency"
+	"goalhero-emailer/pkg/ratelimit"
+	"goalhero-emailer/pkg/registration"
+	"goalhero-emailer/pkg/reqbody"
 )
 
 type BetaRegisterRequest struct {
 	Email    string `json:"email"`
 	Language string `json:"language"`
+	// Source identifies where the signup happened, e.g. "landing-hero".
+	Source string `json:"source"`
+
+	// Website is a honeypot: forms hide it from people, so only bots
+	// fill it in.
+	Website string `json:"website"`
+	// FormToken is the signed render time from GET /api/form-token.
+	FormToken string `json:"form_token"`
+	// CaptchaToken is the response token of the CAPTCHA widget.
+	CaptchaToken string `json:"captcha_token"`
 }
 
+const (
+	defaultSource   = "web"
+	maxSourceLength = 64
+)
+
+// Registration outcomes reported in BetaRegisterResponse.Status.
+const (
+	StatusRegistered        = "registered"
+	StatusAlreadyRegistered = "already_registered"
+	StatusConfirmationSent  = "confirmation_sent"
+)
+
 type BetaRegisterResponse struct {
 	Success bool   `json:"success"`
+	Status  string `json:"status,omitempty"`
 	Message string `json:"message"`
+	// Suggestion is the address the user may have meant when the domain
+	// looks like a typo, e.g. jane@gmail.com for jane@gmial.com. The
+	// address as sent was still registered.
+	Suggestion string `json:"suggestion,omitempty"`
+	// Error details the failure when Success is false.
+	Error *apierror.Error `json:"error,omitempty"`
+}
+
+const maxIdempotencyKeyLength = 255
+
+var emailErrorCodes = map[emailaddr.Reason]apierror.Code{
+	emailaddr.ReasonSyntax:     apierror.CodeEmailInvalid,
+	emailaddr.ReasonTooLong:    apierror.CodeEmailTooLong,
+	emailaddr.ReasonDisposable: apierror.CodeEmailDisposable,
+	emailaddr.ReasonNoMX:       apierror.CodeEmailUndeliverable,
+}
+
+// captchaFormFields are the fields CAPTCHA widgets add to plain HTML
+// forms, all read as captcha_token.
+var captchaFormFields = map[string]string{
+	"h-captcha-response":    "captcha_token",
+	"cf-turnstile-response": "captcha_token",
+	"g-recaptcha-response":  "captcha_token",
+}
+
+var registerCORS = cors.Options{
+	Methods: []string{"POST"},
+	Headers: []string{"Content-Type", "Idempotency-Key", apierror.RequestIDHeader},
+	Expose:  []string{apierror.RequestIDHeader, "Idempotent-Replayed", "Retry-After"},
+	Reject: func(w http.ResponseWriter, r *http.Request) {
+		l := i18n.New(negotiateLocale(r.Header.Get("Accept-Language")))
+		writeError(w, http.StatusForbidden, apierror.New(l, apierror.CodeOriginNotAllowed))
+	},
 }
 
 func Handler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(apierror.RequestIDHeader, apierror.RequestID(r))
+		l := i18n.New(negotiateLocale(r.Header.Get("Accept-Language")))
+		writeError(w, http.StatusInternalServerError, apierror.New(l, apierror.CodeInternal))
+		return
+	}
+	handleBetaRegister(w, r, a)
+}
+
+// handleBetaRegister serves a signup with a, which tests build themselves.
+func handleBetaRegister(w http.ResponseWriter, r *http.Request, a *app.App) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set(apierror.RequestIDHeader, apierror.RequestID(r))
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// Errors before the body is read are in the caller's preferred
+	// language; later ones in the language the signup resolved to.
+	l := i18n.New(negotiateLocale(r.Header.Get("Accept-Language")))
+
+	if !a.CORS.Handle(w, r, registerCORS) {
 		return
 	}
 
 	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(BetaRegisterResponse{
-			Success: false,
-			Message: "Method not allowed",
-		})
+		writeError(w, http.StatusMethodNotAllowed, apierror.New(l, apierror.CodeMethodNotAllowed))
 		return
 	}
 
 	var req BetaRegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(BetaRegisterResponse{
-			Success: false,
-			Message: "Invalid request body",
-		})
+	err := reqbody.Decode(w, r, &req, reqbody.Options{
+		MaxBytes:    a.Config.MaxBodyBytes,
+		Strict:      a.Config.StrictRequests,
+		AllowForm:   true,
+		FormAliases: captchaFormFields,
+	})
+	if err != nil {
+		var tooLarge *reqbody.TooLargeError
+		var unknown *reqbody.UnknownFieldError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, apierror.New(l, apierror.CodeBodyTooLarge))
+		case errors.Is(err, reqbody.ErrUnsupportedMediaType):
+			writeError(w, http.StatusUnsupportedMediaType, apierror.New(l, apierror.CodeUnsupportedMedia))
+		case errors.As(err, &unknown):
+			writeError(w, http.StatusBadRequest, apierror.New(l, apierror.CodeValidationFailed,
+				apierror.Field(l, unknown.Field, apierror.CodeUnknownField)))
+		default:
+			writeError(w, http.StatusBadRequest, apierror.New(l, apierror.CodeInvalidBody))
+		}
 		return
 	}
 
-	if req.Email == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(BetaRegisterResponse{
-			Success: false,
-			Message: "Email is required",
-		})
+	var fields []apierror.FieldError
+	locale, localeSource, err := resolveLocale(req.Language, r.Header.Get("Accept-Language"))
+	if err != nil {
+		fields = append(fields, apierror.Field(l, "language", apierror.CodeLanguageUnsupported,
+			"supported", strings.Join(i18n.Supported(), ", ")))
+	} else {
+		l = i18n.New(locale)
+	}
+
+	ip := ratelimit.ClientIP(r, a.Config.TrustProxy)
+	if wait := a.Throttle(r.Context(), "ip:"+ip, a.Config.RateLimits.IP); wait > 0 {
+		writeRateLimited(w, l, wait)
 		return
 	}
 
-	if req.Language == "" {
-		req.Language = "en"
+	// A retry of a signup that already succeeded is replayed before the bot
+	// checks, which a spent CAPTCHA token would fail.
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLength {
+		fields = append(fields, apierror.Field(l, "Idempotency-Key", apierror.CodeIdempotencyKey,
+			"max", maxIdempotencyKeyLength))
+	} else if key != "" {
+		fingerprint := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(req.Email)) +
+			"\x00" + locale + "\x00" + signupSource(req.Source)))
+		prev, err := a.Idempotency.Reserve(r.Context(), key, hex.EncodeToString(fingerprint[:]))
+		switch {
+		case prev != nil:
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(prev.Status)
+			w.Write(prev.Body)
+			return
+		case errors.Is(err, idempotency.ErrInFlight):
+			writeError(w, http.StatusConflict, apierror.New(l, apierror.CodeIdempotencyPending))
+			return
+		case errors.Is(err, idempotency.ErrMismatch):
+			writeError(w, http.StatusUnprocessableEntity, apierror.New(l, apierror.CodeIdempotencyReused))
+			return
+		case err != nil:
+			// Losing idempotency is better than losing the signup.
+			log.Printf("Error reserving idempotency key: %v", err)
+		default:
+			rec := &idempotency.Recorder{ResponseWriter: w}
+			w = rec
+			defer saveIdempotent(r, a, key, rec)
+		}
 	}
 
-	if req.Language != "en" && req.Language != "es" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(BetaRegisterResponse{
-			Success: false,
-			Message: "Language must be 'en' or 'es'",
-		})
+	reason, err := suspectBot(r, a, &req)
+	if errors.Is(err, errCaptchaExpired) {
+		writeError(w, http.StatusBadRequest, apierror.New(l, apierror.CodeValidationFailed,
+			apierror.Field(l, "captcha_token", apierror.CodeCaptchaExpired)))
+		return
+	}
+	if reason != "" {
+		// Bots get the answer a person would, so they have nothing to
+		// tune against.
+		log.Printf("Dropped suspected bot signup from %s: %s", ip, reason)
+		suggestion := emailaddr.Suggest(strings.TrimSpace(req.Email))
+		if a.Config.DoubleOptIn {
+			writeSuccess(w, l, StatusConfirmationSent, suggestion)
+		} else {
+			writeSuccess(w, l, StatusRegistered, suggestion)
+		}
 		return
 	}
 
-	if err := sendWelcomeEmail(req.Email, req.Language); err != nil {
-		log.Printf("Error sending email: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(BetaRegisterResponse{
-			Success: false,
-			Message: "Failed to send welcome email",
-		})
+	var email string
+	if strings.TrimSpace(req.Email) == "" {
+		fields = append(fields, apierror.Field(l, "email", apierror.CodeEmailRequired))
+	} else if email, err = a.Validator.Validate(r.Context(), req.Email); err != nil {
+		var invalid *emailaddr.InvalidError
+		if !errors.As(err, &invalid) {
+			log.Printf("Error validating email: %v", err)
+			invalid = &emailaddr.InvalidError{Reason: emailaddr.ReasonSyntax}
+		}
+		fields = append(fields, apierror.Field(l, "email", emailErrorCodes[invalid.Reason]))
+	}
+
+	if len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, apierror.New(l, apierror.CodeValidationFailed, fields...))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(BetaRegisterResponse{
-		Success: true,
-		Message: "Welcome email sent successfully!",
+	registerBeta(w, r, a, &registration.Registration{
+		Email:          email,
+		Key:            emailaddr.Normalize(email, a.Config.NormalizeGmail),
+		Language:       locale,
+		LanguageSource: string(localeSource),
+		Source:         signupSource(req.Source),
 	})
 }
 
-func sendWelcomeEmail(email, language string) error {
-	from := mail.NewEmail("GoalHero Team", "info@goalhero.eu")
-	to := mail.NewEmail("", email)
+// saveIdempotent stores a successful response for key so retries replay
+// it. Any other response releases the key so the retry runs again, with a
+// fresh CAPTCHA token or after the rate limit or the outage has passed.
+func saveIdempotent(r *http.Request, a *app.App, key string, rec *idempotency.Recorder) {
+	var err error
+	if rec.Status >= 200 && rec.Status < 300 {
+		err = a.Idempotency.Complete(r.Context(), key, rec.Status, rec.Body)
+	} else {
+		err = a.Idempotency.Release(r.Context(), key)
+	}
+	if err != nil {
+		log.Printf("Error saving idempotency key: %v", err)
+	}
+}
 
-	var subject string
-	var htmlContent string
+// errCaptchaExpired means the CAPTCHA token timed out or was already used,
+// as when a client retries a failed signup with the same token.
+var errCaptchaExpired = errors.New("CAPTCHA token expired or already used")
 
-	if language == "es" {
-		subject = "🎉⚽ ¡Bienvenido a GoalHero!"
-		htmlContent = getWelcomeEmailHTMLSpanish()
-	} else {
-		subject = "🎉⚽ Welcome to GoalHero!"
-		htmlContent = getWelcomeEmailHTML()
+// suspectBot returns why a signup looks automated, or "" if it passes the
+// honeypot, form token and CAPTCHA checks. A CAPTCHA provider that can't
+// be reached lets the signup through; a stale CAPTCHA token returns
+// errCaptchaExpired, since people resend those too.
+func suspectBot(r *http.Request, a *app.App, req *BetaRegisterRequest) (string, error) {
+	if req.Website != "" {
+		return "honeypot filled in", nil
 	}
 
-	message := mail.NewSingleEmail(from, subject, to, "", htmlContent)
+	bots := a.Config.Bots
+	if a.Signer != nil && (req.FormToken != "" || bots.RequireFormToken) {
+		err := formtoken.Verify(a.Signer, req.FormToken, time.Now(), bots.MinFillTime, bots.FormTokenMaxAge)
+		if err != nil {
+			return "form token: " + err.Error(), nil
+		}
+	}
+
+	if a.Captcha != nil {
+		if req.CaptchaToken == "" {
+			return "missing CAPTCHA token", nil
+		}
+		res, err := a.Captcha.Verify(r.Context(), req.CaptchaToken, ratelimit.ClientAddr(r, a.Config.TrustProxy))
+		switch {
+		case err != nil:
+			log.Printf("Error verifying CAPTCHA: %v", err)
+		case res.Expired():
+			return "", errCaptchaExpired
+		case !res.Success:
+			return "CAPTCHA failed: " + strings.Join(res.Errors, ", "), nil
+		case res.Score < bots.CaptchaMinScore:
+			return fmt.Sprintf("CAPTCHA score %.2f below %.2f", res.Score, bots.CaptchaMinScore), nil
+		case res.Hostname != "" && !a.CORS.AllowedHost(res.Hostname) && !sameHost(r, res.Hostname):
+			return "CAPTCHA solved on " + res.Hostname, nil
+		}
+	}
+	return "", nil
+}
+
+// sameHost reports whether host is the one serving r, for forms served by
+// this deployment.
+func sameHost(r *http.Request, host string) bool {
+	h := r.Host
+	if hh, _, err := net.SplitHostPort(h); err == nil {
+		h = hh
+	}
+	return strings.EqualFold(h, host)
+}
 
-	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
-	response, err := client.Send(message)
+// registerBeta stores reg and queues the welcome email, or with double
+// opt-in the confirmation email, in the same write; the outbox sends it
+// after the response. A repeat signup gets StatusAlreadyRegistered without
+// a second email, unless the first email failed, in which case it is
+// queued again. A repeat signup that is still awaiting confirmation gets
+// a fresh confirmation email.
+func registerBeta(w http.ResponseWriter, r *http.Request, a *app.App, reg *registration.Registration) {
+	l := i18n.New(reg.Language)
+	suggestion := emailaddr.Suggest(reg.Email)
+
+	// Every attempt may send an email, so limit how often one address, and
+	// one domain, can be targeted.
+	_, domain, _ := strings.Cut(reg.Key, "@")
+	for _, b := range []struct {
+		key   string
+		limit ratelimit.Limit
+	}{
+		{"email:" + reg.Key, a.Config.RateLimits.Email},
+		{"domain:" + domain, a.Config.RateLimits.Domain},
+	} {
+		if wait := a.Throttle(r.Context(), b.key, b.limit); wait > 0 {
+			writeRateLimited(w, l, wait)
+			return
+		}
+	}
+
+	job, status := registration.JobWelcome, StatusRegistered
+	expires := time.Now().UTC().Add(a.Config.ConfirmTTL)
+	if a.Config.DoubleOptIn {
+		reg.State = registration.StatePending
+		reg.ExpiresAt = &expires
+		job, status = registration.JobConfirm, StatusConfirmationSent
+	}
+
+	err := a.Registrations.Create(r.Context(), reg, job)
+	if errors.Is(err, registration.ErrExists) {
+		existing, gerr := a.Registrations.Get(r.Context(), reg.Key)
+		switch {
+		case gerr != nil:
+			err = gerr
+		case existing.State == registration.StatePending:
+			// The new email's link is valid for a full ConfirmTTL again,
+			// and so is the registration.
+			job, status = registration.JobConfirm, StatusConfirmationSent
+			err = a.Registrations.Renew(r.Context(), reg.Key, expires, job)
+			if errors.Is(err, registration.ErrNotPending) {
+				writeSuccess(w, l, StatusAlreadyRegistered, suggestion)
+				return
+			}
+		case existing.EmailStatus == registration.EmailFailed:
+			job, status = registration.JobWelcome, StatusRegistered
+			err = a.Registrations.Enqueue(r.Context(), reg.Key, job)
+		default:
+			writeSuccess(w, l, StatusAlreadyRegistered, suggestion)
+			return
+		}
+	}
 	if err != nil {
-		fmt.Printf("Error sending email: %v\n", err)
-		return fmt.Errorf("error sending email: %v", err)
+		log.Printf("Error storing registration: %v", err)
+		writeError(w, http.StatusInternalServerError, apierror.New(l, apierror.CodeInternal))
+		return
 	}
 
-	if response.StatusCode >= 400 {
-		fmt.Printf("Error sending email: %v\n", err)
-		return fmt.Errorf("sendgrid error: status code %d", response.StatusCode)
+	a.Outbox.Dispatch(r.Context(), reg.Key)
+	writeSuccess(w, l, status, suggestion)
+}
+
+// writeSuccess reports a successful signup with its localized message and
+// any typo suggestion for the address.
+func writeSuccess(w http.ResponseWriter, l *i18n.Localizer, status, suggestion string) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(BetaRegisterResponse{
+		Success:    true,
+		Status:     status,
+		Message:    l.T("register." + status),
+		Suggestion: suggestion,
+	})
+}
+
+// writeRateLimited reports a throttled request with a Retry-After header.
+func writeRateLimited(w http.ResponseWriter, l *i18n.Localizer, wait time.Duration) {
+	w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+	writeError(w, http.StatusTooManyRequests, apierror.New(l, apierror.CodeRateLimited))
+}
+
+// writeError reports a failure, tagged with the request ID set on w.
+// Server errors also log the ID so a client report can be traced.
+func writeError(w http.ResponseWriter, status int, e *apierror.Error) {
+	e.RequestID = w.Header().Get(apierror.RequestIDHeader)
+	if status >= 500 {
+		log.Printf("Request %s failed: %d %s", e.RequestID, status, e.Code)
 	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(BetaRegisterResponse{
+		Success: false,
+		Message: e.Message,
+		Error:   e,
+	})
+}
 
-	return nil
+// resolveLocale prefers an explicit language from the body, then the
+// Accept-Language header, then the default locale. Only an explicit but
+// unsupported language is an error; a header we can't satisfy just falls
+// through to the default.
+func resolveLocale(language, acceptLanguage string) (string, i18n.Source, error) {
+	if language != "" {
+		locale, err := i18n.Match(language)
+		return locale, i18n.SourceRequest, err
+	}
+	if locale, ok := i18n.Negotiate(acceptLanguage); ok {
+		return locale, i18n.SourceAcceptLanguage, nil
+	}
+	return i18n.DefaultLocale, i18n.SourceDefault, nil
 }
 
-func getWelcomeEmailHTML() string {
-	return `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Welcome to GoalHero!</title>
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-        
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
-            line-height: 1.6;
-            color: #333;
-            background-color: #f8fafc;
-        }
-        
-        .container {
-            max-width: 600px;
-            margin: 0 auto;
-            background-color: #ffffff;
-            border-radius: 16px;
-            overflow: hidden;
-            box-shadow: 0 10px 40px rgba(0, 0, 0, 0.1);
-        }
-        
-        .logo-banner {
-            width: 100%;
-            height: 200px;
-            background: #000000;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            position: relative;
-            padding: 20px;
-        }
-        
-        .logo-banner img {
-            max-width: 300px;
-            max-height: 160px;
-            width: auto;
-            height: auto;
-            display: block;
-            margin: 0 auto;
-        }
-        
-        .header {
-            background: #ffffff;
-            padding: 30px 30px 20px;
-            text-align: center;
-            border-bottom: 1px solid #e5e7eb;
-        }
-        
-        .header h1 {
-            color: #1a1a1a;
-            font-size: 32px;
-            font-weight: 700;
-            margin-bottom: 10px;
-        }
-        
-        .header p {
-            color: #4a4a4a;
-            font-size: 18px;
-            font-weight: 500;
-        }
-        
-        .content {
-            padding: 40px 30px;
-        }
-        
-        .welcome-message {
-            text-align: center;
-            margin-bottom: 40px;
-        }
-        
-        .welcome-message h2 {
-            font-size: 28px;
-            color: #1a1a1a;
-            margin-bottom: 20px;
-            font-weight: 600;
-        }
-        
-        .welcome-message p {
-            font-size: 18px;
-            color: #4a4a4a;
-            line-height: 1.7;
-            max-width: 500px;
-            margin: 0 auto;
-        }
-        
-        .features {
-            background: linear-gradient(135deg, #f8f8f8 0%, #f0f0f0 100%);
-            border-radius: 16px;
-            padding: 30px;
-            margin: 40px 0;
-            border: 1px solid #e0e0e0;
-        }
-        
-        .features h3 {
-            font-size: 22px;
-            color: #1a1a1a;
-            margin-bottom: 25px;
-            text-align: center;
-            font-weight: 600;
-        }
-        
-        .feature-list {
-            list-style: none;
-        }
-        
-        .feature-list li {
-            padding: 12px 0;
-            color: #2a2a2a;
-            font-size: 16px;
-            font-weight: 500;
-            position: relative;
-            padding-left: 60px;
-        }
-        
-        .feature-list li img {
-            position: absolute;
-            left: 0;
-            top: 8px;
-            width: 40px;
-            height: 40px;
-            object-fit: contain;
-        }
-        
-        .cta-section {
-            text-align: center;
-            margin: 40px 0;
-        }
-        
-        .cta-button {
-            display: inline-block;
-            background: linear-gradient(135deg, #00C851 0%, #007E33 100%);
-            color: #ffffff;
-            text-decoration: none;
-            padding: 20px 50px;
-            border-radius: 50px;
-            font-weight: 700;
-            font-size: 19px;
-            letter-spacing: 0.5px;
-            text-transform: uppercase;
-            transition: all 0.4s cubic-bezier(0.175, 0.885, 0.32, 1.275);
-            box-shadow: 0 8px 30px rgba(0, 200, 81, 0.4);
-            border: 3px solid transparent;
-            position: relative;
-            overflow: hidden;
-        }
-        
-        .cta-button:before {
-            content: '';
-            position: absolute;
-            top: 0;
-            left: -100%;
-            width: 100%;
-            height: 100%;
-            background: linear-gradient(90deg, transparent, rgba(255, 255, 255, 0.3), transparent);
-            transition: left 0.6s;
-        }
-        
-        .cta-button:hover {
-            transform: translateY(-5px) scale(1.05);
-            box-shadow: 0 15px 40px rgba(0, 200, 81, 0.6);
-            border-color: rgba(255, 255, 255, 0.3);
-        }
-        
-        .cta-button:hover:before {
-            left: 100%;
-        }
-        
-        .cta-button:active {
-            transform: translateY(-2px) scale(1.02);
-            transition: all 0.1s ease;
-        }
-        
-        .social-section {
-            background: linear-gradient(135deg, #f8f8f8 0%, #f0f0f0 100%);
-            border-radius: 16px;
-            padding: 35px;
-            text-align: center;
-            margin: 40px 0;
-            border: 1px solid #e0e0e0;
-        }
-        
-        .social-section h3 {
-            font-size: 22px;
-            color: #1a1a1a;
-            margin-bottom: 30px;
-            font-weight: 600;
-        }
-        
-        .social-links {
-            display: flex;
-            justify-content: center;
-            align-items: center;
-            gap: 15px;
-            flex-wrap: wrap;
-            text-align: center;
-            width: 100%;
-        }
-        
-        .social-link {
-            display: inline-flex;
-            align-items: center;
-            justify-content: center;
-            text-decoration: none;
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            font-weight: 700;
-            padding: 15px 25px;
-            border-radius: 50px;
-            transition: all 0.4s cubic-bezier(0.175, 0.885, 0.32, 1.275);
-            font-size: 15px;
-            width: 155px;
-            height: 50px;
-            position: relative;
-            overflow: hidden;
-            box-sizing: border-box;
-            text-align: center;
-            line-height: 1.2;
-            margin: 0 auto;
-        }
-        
-        .social-link.website {
-            background: linear-gradient(135deg, #1a73e8 0%, #1557b0 100%);
-            color: #ffffff;
-            box-shadow: 0 6px 20px rgba(26, 115, 232, 0.3);
-            border: 2px solid transparent;
-        }
-        
-        .social-link.instagram {
-            background: linear-gradient(45deg, #f09433 0%,#e6683c 25%,#dc2743 50%,#cc2366 75%,#bc1888 100%);
-            color: #ffffff;
-            box-shadow: 0 6px 20px rgba(225, 48, 108, 0.3);
-            border: 2px solid transparent;
-        }
-        
-        .social-link:before {
-            content: '';
-            position: absolute;
-            top: 0;
-            left: -100%;
-            width: 100%;
-            height: 100%;
-            background: linear-gradient(90deg, transparent, rgba(255, 255, 255, 0.3), transparent);
-            transition: left 0.6s;
-        }
-        
-        .social-link:hover {
-            transform: translateY(-3px) scale(1.05);
-            box-shadow: 0 12px 35px rgba(0, 0, 0, 0.2);
-        }
-        
-        .social-link.website:hover {
-            box-shadow: 0 12px 35px rgba(26, 115, 232, 0.4);
-        }
-        
-        .social-link.instagram:hover {
-            box-shadow: 0 12px 35px rgba(225, 48, 108, 0.4);
-        }
-        
-        .social-link:hover:before {
-            left: 100%;
-        }
-        
-        .social-link:active {
-            transform: translateY(-1px) scale(1.02);
-            transition: all 0.1s ease;
-        }
-        
-        .footer {
-            background: linear-gradient(135deg, #1a1a1a 0%, #000000 100%);
-            color: #cccccc;
-            padding: 40px 30px;
-            text-align: center;
-        }
-        
-        .footer p {
-            margin-bottom: 15px;
-            font-size: 16px;
-        }
-        
-        .footer a {
-            color: #4CAF50;
-            text-decoration: none;
-            transition: color 0.3s ease;
-        }
-        
-        .footer a:hover {
-            color: #66BB6A;
-        }
-        
-        @media (max-width: 600px) {
-            .container {
-                margin: 10px;
-                border-radius: 12px;
-            }
-            
-            .logo-banner {
-                height: 150px;
-            }
-            
-            .logo-banner img {
-                max-width: 200px;
-                max-height: 80px;
-            }
-            
-            .header, .content {
-                padding: 25px 20px;
-            }
-            
-            .header h1 {
-                font-size: 28px;
-            }
-            
-            .welcome-message h2 {
-                font-size: 24px;
-            }
-            
-            .welcome-message p {
-                font-size: 16px;
-            }
-            
-            .features, .social-section {
-                padding: 25px 20px;
-            }
-            
-            .social-links {
-                flex-direction: column;
-                align-items: center;
-                gap: 12px;
-                width: 100%;
-            }
-            
-            .social-link {
-                width: 200px;
-                height: 50px;
-                margin: 0 auto;
-            }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="logo-banner">
-            <img src="https://www.goalhero.eu/assets/icon.png" alt="GoalHero Logo" />
-        </div>
-        
-        <div class="header">
-            <h1>Welcome to GoalHero!</h1>
-            <p>Never cancel another match - find goalkeepers instantly</p>
-        </div>
-        
-        <div class="content">
-            <div class="welcome-message">
-                <h2>⚽ Welcome to the Beta!</h2>
-                <p>Thank you for joining GoalHero! You're among the first to experience our revolutionary goalkeeper marketplace that ensures your team never forfeits another match due to missing keepers.</p>
-                <p style="margin-top: 20px; font-weight: 600; color: #00C851;">📱 We'll contact you as soon as the beta is ready for download!</p>
-            </div>
-            
-            <div class="features">
-                <h3>What's Coming Your Way</h3>
-                <ul class="feature-list">
-                    <li>Post games and receive competitive bids from goalkeepers</li>
-                    <li>Browse verified goalkeeper profiles with ratings & reviews</li>
-                    <li>Secure payment system with guaranteed show-up protection</li>
-                </ul>
-            </div>        
-            
-            <div class="social-section">
-                <h3>Stay Connected</h3>
-                <div class="social-links">
-                    <a href="https://www.goalhero.eu" class="social-link website">🌐 Website</a>
-                    <a href="https://instagram.com/goalhero.app" class="social-link instagram">📷 Instagram</a>
-                </div>
-            </div>
-            
-            <div style="text-align: center; margin-top: 40px; padding-top: 30px; border-top: 1px solid #e5e7eb;">
-                <p style="color: #6b7280; font-size: 16px;">
-                    Have questions? We're here to help! Reply to this email or contact us at 
-                    <a href="mailto:info@goalhero.eu" style="color: #4CAF50;">info@goalhero.eu</a>
-                </p>
-            </div>
-        </div>
-        
-        <div class="footer">
-            <p><strong>GoalHero Team</strong></p>
-            <p>Making dreams achievable, one goal at a time.</p>
-            <p style="margin-top: 20px; font-size: 14px; opacity: 0.8;">
-                © 2025 GoalHero. All rights reserved.<br>
-                <a href="#">Unsubscribe</a> | <a href="#">Privacy Policy</a>
-            </p>
-        </div>
-    </div>
-</body>
-</html>
-    `
+// negotiateLocale picks the locale for messages sent before the request's
+// own language is known.
+func negotiateLocale(acceptLanguage string) string {
+	if locale, ok := i18n.Negotiate(acceptLanguage); ok {
+		return locale
+	}
+	return i18n.DefaultLocale
 }
 
-func getWelcomeEmailHTMLSpanish() string {
-	return `
-<!DOCTYPE html>
-<html lang="es">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>¡Bienvenido a GoalHero!</title>
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-        
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
-            line-height: 1.6;
-            color: #333;
-            background-color: #f8fafc;
-        }
-        
-        .container {
-            max-width: 600px;
-            margin: 0 auto;
-            background-color: #ffffff;
-            border-radius: 16px;
-            overflow: hidden;
-            box-shadow: 0 10px 40px rgba(0, 0, 0, 0.1);
-        }
-        
-        .logo-banner {
-            width: 100%;
-            height: 200px;
-            background: #000000;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            position: relative;
-            padding: 20px;
-        }
-        
-        .logo-banner img {
-            max-width: 300px;
-            max-height: 160px;
-            width: auto;
-            height: auto;
-            display: block;
-            margin: 0 auto;
-        }
-        
-        .header {
-            background: #ffffff;
-            padding: 30px 30px 20px;
-            text-align: center;
-            border-bottom: 1px solid #e5e7eb;
-        }
-        
-        .header h1 {
-            color: #1a1a1a;
-            font-size: 32px;
-            font-weight: 700;
-            margin-bottom: 10px;
-        }
-        
-        .header p {
-            color: #4a4a4a;
-            font-size: 18px;
-            font-weight: 500;
-        }
-        
-        .content {
-            padding: 40px 30px;
-        }
-        
-        .welcome-message {
-            text-align: center;
-            margin-bottom: 40px;
-        }
-        
-        .welcome-message h2 {
-            font-size: 28px;
-            color: #1a1a1a;
-            margin-bottom: 20px;
-            font-weight: 600;
-        }
-        
-        .welcome-message p {
-            font-size: 18px;
-            color: #4a4a4a;
-            line-height: 1.7;
-            max-width: 500px;
-            margin: 0 auto;
-        }
-        
-        .features {
-            background: linear-gradient(135deg, #f8f8f8 0%, #f0f0f0 100%);
-            border-radius: 16px;
-            padding: 30px;
-            margin: 40px 0;
-            border: 1px solid #e0e0e0;
-        }
-        
-        .features h3 {
-            font-size: 22px;
-            color: #1a1a1a;
-            margin-bottom: 25px;
-            text-align: center;
-            font-weight: 600;
-        }
-        
-        .feature-list {
-            list-style: none;
-        }
-        
-        .feature-list li {
-            padding: 12px 0;
-            color: #2a2a2a;
-            font-size: 16px;
-            font-weight: 500;
-            position: relative;
-            padding-left: 60px;
-        }
-        
-        .feature-list li img {
-            position: absolute;
-            left: 0;
-            top: 8px;
-            width: 40px;
-            height: 40px;
-            object-fit: contain;
-        }
-        
-        .cta-section {
-            text-align: center;
-            margin: 40px 0;
-        }
-        
-        .cta-button {
-            display: inline-block;
-            background: linear-gradient(135deg, #00C851 0%, #007E33 100%);
-            color: #ffffff;
-            text-decoration: none;
-            padding: 20px 50px;
-            border-radius: 50px;
-            font-weight: 700;
-            font-size: 19px;
-            letter-spacing: 0.5px;
-            text-transform: uppercase;
-            transition: all 0.4s cubic-bezier(0.175, 0.885, 0.32, 1.275);
-            box-shadow: 0 8px 30px rgba(0, 200, 81, 0.4);
-            border: 3px solid transparent;
-            position: relative;
-            overflow: hidden;
-        }
-        
-        .cta-button:before {
-            content: '';
-            position: absolute;
-            top: 0;
-            left: -100%;
-            width: 100%;
-            height: 100%;
-            background: linear-gradient(90deg, transparent, rgba(255, 255, 255, 0.3), transparent);
-            transition: left 0.6s;
-        }
-        
-        .cta-button:hover {
-            transform: translateY(-5px) scale(1.05);
-            box-shadow: 0 15px 40px rgba(0, 200, 81, 0.6);
-            border-color: rgba(255, 255, 255, 0.3);
-        }
-        
-        .cta-button:hover:before {
-            left: 100%;
-        }
-        
-        .cta-button:active {
-            transform: translateY(-2px) scale(1.02);
-            transition: all 0.1s ease;
-        }
-        
-        .social-section {
-            background: linear-gradient(135deg, #f8f8f8 0%, #f0f0f0 100%);
-            border-radius: 16px;
-            padding: 35px;
-            text-align: center;
-            margin: 40px 0;
-            border: 1px solid #e0e0e0;
-        }
-        
-        .social-section h3 {
-            font-size: 22px;
-            color: #1a1a1a;
-            margin-bottom: 30px;
-            font-weight: 600;
-        }
-        
-        .social-links {
-            display: flex;
-            justify-content: center;
-            align-items: center;
-            gap: 15px;
-            flex-wrap: wrap;
-            text-align: center;
-            width: 100%;
-        }
-        
-        .social-link {
-            display: inline-flex;
-            align-items: center;
-            justify-content: center;
-            text-decoration: none;
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            font-weight: 700;
-            padding: 15px 25px;
-            border-radius: 50px;
-            transition: all 0.4s cubic-bezier(0.175, 0.885, 0.32, 1.275);
-            font-size: 15px;
-            width: 155px;
-            height: 50px;
-            position: relative;
-            overflow: hidden;
-            box-sizing: border-box;
-            text-align: center;
-            line-height: 1.2;
-            margin: 0 auto;
-        }
-        
-        .social-link.website {
-            background: linear-gradient(135deg, #1a73e8 0%, #1557b0 100%);
-            color: #ffffff;
-            box-shadow: 0 6px 20px rgba(26, 115, 232, 0.3);
-            border: 2px solid transparent;
-        }
-        
-        .social-link.instagram {
-            background: linear-gradient(45deg, #f09433 0%,#e6683c 25%,#dc2743 50%,#cc2366 75%,#bc1888 100%);
-            color: #ffffff;
-            box-shadow: 0 6px 20px rgba(225, 48, 108, 0.3);
-            border: 2px solid transparent;
-        }
-        
-        .social-link:before {
-            content: '';
-            position: absolute;
-            top: 0;
-            left: -100%;
-            width: 100%;
-            height: 100%;
-            background: linear-gradient(90deg, transparent, rgba(255, 255, 255, 0.3), transparent);
-            transition: left 0.6s;
-        }
-        
-        .social-link:hover {
-            transform: translateY(-3px) scale(1.05);
-            box-shadow: 0 12px 35px rgba(0, 0, 0, 0.2);
-        }
-        
-        .social-link.website:hover {
-            box-shadow: 0 12px 35px rgba(26, 115, 232, 0.4);
-        }
-        
-        .social-link.instagram:hover {
-            box-shadow: 0 12px 35px rgba(225, 48, 108, 0.4);
-        }
-        
-        .social-link:hover:before {
-            left: 100%;
-        }
-        
-        .social-link:active {
-            transform: translateY(-1px) scale(1.02);
-            transition: all 0.1s ease;
-        }
-        
-        .footer {
-            background: linear-gradient(135deg, #1a1a1a 0%, #000000 100%);
-            color: #cccccc;
-            padding: 40px 30px;
-            text-align: center;
-        }
-        
-        .footer p {
-            margin-bottom: 15px;
-            font-size: 16px;
-        }
-        
-        .footer a {
-            color: #4CAF50;
-            text-decoration: none;
-            transition: color 0.3s ease;
-        }
-        
-        .footer a:hover {
-            color: #66BB6A;
-        }
-        
-        @media (max-width: 600px) {
-            .container {
-                margin: 10px;
-                border-radius: 12px;
-            }
-            
-            .logo-banner {
-                height: 150px;
-            }
-            
-            .logo-banner img {
-                max-width: 200px;
-                max-height: 80px;
-            }
-            
-            .header, .content {
-                padding: 25px 20px;
-            }
-            
-            .header h1 {
-                font-size: 28px;
-            }
-            
-            .welcome-message h2 {
-                font-size: 24px;
-            }
-            
-            .welcome-message p {
-                font-size: 16px;
-            }
-            
-            .features, .social-section {
-                padding: 25px 20px;
-            }
-            
-            .social-links {
-                flex-direction: column;
-                align-items: center;
-                gap: 12px;
-                width: 100%;
-            }
-            
-            .social-link {
-                width: 200px;
-                height: 50px;
-                margin: 0 auto;
-            }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="logo-banner">
-            <img src="https://www.goalhero.eu/assets/icon.png" alt="GoalHero Logo" />
-        </div>
-        
-        <div class="header">
-            <h1>¡Bienvenido a GoalHero!</h1>
-            <p>Nunca canceles otro partido - encuentra porteros al instante</p>
-        </div>
-        
-        <div class="content">
-            <div class="welcome-message">
-                <h2>⚽ ¡Bienvenido a la Beta!</h2>
-                <p>¡Gracias por unirte a GoalHero! Estás entre los primeros en experimentar nuestro revolucionario marketplace de porteros que asegura que tu equipo nunca más tenga que abandonar un partido por falta de porteros.</p>
-                <p style="margin-top: 20px; font-weight: 600; color: #00C851;">📱 ¡Te contactaremos tan pronto como la beta esté lista para descargar!</p>
-            </div>
-            
-            <div class="features">
-                <h3>Lo Que Te Espera</h3>
-                <ul class="feature-list">
-                    <li>Publica partidos y recibe ofertas competitivas de porteros</li>
-                    <li>Explora perfiles verificados de porteros con calificaciones y reseñas</li>
-                    <li>Sistema de pago seguro con protección de asistencia garantizada</li>
-                </ul>
-            </div>
-        
-            <div class="social-section">
-                <h3>Mantente Conectado</h3>
-                <div class="social-links">
-                    <a href="https://www.goalhero.eu" class="social-link website">🌐 Sitio Web</a>
-                    <a href="https://instagram.com/goalhero.app" class="social-link instagram">📷 Instagram</a>
-                </div>
-            </div>
-            
-            <div style="text-align: center; margin-top: 40px; padding-top: 30px; border-top: 1px solid #e5e7eb;">
-                <p style="color: #6b7280; font-size: 16px;">
-                    ¿Tienes preguntas? ¡Estamos aquí para ayudar! Responde a este email o contáctanos en 
-                    <a href="mailto:info@goalhero.eu" style="color: #4CAF50;">info@goalhero.eu</a>
-                </p>
-            </div>
-        </div>
-        
-        <div class="footer">
-            <p><strong>Equipo GoalHero</strong></p>
-            <p>Haciendo los sueños alcanzables, un gol a la vez.</p>
-            <p style="margin-top: 20px; font-size: 14px; opacity: 0.8;">
-                © 2025 GoalHero. Todos los derechos reservados.<br>
-                <a href="#">Darse de baja</a> | <a href="#">Política de Privacidad</a>
-            </p>
-        </div>
-    </div>
-</body>
-</html>
-    `
+// signupSource sanitizes the client-supplied source label.
+func signupSource(source string) string {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return defaultSource
+	}
+	if r := []rune(source); len(r) > maxSourceLength {
+		source = string(r[:maxSourceLength])
+	}
+	return source
 }
@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/apierror"
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/config"
+	"goalhero-emailer/pkg/confirm"
+	"goalhero-emailer/pkg/mailer"
+	"goalhero-emailer/pkg/registration"
+	"goalhero-emailer/pkg/suppression"
+)
+
+// fakeMailer records what it is asked to send.
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []*mailer.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg *mailer.Message) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return "msg-1", nil
+}
+
+func (m *fakeMailer) recipients() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var to []string
+	for _, msg := range m.sent {
+		to = append(to, msg.To.Email)
+	}
+	return to
+}
+
+// newTestApp builds an App on files in a temporary directory whose emails
+// go to a fakeMailer, sent before the response. env overrides settings.
+func newTestApp(t *testing.T, env ...string) (*app.App, *fakeMailer) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg, err := config.Parse(append([]string{
+		"MAILER=capture",
+		"CAPTURE_DIR=" + dir,
+		"DATA_DIR=" + dir,
+		"FROM_EMAIL=team@goalhero.eu",
+		"FROM_EMAIL_ES=equipo@goalhero.eu",
+		"REPLY_TO=support@goalhero.eu",
+		"OUTBOX_SYNC=true",
+	}, env...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeMailer{}
+	a.Mailer = suppression.Filter(a.Suppressions, fake)
+	return a, fake
+}
+
+func postSignup(t *testing.T, a *app.App, body string) (*httptest.ResponseRecorder, BetaRegisterResponse) {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/api/beta-register", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handleBetaRegister(w, r, a)
+
+	var resp BetaRegisterResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response %q: %v", w.Body, err)
+	}
+	return w, resp
+}
+
+func TestBetaRegister(t *testing.T) {
+	a, fake := newTestApp(t)
+	w, resp := postSignup(t, a, `{"email": "jane@example.com", "language": "es"}`)
+	if w.Code != http.StatusOK || !resp.Success || resp.Status != StatusRegistered {
+		t.Fatalf("signup: %d %+v", w.Code, resp)
+	}
+	if got := fake.recipients(); len(got) != 1 || got[0] != "jane@example.com" {
+		t.Fatalf("sent to %v", got)
+	}
+	if msg := fake.sent[0]; !strings.Contains(msg.Subject, "Bienvenido") || msg.HTML == "" {
+		t.Errorf("sent %q, want the Spanish welcome email", msg.Subject)
+	}
+	if msg := fake.sent[0]; msg.From.Email != "equipo@goalhero.eu" || msg.ReplyTo == nil || msg.ReplyTo.Email != "support@goalhero.eu" {
+		t.Errorf("from %+v reply-to %+v, want the Spanish sender", msg.From, msg.ReplyTo)
+	}
+	reg, err := a.Registrations.Get(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.Language != "es" || reg.EmailStatus != registration.EmailSent {
+		t.Errorf("stored %+v", reg)
+	}
+	if w.Header().Get(apierror.RequestIDHeader) == "" {
+		t.Errorf("no request ID")
+	}
+}
+
+func TestBetaRegisterInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "malformed", body: `{"email":`},
+		{name: "no email", body: `{"language": "en"}`},
+		{name: "unknown language", body: `{"email": "jane@example.com", "language": "fr"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, fake := newTestApp(t)
+			w, resp := postSignup(t, a, tt.body)
+			if w.Code != http.StatusBadRequest || resp.Success {
+				t.Errorf("signup: %d %+v", w.Code, resp)
+			}
+			if got := fake.recipients(); len(got) != 0 {
+				t.Errorf("sent to %v", got)
+			}
+		})
+	}
+}
+
+func TestBetaRegisterFieldError(t *testing.T) {
+	a, _ := newTestApp(t)
+	w, resp := postSignup(t, a, `{"email": "not-an-address"}`)
+	if w.Code != http.StatusBadRequest || resp.Success || resp.Error == nil {
+		t.Fatalf("signup: %d %+v", w.Code, resp)
+	}
+	e := resp.Error
+	if e.Code != apierror.CodeValidationFailed || len(e.Fields) != 1 ||
+		e.Fields[0].Field != "email" || e.Fields[0].Code != apierror.CodeEmailInvalid {
+		t.Errorf("error %+v", e)
+	}
+	if e.RequestID == "" || e.RequestID != w.Header().Get(apierror.RequestIDHeader) {
+		t.Errorf("request ID %q, header %q", e.RequestID, w.Header().Get(apierror.RequestIDHeader))
+	}
+}
+
+func TestBetaRegisterDuplicate(t *testing.T) {
+	a, fake := newTestApp(t)
+	postSignup(t, a, `{"email": "jane@example.com"}`)
+	w, resp := postSignup(t, a, `{"email": "Jane@Example.com"}`)
+	if w.Code != http.StatusOK || !resp.Success || resp.Status != StatusAlreadyRegistered {
+		t.Fatalf("repeat signup: %d %+v", w.Code, resp)
+	}
+	if got := fake.recipients(); len(got) != 1 {
+		t.Errorf("sent %d emails, want 1", len(got))
+	}
+}
+
+func TestBetaRegisterSuppressed(t *testing.T) {
+	a, fake := newTestApp(t)
+	err := a.Suppressions.Add(context.Background(), suppression.Entry{
+		Email:     "jane@example.com",
+		Reason:    suppression.ReasonUnsubscribed,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, resp := postSignup(t, a, `{"email": "jane@example.com"}`)
+	if w.Code != http.StatusOK || !resp.Success {
+		t.Fatalf("signup: %d %+v", w.Code, resp)
+	}
+	if got := fake.recipients(); len(got) != 0 {
+		t.Errorf("sent to %v", got)
+	}
+	reg, err := a.Registrations.Get(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.EmailStatus != registration.EmailSuppressed {
+		t.Errorf("email status %q, want %q", reg.EmailStatus, registration.EmailSuppressed)
+	}
+}
+
+func TestBetaRegisterRateLimited(t *testing.T) {
+	a, fake := newTestApp(t, "RATE_LIMIT_IP=1/1h")
+	postSignup(t, a, `{"email": "jane@example.com"}`)
+	w, resp := postSignup(t, a, `{"email": "john@example.com"}`)
+	if w.Code != http.StatusTooManyRequests || resp.Error == nil || resp.Error.Code != apierror.CodeRateLimited {
+		t.Fatalf("signup over the limit: %d %+v", w.Code, resp)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("no Retry-After")
+	}
+	if got := fake.recipients(); len(got) != 1 {
+		t.Errorf("sent to %v", got)
+	}
+}
+
+func TestBetaRegisterMethod(t *testing.T) {
+	a, _ := newTestApp(t)
+	w := httptest.NewRecorder()
+	handleBetaRegister(w, httptest.NewRequest("GET", "/api/beta-register", nil), a)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status %d, want 405", w.Code)
+	}
+}
+
+func TestBetaRegisterPendingAgain(t *testing.T) {
+	a, fake := newTestApp(t,
+		"DOUBLE_OPT_IN=true",
+		"PUBLIC_BASE_URL=https://goalhero.eu",
+		"SIGNING_SECRET=0123456789abcdef0123456789abcdef",
+	)
+	ctx := context.Background()
+	postSignup(t, a, `{"email": "jane@example.com"}`)
+	// Let most of the confirmation window pass.
+	soon := time.Now().UTC().Add(time.Hour)
+	if err := a.Registrations.Renew(ctx, "jane@example.com", soon); err != nil {
+		t.Fatal(err)
+	}
+
+	w, resp := postSignup(t, a, `{"email": "jane@example.com"}`)
+	if w.Code != http.StatusOK || resp.Status != StatusConfirmationSent {
+		t.Fatalf("repeat signup: %d %+v", w.Code, resp)
+	}
+	reg, err := a.Registrations.Get(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.ExpiresAt == nil || reg.ExpiresAt.Before(time.Now().Add(a.Config.ConfirmTTL-time.Minute)) {
+		t.Fatalf("expiry %v not extended", reg.ExpiresAt)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.sent) != 2 {
+		t.Fatalf("sent %d emails, want 2", len(fake.sent))
+	}
+	link := confirm.URL(a.Signer, a.Config.BaseURL, reg.Key, *reg.ExpiresAt)
+	if !strings.Contains(fake.sent[1].Text, link) {
+		t.Errorf("second email does not link to %s:\n%s", link, fake.sent[1].Text)
+	}
+}
+
+func TestBetaRegisterOriginNotAllowed(t *testing.T) {
+	a, _ := newTestApp(t, "CORS_ALLOWED_ORIGINS=https://goalhero.eu")
+	r := httptest.NewRequest("POST", "/api/beta-register", strings.NewReader(`{"email": "jane@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	handleBetaRegister(w, r, a)
+
+	var resp BetaRegisterResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response %q: %v", w.Body, err)
+	}
+	if w.Code != http.StatusForbidden || resp.Error == nil || resp.Error.Code != apierror.CodeOriginNotAllowed {
+		t.Fatalf("signup: %d %+v", w.Code, resp)
+	}
+	if resp.Error.RequestID == "" {
+		t.Errorf("no request ID")
+	}
+	if _, err := a.Registrations.Get(context.Background(), "jane@example.com"); err == nil {
+		t.Errorf("registered from a disallowed origin")
+	}
+}
+
+func TestBetaRegisterBody(t *testing.T) {
+	a, _ := newTestApp(t, "MAX_BODY_BYTES=1024", "STRICT_REQUESTS=true")
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        apierror.Code
+	}{
+		{name: "too large", contentType: "application/json", body: `{"email": "` + strings.Repeat("a", 2048) + `"}`,
+			status: http.StatusRequestEntityTooLarge, code: apierror.CodeBodyTooLarge},
+		{name: "unsupported type", contentType: "text/plain", body: `{"email": "jane@example.com"}`,
+			status: http.StatusUnsupportedMediaType, code: apierror.CodeUnsupportedMedia},
+		{name: "malformed", contentType: "application/json", body: `{"email": `,
+			status: http.StatusBadRequest, code: apierror.CodeInvalidBody},
+		{name: "unknown field", contentType: "application/json", body: `{"email": "jane@example.com", "plan": "pro"}`,
+			status: http.StatusBadRequest, code: apierror.CodeValidationFailed},
+		{name: "form with submit button", contentType: "application/x-www-form-urlencoded", body: "email=jane%40example.com&submit=Join",
+			status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/beta-register", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			handleBetaRegister(w, r, a)
+
+			var resp BetaRegisterResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response %q: %v", w.Body, err)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %+v", w.Code, tt.status, resp)
+			}
+			if tt.code != "" && (resp.Error == nil || resp.Error.Code != tt.code) {
+				t.Errorf("error %+v, want code %s", resp.Error, tt.code)
+			}
+		})
+	}
+}
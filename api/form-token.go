@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/cors"
+	"goalhero-emailer/pkg/formtoken"
+)
+
+type FormTokenResponse struct {
+	Success bool   `json:"success"`
+	Token   string `json:"token,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// FormTokenHandler issues the signed render timestamp a signup form sends
+// back as "form_token". Forms should fetch it when they are shown, not
+// when they are submitted. It is disabled when SIGNING_SECRET is unset.
+func FormTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	reply := func(status int, resp FormTokenResponse) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		reply(http.StatusInternalServerError, FormTokenResponse{Message: "Service unavailable"})
+		return
+	}
+	corsOpts := cors.Options{
+		Methods: []string{"GET"},
+		Reject: func(w http.ResponseWriter, r *http.Request) {
+			reply(http.StatusForbidden, FormTokenResponse{Message: "Origin not allowed"})
+		},
+	}
+	if !a.CORS.Handle(w, r, corsOpts) {
+		return
+	}
+	if r.Method != "GET" {
+		reply(http.StatusMethodNotAllowed, FormTokenResponse{Message: "Method not allowed"})
+		return
+	}
+	if a.Signer == nil {
+		reply(http.StatusNotFound, FormTokenResponse{Message: "Not found"})
+		return
+	}
+
+	reply(http.StatusOK, FormTokenResponse{Success: true, Token: formtoken.Token(a.Signer, time.Now())})
+}
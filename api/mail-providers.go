@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/mailer"
+)
+
+type MailProvidersResponse struct {
+	Success   bool            `json:"success"`
+	Message   string          `json:"message,omitempty"`
+	Providers []mailer.Health `json:"providers,omitempty"`
+}
+
+// MailProvidersHandler reports the circuit breaker of every mail provider,
+// in failover order, as seen by the instance that answers. It requires
+// "Authorization: Bearer $ADMIN_TOKEN" and is disabled when ADMIN_TOKEN
+// is unset.
+//
+//	GET /api/mail-providers
+func MailProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		writeMailProviders(w, http.StatusInternalServerError, MailProvidersResponse{Message: "Service unavailable"})
+		return
+	}
+	handleMailProviders(w, r, a)
+}
+
+// handleMailProviders reports the providers of a, which tests build
+// themselves.
+func handleMailProviders(w http.ResponseWriter, r *http.Request, a *app.App) {
+	reply := func(status int, resp MailProvidersResponse) {
+		writeMailProviders(w, status, resp)
+	}
+
+	if a.Config.AdminToken == "" {
+		reply(http.StatusNotFound, MailProvidersResponse{Message: "Not found"})
+		return
+	}
+	if !a.AdminAuthorized(r) {
+		reply(http.StatusUnauthorized, MailProvidersResponse{Message: "Unauthorized"})
+		return
+	}
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		reply(http.StatusMethodNotAllowed, MailProvidersResponse{Message: "Method not allowed"})
+		return
+	}
+
+	reply(http.StatusOK, MailProvidersResponse{Success: true, Providers: a.Providers.Health()})
+}
+
+func writeMailProviders(w http.ResponseWriter, status int, resp MailProvidersResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
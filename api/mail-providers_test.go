@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/mailer"
+)
+
+// downMailer fails every send as an unreachable provider would.
+type downMailer struct{}
+
+func (downMailer) Send(ctx context.Context, msg *mailer.Message) (string, error) {
+	return "", &mailer.SendError{Temporary: true, Err: errors.New("connection refused")}
+}
+
+func mailProvidersRequest(a *app.App, method, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/mail-providers", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handleMailProviders(w, r, a)
+	return w
+}
+
+func TestMailProviders(t *testing.T) {
+	a, _ := newTestApp(t, "ADMIN_TOKEN="+testAdminToken)
+	a.Providers = mailer.NewFailover(1, time.Hour,
+		mailer.Provider{Name: "smtp", Mailer: downMailer{}},
+		mailer.Provider{Name: "sendgrid", Mailer: &fakeMailer{}},
+	)
+	if _, err := a.Providers.Send(context.Background(), &mailer.Message{}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := mailProvidersRequest(a, "GET", testAdminToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET: %d %s", w.Code, w.Body)
+	}
+	// Decode loosely to check the wire names, not just the round trip.
+	var resp struct {
+		Success   bool             `json:"success"`
+		Providers []map[string]any `json:"providers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || !resp.Success || len(resp.Providers) != 2 {
+		t.Fatalf("response %s: %v", w.Body, err)
+	}
+	smtp, sendgrid := resp.Providers[0], resp.Providers[1]
+	if smtp["name"] != "smtp" || smtp["state"] != "open" || smtp["failures"] != 1.0 || smtp["last_error"] != "connection refused" {
+		t.Errorf("smtp = %v", smtp)
+	}
+	until, _ := smtp["open_until"].(string)
+	if at, err := time.Parse(time.RFC3339, until); err != nil || at.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("smtp open_until = %q, want about an hour from now", until)
+	}
+	if sendgrid["name"] != "sendgrid" || sendgrid["state"] != "closed" || sendgrid["failures"] != 0.0 {
+		t.Errorf("sendgrid = %v", sendgrid)
+	}
+	for _, key := range []string{"open_until", "last_error"} {
+		if _, ok := sendgrid[key]; ok {
+			t.Errorf("sendgrid has %s while closed: %v", key, sendgrid)
+		}
+	}
+}
+
+func TestMailProvidersInvalid(t *testing.T) {
+	a, _ := newTestApp(t, "ADMIN_TOKEN="+testAdminToken)
+	disabled, _ := newTestApp(t)
+	tests := []struct {
+		name   string
+		app    *app.App
+		method string
+		token  string
+		status int
+	}{
+		{name: "no token", app: a, method: "GET", status: http.StatusUnauthorized},
+		{name: "method", app: a, method: "POST", token: testAdminToken, status: http.StatusMethodNotAllowed},
+		{name: "disabled", app: disabled, method: "GET", token: testAdminToken, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := mailProvidersRequest(tt.app, tt.method, tt.token); w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/mailbox"
+	"goalhero-emailer/pkg/mailer"
+)
+
+type MailboxResponse struct {
+	Success  bool              `json:"success"`
+	Message  string            `json:"message,omitempty"`
+	Messages []mailer.Captured `json:"messages,omitempty"`
+}
+
+// MailboxHandler shows the emails kept by the capture mailer. It only
+// exists with MAILER=capture, which is meant for development, and needs
+// the admin token, or a local client when ADMIN_TOKEN is unset.
+//
+//	GET    /api/mailbox                 list (HTML, or JSON with Accept: application/json)
+//	GET    /api/mailbox?id=...          one message (HTML or JSON)
+//	GET    /api/mailbox?id=...&part=... its html, text or raw (.eml) part
+//	DELETE /api/mailbox                 delete every message
+func MailboxHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		writeMailbox(w, http.StatusInternalServerError, MailboxResponse{Message: "Service unavailable"})
+		return
+	}
+	handleMailbox(w, r, a)
+}
+
+// handleMailbox serves the mailbox of a, which tests build themselves.
+func handleMailbox(w http.ResponseWriter, r *http.Request, a *app.App) {
+	wantJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+	if a.Capture == nil {
+		writeMailbox(w, http.StatusNotFound, MailboxResponse{Message: "Not found"})
+		return
+	}
+	if !a.MailboxAuthorized(r) {
+		writeMailbox(w, http.StatusUnauthorized, MailboxResponse{Message: "Unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			msgs, err := a.Capture.List(r.Context())
+			if err != nil {
+				log.Printf("Error listing mailbox: %v", err)
+				writeMailbox(w, http.StatusInternalServerError, MailboxResponse{Message: "Failed to list mailbox"})
+				return
+			}
+			if wantJSON {
+				writeMailbox(w, http.StatusOK, MailboxResponse{Success: true, Messages: msgs})
+				return
+			}
+			mailbox.List(w, msgs)
+			return
+		}
+
+		m, err := a.Capture.Get(r.Context(), id)
+		if errors.Is(err, mailer.ErrCapturedNotFound) {
+			writeMailbox(w, http.StatusNotFound, MailboxResponse{Message: "Message not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading captured email %s: %v", id, err)
+			writeMailbox(w, http.StatusInternalServerError, MailboxResponse{Message: "Failed to read message"})
+			return
+		}
+		switch {
+		case r.URL.Query().Has("part"):
+			mailbox.Part(w, m, r.URL.Query().Get("part"))
+		case wantJSON:
+			writeMailbox(w, http.StatusOK, MailboxResponse{Success: true, Messages: []mailer.Captured{m}})
+		default:
+			mailbox.Message(w, m)
+		}
+
+	case "DELETE":
+		if err := a.Capture.Clear(r.Context()); err != nil {
+			log.Printf("Error clearing mailbox: %v", err)
+			writeMailbox(w, http.StatusInternalServerError, MailboxResponse{Message: "Failed to clear mailbox"})
+			return
+		}
+		writeMailbox(w, http.StatusOK, MailboxResponse{Success: true, Message: "Mailbox cleared"})
+
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeMailbox(w, http.StatusMethodNotAllowed, MailboxResponse{Message: "Method not allowed"})
+	}
+}
+
+func writeMailbox(w http.ResponseWriter, status int, resp MailboxResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
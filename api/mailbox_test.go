@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/mailer"
+)
+
+const testAdminToken = "0123456789abcdef0123456789abcdef"
+
+// mailboxRequest calls the mailbox from the loopback address.
+func mailboxRequest(a *app.App, method, target string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.RemoteAddr = "127.0.0.1:51234"
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handleMailbox(w, r, a)
+	return w
+}
+
+func TestMailbox(t *testing.T) {
+	a, _ := newTestApp(t)
+	_, err := a.Capture.Send(context.Background(), &mailer.Message{
+		From:    mailer.Address{Email: "beta@goalhero.eu"},
+		To:      mailer.Address{Email: "jane@example.com"},
+		Subject: "Welcome",
+		HTML:    "<p>Hi Jane</p>",
+		Text:    "Hi Jane",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := mailboxRequest(a, "GET", "/api/mailbox")
+	var list MailboxResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK || len(list.Messages) != 1 {
+		t.Fatalf("list: %d %s", w.Code, w.Body)
+	}
+	id := list.Messages[0].ID
+
+	w = mailboxRequest(a, "GET", "/api/mailbox?id="+id)
+	var one MailboxResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &one); err != nil || len(one.Messages) != 1 ||
+		one.Messages[0].HTML != "<p>Hi Jane</p>" || one.Messages[0].Text != "Hi Jane" {
+		t.Fatalf("get: %d %s", w.Code, w.Body)
+	}
+	if w := mailboxRequest(a, "GET", "/api/mailbox?id="+id+"&part=text"); w.Body.String() != "Hi Jane" {
+		t.Errorf("text part: %d %q", w.Code, w.Body)
+	}
+	if w := mailboxRequest(a, "GET", "/api/mailbox?id=nope"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown id: %d", w.Code)
+	}
+
+	if w := mailboxRequest(a, "DELETE", "/api/mailbox"); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	if msgs, _ := a.Capture.List(context.Background()); len(msgs) != 0 {
+		t.Errorf("%d messages left after DELETE", len(msgs))
+	}
+	if w := mailboxRequest(a, "POST", "/api/mailbox"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d", w.Code)
+	}
+}
+
+func TestMailboxNotCapturing(t *testing.T) {
+	a, _ := newTestApp(t)
+	a.Capture = nil
+	if w := mailboxRequest(a, "GET", "/api/mailbox"); w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", w.Code)
+	}
+}
+
+func TestMailboxAuthorized(t *testing.T) {
+	// Forwarded headers don't count even where the proxy is trusted.
+	local, _ := newTestApp(t, "TRUST_PROXY=true")
+	admin, _ := newTestApp(t, "ADMIN_TOKEN="+testAdminToken)
+	// config.Parse requires ADMIN_TOKEN on Vercel; the mailbox doesn't
+	// rely on that.
+	vercel, _ := newTestApp(t)
+	vercel.Config.Vercel = true
+	tests := []struct {
+		name       string
+		app        *app.App
+		remoteAddr string
+		header     map[string]string
+		want       bool
+	}{
+		{name: "loopback", app: local, remoteAddr: "127.0.0.1:51234", want: true},
+		{name: "loopback v6", app: local, remoteAddr: "[::1]:51234", want: true},
+		{name: "remote", app: local, remoteAddr: "203.0.113.7:51234"},
+		{name: "remote forwarded as local", app: local, remoteAddr: "203.0.113.7:51234",
+			header: map[string]string{"X-Forwarded-For": "127.0.0.1", "X-Real-IP": "127.0.0.1"}},
+		{name: "local behind a proxy", app: local, remoteAddr: "127.0.0.1:51234",
+			header: map[string]string{"X-Forwarded-For": "203.0.113.7"}, want: true},
+		{name: "remote with token", app: admin, remoteAddr: "203.0.113.7:51234",
+			header: map[string]string{"Authorization": "Bearer " + testAdminToken}, want: true},
+		{name: "loopback without token", app: admin, remoteAddr: "127.0.0.1:51234"},
+		{name: "wrong token", app: admin, remoteAddr: "127.0.0.1:51234",
+			header: map[string]string{"Authorization": "Bearer " + strings.Repeat("x", 32)}},
+		{name: "loopback on vercel", app: vercel, remoteAddr: "127.0.0.1:51234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/mailbox", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := tt.app.MailboxAuthorized(r); got != tt.want {
+				t.Errorf("MailboxAuthorized = %v, want %v", got, tt.want)
+			}
+			w := httptest.NewRecorder()
+			handleMailbox(w, r, tt.app)
+			if (w.Code == http.StatusOK) != tt.want {
+				t.Errorf("status %d", w.Code)
+			}
+		})
+	}
+}
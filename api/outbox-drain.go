@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"goalhero-emailer/pkg/app"
+)
+
+type OutboxDrainResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message,omitempty"`
+	Processed int    `json:"processed"`
+}
+
+// OutboxDrainHandler sends every due outbox job before answering. It is
+// meant for a scheduler such as Vercel Cron, since serverless instances
+// can't be relied on to keep a background worker running, and accepts
+// "Authorization: Bearer $CRON_SECRET" or the admin token.
+func OutboxDrainHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		writeOutboxDrain(w, http.StatusInternalServerError, OutboxDrainResponse{Message: "Service unavailable"})
+		return
+	}
+	handleOutboxDrain(w, r, a)
+}
+
+// handleOutboxDrain drains the outbox of a, which tests build themselves.
+func handleOutboxDrain(w http.ResponseWriter, r *http.Request, a *app.App) {
+	reply := func(status int, resp OutboxDrainResponse) {
+		writeOutboxDrain(w, status, resp)
+	}
+
+	if a.Config.CronSecret == "" && a.Config.AdminToken == "" {
+		reply(http.StatusNotFound, OutboxDrainResponse{Message: "Not found"})
+		return
+	}
+	if !a.CronAuthorized(r) {
+		reply(http.StatusUnauthorized, OutboxDrainResponse{Message: "Unauthorized"})
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" {
+		w.Header().Set("Allow", "GET, POST")
+		reply(http.StatusMethodNotAllowed, OutboxDrainResponse{Message: "Method not allowed"})
+		return
+	}
+
+	n, err := a.Outbox.Drain(r.Context())
+	if err != nil {
+		log.Printf("Error draining outbox: %v", err)
+		reply(http.StatusInternalServerError, OutboxDrainResponse{Message: "Failed to drain outbox", Processed: n})
+		return
+	}
+	reply(http.StatusOK, OutboxDrainResponse{Success: true, Processed: n})
+}
+
+func writeOutboxDrain(w http.ResponseWriter, status int, resp OutboxDrainResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/registration"
+)
+
+const testCronSecret = "fedcba9876543210fedcba9876543210"
+
+func drainRequest(a *app.App, method, token string) (*httptest.ResponseRecorder, OutboxDrainResponse) {
+	r := httptest.NewRequest(method, "/api/outbox-drain", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handleOutboxDrain(w, r, a)
+
+	var resp OutboxDrainResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func TestOutboxDrain(t *testing.T) {
+	a, fake := newTestApp(t, "CRON_SECRET="+testCronSecret)
+	err := a.Registrations.Create(context.Background(), &registration.Registration{Email: "jane@example.com"}, registration.JobWelcome)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, resp := drainRequest(a, "GET", testCronSecret)
+	if w.Code != http.StatusOK || !resp.Success || resp.Processed != 1 {
+		t.Fatalf("drain: %d %s", w.Code, w.Body)
+	}
+	if to := fake.recipients(); len(to) != 1 || to[0] != "jane@example.com" {
+		t.Errorf("sent to %v", to)
+	}
+	if w, resp := drainRequest(a, "POST", testCronSecret); w.Code != http.StatusOK || resp.Processed != 0 {
+		t.Errorf("second drain: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestOutboxDrainAuthorized(t *testing.T) {
+	cron, _ := newTestApp(t, "CRON_SECRET="+testCronSecret)
+	admin, _ := newTestApp(t, "ADMIN_TOKEN="+testAdminToken)
+	both, _ := newTestApp(t, "CRON_SECRET="+testCronSecret, "ADMIN_TOKEN="+testAdminToken)
+	neither, _ := newTestApp(t)
+	tests := []struct {
+		name   string
+		app    *app.App
+		method string
+		token  string
+		status int
+	}{
+		{name: "cron secret", app: cron, method: "GET", token: testCronSecret, status: http.StatusOK},
+		{name: "admin token", app: admin, method: "POST", token: testAdminToken, status: http.StatusOK},
+		{name: "either with both", app: both, method: "GET", token: testAdminToken, status: http.StatusOK},
+		{name: "no token", app: both, method: "GET", status: http.StatusUnauthorized},
+		{name: "wrong token", app: both, method: "GET", token: strings.Repeat("x", 32), status: http.StatusUnauthorized},
+		{name: "admin token as cron secret", app: cron, method: "GET", token: testAdminToken, status: http.StatusUnauthorized},
+		{name: "disabled", app: neither, method: "GET", token: testCronSecret, status: http.StatusNotFound},
+		{name: "method", app: cron, method: "DELETE", token: testCronSecret, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w, _ := drainRequest(tt.app, tt.method, tt.token); w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/registration"
+)
+
+type OutboxResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message,omitempty"`
+	Jobs    []registration.Job `json:"jobs,omitempty"`
+}
+
+// OutboxHandler lets operators inspect queued and dead-lettered emails.
+// It requires "Authorization: Bearer $ADMIN_TOKEN" and is disabled when
+// ADMIN_TOKEN is unset.
+//
+//	GET  /api/outbox[?state=dead]   list jobs
+//	POST /api/outbox?id=...         requeue a job with fresh attempts
+func OutboxHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		writeOutbox(w, http.StatusInternalServerError, OutboxResponse{Message: "Service unavailable"})
+		return
+	}
+	handleOutbox(w, r, a)
+}
+
+// handleOutbox serves the outbox of a, which tests build themselves.
+func handleOutbox(w http.ResponseWriter, r *http.Request, a *app.App) {
+	reply := func(status int, resp OutboxResponse) {
+		writeOutbox(w, status, resp)
+	}
+
+	if a.Config.AdminToken == "" {
+		reply(http.StatusNotFound, OutboxResponse{Message: "Not found"})
+		return
+	}
+	if !a.AdminAuthorized(r) {
+		reply(http.StatusUnauthorized, OutboxResponse{Message: "Unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		jobs, err := a.Registrations.Jobs(r.Context())
+		if err != nil {
+			log.Printf("Error listing outbox: %v", err)
+			reply(http.StatusInternalServerError, OutboxResponse{Message: "Failed to list outbox"})
+			return
+		}
+		if state := registration.JobState(r.URL.Query().Get("state")); state != "" {
+			filtered := jobs[:0]
+			for _, j := range jobs {
+				if j.State == state {
+					filtered = append(filtered, j)
+				}
+			}
+			jobs = filtered
+		}
+		reply(http.StatusOK, OutboxResponse{Success: true, Jobs: jobs})
+
+	case "POST":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			reply(http.StatusBadRequest, OutboxResponse{Message: "Job id is required"})
+			return
+		}
+		err := a.Registrations.RequeueJob(r.Context(), id)
+		switch {
+		case errors.Is(err, registration.ErrJobNotFound):
+			reply(http.StatusNotFound, OutboxResponse{Message: "Job not found"})
+			return
+		case errors.Is(err, registration.ErrJobLeased):
+			reply(http.StatusConflict, OutboxResponse{Message: "Job is being sent"})
+			return
+		case err != nil:
+			log.Printf("Error requeueing outbox job %s: %v", id, err)
+			reply(http.StatusInternalServerError, OutboxResponse{Message: "Failed to requeue job"})
+			return
+		}
+		a.Outbox.Kick()
+		reply(http.StatusOK, OutboxResponse{Success: true, Message: "Job requeued"})
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		reply(http.StatusMethodNotAllowed, OutboxResponse{Message: "Method not allowed"})
+	}
+}
+
+func writeOutbox(w http.ResponseWriter, status int, resp OutboxResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
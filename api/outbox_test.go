@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/registration"
+)
+
+func outboxRequest(a *app.App, method, target, token string) (*httptest.ResponseRecorder, OutboxResponse) {
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handleOutbox(w, r, a)
+
+	var resp OutboxResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+// claimJob signs up email with a queued welcome job, bypassing the
+// handler so nothing sends it, and leases the job.
+func claimJob(t *testing.T, a *app.App, email string) registration.Job {
+	t.Helper()
+	ctx := context.Background()
+	if err := a.Registrations.Create(ctx, &registration.Registration{Email: email}, registration.JobWelcome); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := a.Registrations.ClaimJobsFor(ctx, email, time.Now().UTC(), time.Minute)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("ClaimJobsFor = %+v, %v", jobs, err)
+	}
+	return jobs[0]
+}
+
+func TestOutbox(t *testing.T) {
+	a, _ := newTestApp(t, "ADMIN_TOKEN="+testAdminToken)
+	ctx := context.Background()
+	dead := claimJob(t, a, "jane@example.com")
+	if err := a.Registrations.BuryJob(ctx, dead.ID, "rejected"); err != nil {
+		t.Fatal(err)
+	}
+	leased := claimJob(t, a, "john@example.com")
+
+	w, resp := outboxRequest(a, "GET", "/api/outbox", testAdminToken)
+	if w.Code != http.StatusOK || !resp.Success || len(resp.Jobs) != 2 {
+		t.Fatalf("GET: %d %s", w.Code, w.Body)
+	}
+	w, resp = outboxRequest(a, "GET", "/api/outbox?state=dead", testAdminToken)
+	if w.Code != http.StatusOK || len(resp.Jobs) != 1 || resp.Jobs[0].ID != dead.ID {
+		t.Fatalf("GET dead: %d %s", w.Code, w.Body)
+	}
+
+	w, _ = outboxRequest(a, "POST", "/api/outbox?id="+dead.ID, testAdminToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("requeue: %d %s", w.Code, w.Body)
+	}
+	jobs, _ := a.Registrations.Jobs(ctx)
+	for _, j := range jobs {
+		if j.ID == dead.ID && (j.State != registration.JobQueued || j.Attempts != 0) {
+			t.Errorf("requeued job = %+v, want queued with no attempts", j)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		status int
+	}{
+		{name: "no token", method: "GET", target: "/api/outbox", status: http.StatusUnauthorized},
+		{name: "wrong token", method: "GET", target: "/api/outbox", token: strings.Repeat("x", 32), status: http.StatusUnauthorized},
+		{name: "requeue without id", method: "POST", target: "/api/outbox", token: testAdminToken, status: http.StatusBadRequest},
+		{name: "requeue unknown", method: "POST", target: "/api/outbox?id=nope", token: testAdminToken, status: http.StatusNotFound},
+		{name: "requeue leased", method: "POST", target: "/api/outbox?id=" + leased.ID, token: testAdminToken, status: http.StatusConflict},
+		{name: "method", method: "DELETE", target: "/api/outbox", token: testAdminToken, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w, _ := outboxRequest(a, tt.method, tt.target, tt.token); w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestOutboxDisabled(t *testing.T) {
+	a, _ := newTestApp(t, "CRON_SECRET="+testCronSecret)
+	if w, _ := outboxRequest(a, "GET", "/api/outbox", testCronSecret); w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404 without ADMIN_TOKEN", w.Code)
+	}
+}
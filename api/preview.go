@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"goalhero-emailer/pkg/config"
+	"goalhero-emailer/pkg/i18n"
+	"goalhero-emailer/pkg/templates"
+)
+
+type PreviewResponse struct {
+	Success  bool     `json:"success"`
+	Message  string   `json:"message,omitempty"`
+	Subject  string   `json:"subject,omitempty"`
+	HTML     string   `json:"html,omitempty"`
+	Text     string   `json:"text,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+var previewIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Template previews</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background: #f8fafc; color: #333; padding: 24px; }
+        a { color: #00994d; margin-right: 12px; }
+    </style>
+</head>
+<body>
+    <h1>Template previews</h1>
+    {{range .Templates}}{{$name := .}}<p><strong>{{$name}}</strong>:
+        {{range $.Locales}}<a href="/preview/{{$name}}/{{.}}">{{.}}</a>{{end}}
+    </p>{{end}}
+</body>
+</html>
+`))
+
+// PreviewHandler renders a template with sample data so designers and
+// translators can check it without signing up. It is disabled outside
+// development unless PREVIEWS=true. It only needs the configuration, not
+// a mail transport or stores, so it works before those are set up.
+//
+//	GET /preview                                  index of templates and locales
+//	GET /preview/{template}/{lang}[?format=...]   html (default), text or json
+//
+// On Vercel, /preview/{template}/{lang} is rewritten to
+// /api/preview?template=...&lang=..., which works as well.
+func PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Error loading config: %v", err)
+		writePreview(w, http.StatusInternalServerError, PreviewResponse{Message: "Service unavailable"})
+		return
+	}
+	handlePreview(w, r, cfg)
+}
+
+// handlePreview serves a preview with cfg, which tests build themselves.
+func handlePreview(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	if !cfg.Previews {
+		writePreview(w, http.StatusNotFound, PreviewResponse{Message: "Not found"})
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		writePreview(w, http.StatusMethodNotAllowed, PreviewResponse{Message: "Method not allowed"})
+		return
+	}
+
+	q := r.URL.Query()
+	name, lang := q.Get("template"), q.Get("lang")
+	if name == "" {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/preview"), "/")
+		name, lang, _ = strings.Cut(rest, "/")
+	}
+	if name == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		previewIndex.Execute(w, map[string][]string{"Templates": templates.Names, "Locales": i18n.Supported()})
+		return
+	}
+
+	if !slices.Contains(templates.Names, name) {
+		writePreview(w, http.StatusNotFound, PreviewResponse{Message: "Unknown template " + name + " (have " + strings.Join(templates.Names, ", ") + ")"})
+		return
+	}
+	if lang == "" {
+		lang = i18n.DefaultLocale
+	}
+	locale, err := i18n.Match(lang)
+	var unsupported *i18n.UnsupportedError
+	if errors.As(err, &unsupported) {
+		writePreview(w, http.StatusNotFound, PreviewResponse{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		writePreview(w, http.StatusBadRequest, PreviewResponse{Message: err.Error()})
+		return
+	}
+
+	rendered, err := templates.Render(name, locale, templates.Sample(cfg.SenderFor(locale).Contact()))
+	if err != nil {
+		log.Printf("Error rendering preview: %v", err)
+		writePreview(w, http.StatusInternalServerError, PreviewResponse{Message: err.Error()})
+		return
+	}
+
+	switch q.Get("format") {
+	case "", "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(rendered.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("Subject: " + rendered.Subject + "\n\n" + rendered.Text))
+	case "json":
+		writePreview(w, http.StatusOK, PreviewResponse{
+			Success:  true,
+			Subject:  rendered.Subject,
+			HTML:     rendered.HTML,
+			Text:     rendered.Text,
+			Warnings: rendered.Warnings,
+		})
+	default:
+		writePreview(w, http.StatusBadRequest, PreviewResponse{Message: "format must be html, text or json"})
+	}
+}
+
+func writePreview(w http.ResponseWriter, status int, resp PreviewResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
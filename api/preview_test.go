@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goalhero-emailer/pkg/config"
+)
+
+func newPreviewConfig(t *testing.T, env ...string) *config.Config {
+	t.Helper()
+	cfg, err := config.Parse(append([]string{"FROM_EMAIL=beta@goalhero.eu"}, env...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestPreviewDisabled(t *testing.T) {
+	for _, env := range [][]string{nil, {"VERCEL_ENV=production"}, {"VERCEL_ENV=development", "PREVIEWS=false"}} {
+		w := httptest.NewRecorder()
+		handlePreview(w, httptest.NewRequest("GET", "/preview/welcome/en", nil), newPreviewConfig(t, env...))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%q: status %d, want 404", env, w.Code)
+		}
+	}
+}
+
+func TestPreviewFormats(t *testing.T) {
+	cfg := newPreviewConfig(t, "PREVIEWS=true")
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		want        string
+	}{
+		{name: "html", target: "/preview/welcome/en", contentType: "text/html", want: "<html"},
+		{name: "explicit html", target: "/preview/confirm/es?format=html", contentType: "text/html", want: `lang="es"`},
+		{name: "text", target: "/preview/welcome/en?format=text", contentType: "text/plain", want: "Subject: "},
+		{name: "json", target: "/preview/welcome/en?format=json", contentType: "application/json", want: `"success":true`},
+		{name: "vercel rewrite", target: "/api/preview?template=confirm&lang=es&format=text", contentType: "text/plain", want: "Subject: "},
+		{name: "default locale", target: "/preview/welcome", contentType: "text/html", want: `lang="en"`},
+		{name: "index", target: "/preview", contentType: "text/html", want: `href="/preview/confirm/es"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handlePreview(w, httptest.NewRequest("GET", tt.target, nil), cfg)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("Content-Type %q, want %s", ct, tt.contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body does not contain %q:\n%.300s", tt.want, w.Body)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handlePreview(w, httptest.NewRequest("GET", "/preview/welcome/en?format=json", nil), cfg)
+	var resp PreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Subject == "" || !strings.Contains(resp.HTML, "<html") || resp.Text == "" {
+		t.Errorf("json preview %+v", resp)
+	}
+}
+
+func TestPreviewInvalid(t *testing.T) {
+	cfg := newPreviewConfig(t, "PREVIEWS=true")
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{name: "unknown template", method: "GET", target: "/preview/goodbye/en", status: http.StatusNotFound},
+		{name: "unsupported locale", method: "GET", target: "/preview/welcome/ja", status: http.StatusNotFound},
+		{name: "malformed locale", method: "GET", target: "/preview/welcome/not_a_tag!", status: http.StatusBadRequest},
+		{name: "unknown format", method: "GET", target: "/preview/welcome/en?format=pdf", status: http.StatusBadRequest},
+		{name: "method", method: "POST", target: "/preview/welcome/en", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handlePreview(w, httptest.NewRequest(tt.method, tt.target, nil), cfg)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var resp PreviewResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Success || resp.Message == "" {
+				t.Errorf("body %s: %v", w.Body, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/emailaddr"
+	"goalhero-emailer/pkg/reqbody"
+	"goalhero-emailer/pkg/suppression"
+)
+
+type SuppressionRequest struct {
+	Email  string             `json:"email"`
+	Reason suppression.Reason `json:"reason"`
+}
+
+type SuppressionsResponse struct {
+	Success bool                `json:"success"`
+	Message string              `json:"message,omitempty"`
+	Entries []suppression.Entry `json:"entries,omitempty"`
+}
+
+// SuppressionsHandler lets operators inspect and edit the suppression list.
+// It requires "Authorization: Bearer $ADMIN_TOKEN" and is disabled when
+// ADMIN_TOKEN is unset.
+//
+//	GET    /api/suppressions                list entries
+//	POST   /api/suppressions                add {"email", "reason"}
+//	DELETE /api/suppressions?email=...      remove an entry
+func SuppressionsHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		writeSuppressions(w, http.StatusInternalServerError, SuppressionsResponse{Message: "Service unavailable"})
+		return
+	}
+	handleSuppressions(w, r, a)
+}
+
+// handleSuppressions serves the suppression list of a, which tests build
+// themselves.
+func handleSuppressions(w http.ResponseWriter, r *http.Request, a *app.App) {
+	reply := func(status int, resp SuppressionsResponse) {
+		writeSuppressions(w, status, resp)
+	}
+
+	if a.Config.AdminToken == "" {
+		reply(http.StatusNotFound, SuppressionsResponse{Message: "Not found"})
+		return
+	}
+	if !a.AdminAuthorized(r) {
+		reply(http.StatusUnauthorized, SuppressionsResponse{Message: "Unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		entries, err := a.Suppressions.List(r.Context())
+		if err != nil {
+			log.Printf("Error listing suppressions: %v", err)
+			reply(http.StatusInternalServerError, SuppressionsResponse{Message: "Failed to list suppressions"})
+			return
+		}
+		reply(http.StatusOK, SuppressionsResponse{Success: true, Entries: entries})
+
+	case "POST":
+		var req SuppressionRequest
+		err := reqbody.Decode(w, r, &req, reqbody.Options{MaxBytes: a.Config.MaxBodyBytes, Strict: true})
+		var tooLarge *reqbody.TooLargeError
+		switch {
+		case errors.As(err, &tooLarge):
+			reply(http.StatusRequestEntityTooLarge, SuppressionsResponse{Message: "Request body is too large"})
+			return
+		case errors.Is(err, reqbody.ErrUnsupportedMediaType):
+			reply(http.StatusUnsupportedMediaType, SuppressionsResponse{Message: "Content-Type must be application/json"})
+			return
+		case err != nil:
+			reply(http.StatusBadRequest, SuppressionsResponse{Message: "Invalid request body: " + err.Error()})
+			return
+		}
+		if req.Email == "" {
+			reply(http.StatusBadRequest, SuppressionsResponse{Message: "Email is required"})
+			return
+		}
+		email, err := suppressionAddress(r.Context(), req.Email)
+		if err != nil {
+			reply(http.StatusBadRequest, SuppressionsResponse{Message: "Email is not a valid address"})
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = suppression.ReasonManual
+		}
+		if !req.Reason.Valid() {
+			reply(http.StatusBadRequest, SuppressionsResponse{Message: "Reason must be one of: unsubscribed, bounced, complained, manual"})
+			return
+		}
+		entry := suppression.Entry{Email: email, Reason: req.Reason, CreatedAt: time.Now().UTC()}
+		if err := a.Suppressions.Add(r.Context(), entry); err != nil {
+			log.Printf("Error adding suppression: %v", err)
+			reply(http.StatusInternalServerError, SuppressionsResponse{Message: "Failed to add suppression"})
+			return
+		}
+		reply(http.StatusOK, SuppressionsResponse{Success: true, Message: "Suppression added"})
+
+	case "DELETE":
+		email := r.URL.Query().Get("email")
+		if email == "" {
+			reply(http.StatusBadRequest, SuppressionsResponse{Message: "Email is required"})
+			return
+		}
+		// Entries from before addresses were checked may not parse; they
+		// are removed as given.
+		if normalized, err := suppressionAddress(r.Context(), email); err == nil {
+			email = normalized
+		}
+		if err := a.Suppressions.Remove(r.Context(), email); err != nil {
+			log.Printf("Error removing suppression: %v", err)
+			reply(http.StatusInternalServerError, SuppressionsResponse{Message: "Failed to remove suppression"})
+			return
+		}
+		reply(http.StatusOK, SuppressionsResponse{Success: true, Message: "Suppression removed"})
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		reply(http.StatusMethodNotAllowed, SuppressionsResponse{Message: "Method not allowed"})
+	}
+}
+
+// suppressionAddress checks the syntax of an address an operator entered
+// and returns it the way the filter looks it up: trimmed, case-folded and
+// with an IDN domain in punycode, as signups are sent to it. Disposable
+// and undeliverable domains are accepted, since those are worth
+// suppressing too.
+func suppressionAddress(ctx context.Context, input string) (string, error) {
+	email, err := (&emailaddr.Validator{}).Validate(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return emailaddr.Normalize(email, false), nil
+}
+
+func writeSuppressions(w http.ResponseWriter, status int, resp SuppressionsResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/suppression"
+)
+
+func suppressionsRequest(a *app.App, method, target, token, body string) (*httptest.ResponseRecorder, SuppressionsResponse) {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handleSuppressions(w, r, a)
+
+	var resp SuppressionsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func TestSuppressions(t *testing.T) {
+	a, _ := newTestApp(t, "ADMIN_TOKEN="+testAdminToken)
+	ctx := context.Background()
+
+	w, _ := suppressionsRequest(a, "POST", "/api/suppressions", testAdminToken, `{"email": " Jane@Example.COM ", "reason": "bounced"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST: %d %s", w.Code, w.Body)
+	}
+	e, err := a.Suppressions.Get(ctx, "jane@example.com")
+	if err != nil || e == nil || e.Email != "jane@example.com" || e.Reason != suppression.ReasonBounced {
+		t.Fatalf("entry after POST: %+v, %v", e, err)
+	}
+
+	// The reason defaults to manual, and IDN domains are stored as sent.
+	if w, _ := suppressionsRequest(a, "POST", "/api/suppressions", testAdminToken, `{"email": "john@Bücher.de"}`); w.Code != http.StatusOK {
+		t.Fatalf("POST: %d %s", w.Code, w.Body)
+	}
+	w, resp := suppressionsRequest(a, "GET", "/api/suppressions", testAdminToken, "")
+	if w.Code != http.StatusOK || !resp.Success || len(resp.Entries) != 2 {
+		t.Fatalf("GET: %d %s", w.Code, w.Body)
+	}
+	if got := resp.Entries[1]; got.Email != "john@xn--bcher-kva.de" || got.Reason != suppression.ReasonManual {
+		t.Errorf("second entry = %+v", got)
+	}
+
+	w, _ = suppressionsRequest(a, "DELETE", "/api/suppressions?email=JANE@example.com", testAdminToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE: %d %s", w.Code, w.Body)
+	}
+	if e, _ := a.Suppressions.Get(ctx, "jane@example.com"); e != nil {
+		t.Errorf("entry after DELETE: %+v", e)
+	}
+}
+
+func TestSuppressionsInvalid(t *testing.T) {
+	a, _ := newTestApp(t, "ADMIN_TOKEN="+testAdminToken)
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   string
+		status int
+	}{
+		{name: "no token", method: "GET", target: "/api/suppressions", status: http.StatusUnauthorized},
+		{name: "wrong token", method: "GET", target: "/api/suppressions", token: strings.Repeat("x", 32), status: http.StatusUnauthorized},
+		{name: "no email", method: "POST", target: "/api/suppressions", token: testAdminToken, body: `{"reason": "manual"}`, status: http.StatusBadRequest},
+		{name: "invalid email", method: "POST", target: "/api/suppressions", token: testAdminToken, body: `{"email": "jane"}`, status: http.StatusBadRequest},
+		{name: "display name", method: "POST", target: "/api/suppressions", token: testAdminToken, body: `{"email": "Jane <jane@example.com>"}`, status: http.StatusBadRequest},
+		{name: "invalid reason", method: "POST", target: "/api/suppressions", token: testAdminToken, body: `{"email": "jane@example.com", "reason": "spite"}`, status: http.StatusBadRequest},
+		{name: "delete without email", method: "DELETE", target: "/api/suppressions", token: testAdminToken, status: http.StatusBadRequest},
+		{name: "method", method: "PUT", target: "/api/suppressions", token: testAdminToken, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w, _ := suppressionsRequest(a, tt.method, tt.target, tt.token, tt.body); w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+	if entries, _ := a.Suppressions.List(context.Background()); len(entries) != 0 {
+		t.Errorf("suppressed %+v", entries)
+	}
+}
+
+func TestSuppressionsDisabled(t *testing.T) {
+	a, _ := newTestApp(t)
+	if w, _ := suppressionsRequest(a, "GET", "/api/suppressions", testAdminToken, ""); w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404 without ADMIN_TOKEN", w.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"goalhero-emailer/pkg/app"
+	"goalhero-emailer/pkg/i18n"
+	"goalhero-emailer/pkg/pages"
+	"goalhero-emailer/pkg/suppression"
+	"goalhero-emailer/pkg/unsubscribe"
+)
+
+// UnsubscribeHandler serves the link in every email's footer and the
+// List-Unsubscribe header. GET shows a confirmation form so link scanners
+// can't unsubscribe anyone; POST records the suppression. Mail clients
+// doing RFC 8058 one-click unsubscribe POST directly.
+func UnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := app.Load()
+	if err != nil {
+		log.Printf("Error loading app: %v", err)
+		locale := negotiateLocale(r.Header.Get("Accept-Language"))
+		l := i18n.New(locale)
+		pages.Render(w, http.StatusInternalServerError, pages.Page{
+			Locale:  locale,
+			Title:   l.T("unsubscribe.title"),
+			Heading: l.T("unsubscribe.title"),
+			Text:    l.T("unsubscribe.error_text"),
+		})
+		return
+	}
+	handleUnsubscribe(w, r, a)
+}
+
+// handleUnsubscribe serves an unsubscribe with a, which tests build
+// themselves.
+func handleUnsubscribe(w http.ResponseWriter, r *http.Request, a *app.App) {
+	locale := negotiateLocale(r.Header.Get("Accept-Language"))
+	l := i18n.New(locale)
+	page := pages.Page{Locale: locale, Title: l.T("unsubscribe.title")}
+
+	render := func(status int, heading, text string) {
+		page.Heading, page.Text = heading, text
+		pages.Render(w, status, page)
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" && r.Method != "POST" {
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		render(http.StatusMethodNotAllowed, l.T("unsubscribe.invalid_heading"), l.T("unsubscribe.invalid_text"))
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if a.Signer == nil || token == "" {
+		render(http.StatusBadRequest, l.T("unsubscribe.invalid_heading"), l.T("unsubscribe.invalid_text"))
+		return
+	}
+	email, err := unsubscribe.Verify(a.Signer, token)
+	if err != nil {
+		render(http.StatusBadRequest, l.T("unsubscribe.invalid_heading"), l.T("unsubscribe.invalid_text"))
+		return
+	}
+
+	if r.Method != "POST" {
+		page.Button = l.T("unsubscribe.button")
+		page.Action = unsubscribe.Path + "?token=" + url.QueryEscape(token)
+		render(http.StatusOK, l.T("unsubscribe.confirm_heading"), l.Format("unsubscribe.confirm_text", "email", email))
+		return
+	}
+
+	err = a.Suppressions.Add(r.Context(), suppression.Entry{
+		Email:     email,
+		Reason:    suppression.ReasonUnsubscribed,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Error recording unsubscribe: %v", err)
+		render(http.StatusInternalServerError, l.T("unsubscribe.title"), l.T("unsubscribe.error_text"))
+		return
+	}
+
+	render(http.StatusOK, l.T("unsubscribe.done_heading"), l.Format("unsubscribe.done_text", "email", email))
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goalhero-emailer/pkg/suppression"
+	"goalhero-emailer/pkg/unsubscribe"
+)
+
+func TestUnsubscribe(t *testing.T) {
+	a, _ := newTestApp(t, "SIGNING_SECRET=0123456789abcdef0123456789abcdef")
+	ctx := context.Background()
+	target := unsubscribe.Path + "?token=" + unsubscribe.Token(a.Signer, "jane@example.com")
+
+	// Opening the link, as a scanner would, only shows the form.
+	w := httptest.NewRecorder()
+	handleUnsubscribe(w, httptest.NewRequest("GET", target, nil), a)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<form method="post"`) {
+		t.Fatalf("GET: %d %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `action="`+target+`"`) {
+		t.Errorf("form doesn't post back to %s: %s", target, w.Body)
+	}
+	if e, err := a.Suppressions.Get(ctx, "jane@example.com"); err != nil || e != nil {
+		t.Fatalf("GET suppressed the address: %+v, %v", e, err)
+	}
+
+	// RFC 8058 one-click: the mail client POSTs straight to the header's URL.
+	r := httptest.NewRequest("POST", target, strings.NewReader("List-Unsubscribe=One-Click"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handleUnsubscribe(w, r, a)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST: %d %s", w.Code, w.Body)
+	}
+	e, err := a.Suppressions.Get(ctx, "jane@example.com")
+	if err != nil || e == nil || e.Reason != suppression.ReasonUnsubscribed {
+		t.Fatalf("suppression after POST: %+v, %v", e, err)
+	}
+}
+
+func TestUnsubscribeInvalid(t *testing.T) {
+	a, _ := newTestApp(t, "SIGNING_SECRET=0123456789abcdef0123456789abcdef")
+	token := unsubscribe.Token(a.Signer, "jane@example.com")
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{name: "no token", method: "POST", target: unsubscribe.Path, status: http.StatusBadRequest},
+		{name: "tampered", method: "POST", target: unsubscribe.Path + "?token=x" + token, status: http.StatusBadRequest},
+		{name: "method", method: "PUT", target: unsubscribe.Path + "?token=" + token, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleUnsubscribe(w, httptest.NewRequest(tt.method, tt.target, nil), a)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+	if entries, _ := a.Suppressions.List(context.Background()); len(entries) != 0 {
+		t.Errorf("suppressed %+v", entries)
+	}
+}
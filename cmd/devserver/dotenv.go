@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadEnv sets the variables in a dotenv file that are not already set
+// in the environment. Lines are KEY=VALUE, optionally prefixed with
+// "export"; values may be single quoted (taken literally) or double
+// quoted (with Go escapes), and # starts a comment outside quotes.
+func loadEnv(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("line %d: want KEY=VALUE", n)
+		}
+		value, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	return sc.Err()
+}
+
+func parseValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, "'"):
+		end := strings.Index(v[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return v[1 : end+1], nil
+	case strings.HasPrefix(v, `"`):
+		prefix, err := strconv.QuotedPrefix(v)
+		if err != nil {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return strconv.Unquote(prefix)
+	default:
+		if i := strings.Index(v, " #"); i >= 0 {
+			v = v[:i]
+		}
+		return strings.TrimSpace(v), nil
+	}
+}
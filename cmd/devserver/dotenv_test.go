@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+		err            bool
+	}{
+		{name: "plain", in: "smtp.example.com", want: "smtp.example.com"},
+		{name: "empty", in: "", want: ""},
+		{name: "comment", in: "587 # submission port", want: "587"},
+		{name: "hash without space", in: "pa#ss", want: "pa#ss"},
+		{name: "single quoted", in: `'a b # not a comment \n'`, want: `a b # not a comment \n`},
+		{name: "single quoted comment", in: "'a b' # comment", want: "a b"},
+		{name: "double quoted escapes", in: `"line\n\"quoted\" # kept"`, want: "line\n\"quoted\" # kept"},
+		{name: "double quoted comment", in: `"GoalHero Team" # sender`, want: "GoalHero Team"},
+		{name: "empty quotes", in: `""`, want: ""},
+		{name: "unterminated single", in: "'abc", err: true},
+		{name: "unterminated double", in: `"abc`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseValue(tt.in)
+			if tt.err {
+				if err == nil {
+					t.Errorf("parseValue(%q) = %q, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseValue(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// writeEnv writes a dotenv file and unsets its keys after the test.
+func writeEnv(t *testing.T, content string, keys ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		t.Cleanup(func() { os.Unsetenv(k) })
+	}
+	return path
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("DOTENV_TEST_SET", "from the environment")
+	t.Setenv("DOTENV_TEST_EMPTY", "")
+	path := writeEnv(t, `
+# a comment
+
+DOTENV_TEST_PLAIN=plain value # trailing comment
+export DOTENV_TEST_EXPORTED=exported
+  DOTENV_TEST_SPACED = spaced
+DOTENV_TEST_SINGLE='single # quoted'
+DOTENV_TEST_DOUBLE="double\tquoted"
+DOTENV_TEST_SET=from the file
+DOTENV_TEST_EMPTY=from the file
+`, "DOTENV_TEST_PLAIN", "DOTENV_TEST_EXPORTED", "DOTENV_TEST_SPACED", "DOTENV_TEST_SINGLE", "DOTENV_TEST_DOUBLE")
+
+	if err := loadEnv(path); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"DOTENV_TEST_PLAIN":    "plain value",
+		"DOTENV_TEST_EXPORTED": "exported",
+		"DOTENV_TEST_SPACED":   "spaced",
+		"DOTENV_TEST_SINGLE":   "single # quoted",
+		"DOTENV_TEST_DOUBLE":   "double\tquoted",
+		// Variables already set win, even when empty.
+		"DOTENV_TEST_SET":   "from the environment",
+		"DOTENV_TEST_EMPTY": "",
+	} {
+		if got := os.Getenv(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoadEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name, content, err string
+	}{
+		{name: "no equals", content: "DOTENV_TEST_A=1\nNOT_A_SETTING\n", err: "line 2: want KEY=VALUE"},
+		{name: "no key", content: "=value\n", err: "line 1: want KEY=VALUE"},
+		{name: "space in key", content: "# comment\nDOTENV TEST=1\n", err: "line 2: want KEY=VALUE"},
+		{name: "unterminated quote", content: "\nDOTENV_TEST_A='open\n", err: "line 2: unterminated quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeEnv(t, tt.content, "DOTENV_TEST_A")
+			err := loadEnv(path)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("loadEnv = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if err := loadEnv(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: %v", err)
+	}
+}
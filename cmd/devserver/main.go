@@ -0,0 +1,140 @@
+// Command devserver serves the api/ handlers on a plain net/http server,
+// so the service can be run and debugged without the Vercel CLI. It loads
+// .env.local first, like `vercel dev`, and runs the outbox worker in the
+// background. The render subcommand prints a template instead.
+//
+//	go run ./cmd/devserver [-addr :3000] [-env .env.local]
+//	go run ./cmd/devserver render [-o file] [-text] [-env file] <template> [lang]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	handler "goalhero-emailer/api"
+	"goalhero-emailer/pkg/app"
+)
+
+// routes mirrors the serverless functions in api/; add new endpoints here
+// too.
+var routes = map[string]http.HandlerFunc{
+	"/api/beta-register":  handler.Handler,
+	"/api/beta-confirm":   handler.BetaConfirmHandler,
+	"/api/form-token":     handler.FormTokenHandler,
+	"/api/unsubscribe":    handler.UnsubscribeHandler,
+	"/api/suppressions":   handler.SuppressionsHandler,
+	"/api/outbox":         handler.OutboxHandler,
+	"/api/outbox-drain":   handler.OutboxDrainHandler,
+	"/api/mail-providers": handler.MailProvidersHandler,
+	"/api/mailbox":        handler.MailboxHandler,
+	"/api/preview":        handler.PreviewHandler,
+	// Rewritten to /api/preview by vercel.json.
+	"/preview":  handler.PreviewHandler,
+	"/preview/": handler.PreviewHandler,
+}
+
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(render(os.Args[2:]))
+	}
+
+	addr := flag.String("addr", envOr("ADDR", ":3000"), "listen address")
+	envFile := flag.String("env", ".env.local", "dotenv file to load; variables already set win")
+	flag.Parse()
+
+	if err := loadEnv(*envFile); err != nil {
+		if !errors.Is(err, os.ErrNotExist) || isFlagSet("env") {
+			log.Fatalf("Error loading %s: %v", *envFile, err)
+		}
+	} else {
+		log.Printf("Loaded %s", *envFile)
+	}
+	// Template previews are off by default outside development.
+	if _, ok := os.LookupEnv("PREVIEWS"); !ok {
+		os.Setenv("PREVIEWS", "true")
+	}
+
+	// Report configuration errors now rather than on the first request.
+	a, err := app.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	for path, h := range routes {
+		mux.Handle(path, h)
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           logRequests(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start the worker before the first request can kick it.
+	outboxDone := a.Outbox.Start(ctx)
+
+	go func() {
+		log.Printf("Listening on %s", *addr)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Print("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down: %v", err)
+	}
+	<-outboxDone
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
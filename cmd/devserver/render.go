@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"goalhero-emailer/pkg/config"
+	"goalhero-emailer/pkg/i18n"
+	"goalhero-emailer/pkg/templates"
+)
+
+// render implements the render subcommand: it renders a template with
+// sample data and the configured contact address, and writes the HTML (or
+// text part) to stdout or a file. The subject and any CSS warnings go to
+// stderr.
+func render(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	out := fs.String("o", "", "write to this file instead of stdout")
+	text := fs.Bool("text", false, "write the plain-text part instead of HTML")
+	envFile := fs.String("env", ".env.local", "dotenv file to load for the sender settings; variables already set win")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: devserver render [-o file] [-text] [-env file] <template> [lang]\n\n")
+		fmt.Fprintf(fs.Output(), "templates: %s\nlocales:   %s\n\n", strings.Join(templates.Names, ", "), strings.Join(i18n.Supported(), ", "))
+		fs.PrintDefaults()
+	}
+	// Allow flags after the arguments too.
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(pos) < 1 || len(pos) > 2 {
+		fs.Usage()
+		return 2
+	}
+
+	lang := i18n.DefaultLocale
+	if len(pos) == 2 {
+		lang = pos[1]
+	}
+	locale, err := i18n.Match(lang)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := loadEnv(*envFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", *envFile, err)
+		return 1
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	rendered, err := templates.Render(pos[0], locale, templates.Sample(cfg.SenderFor(locale).Contact()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	body := rendered.HTML
+	if *text {
+		body = rendered.Text
+	}
+	fmt.Fprintf(os.Stderr, "Subject: %s\n", rendered.Subject)
+	if *out == "" {
+		fmt.Print(body)
+		return 0
+	}
+	if err := os.WriteFile(*out, []byte(body), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *out)
+	return 0
+}
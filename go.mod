@@ -4,10 +4,13 @@ go 1.23.0
 
 toolchain go1.23.10
 
-require github.com/sendgrid/sendgrid-go v3.16.1+incompatible
+require (
+	github.com/sendgrid/sendgrid-go v3.16.1+incompatible
+	golang.org/x/net v0.42.0
+)
 
 require (
 	github.com/sendgrid/rest v2.6.9+incompatible // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
-	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
 )
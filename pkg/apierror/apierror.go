@@ -0,0 +1,106 @@
+// Package apierror defines the error body returned by the JSON endpoints.
+// Clients branch on the codes, which are stable; messages are localized
+// and meant for display only. Breaking changes to the shape bump Version.
+package apierror
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"goalhero-emailer/pkg/i18n"
+)
+
+// Version is the schema version reported in every error body.
+const Version = 1
+
+type Code string
+
+// Request-level codes.
+const (
+	CodeValidationFailed   Code = "validation_failed"
+	CodeInvalidBody        Code = "invalid_body"
+	CodeBodyTooLarge       Code = "body_too_large"
+	CodeUnsupportedMedia   Code = "unsupported_media_type"
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeOriginNotAllowed   Code = "origin_not_allowed"
+	CodeIdempotencyPending Code = "idempotency_in_progress"
+	CodeIdempotencyReused  Code = "idempotency_key_reused"
+	CodeRateLimited        Code = "rate_limited"
+	CodeInternal           Code = "internal_error"
+)
+
+// Field-level codes, reported in Error.Fields.
+const (
+	CodeEmailRequired       Code = "email_required"
+	CodeEmailInvalid        Code = "email_invalid"
+	CodeEmailTooLong        Code = "email_too_long"
+	CodeEmailDisposable     Code = "email_disposable"
+	CodeEmailUndeliverable  Code = "email_undeliverable"
+	CodeLanguageUnsupported Code = "language_unsupported"
+	CodeIdempotencyKey      Code = "idempotency_key_invalid"
+	CodeUnknownField        Code = "unknown_field"
+	CodeCaptchaExpired      Code = "captcha_expired"
+)
+
+// FieldError describes a problem with one request field. Field is the JSON
+// name, or the header name for headers.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Version   int          `json:"version"`
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New returns an error with the message for code in l's language. Passing
+// fields with a zero code yields CodeValidationFailed.
+func New(l *i18n.Localizer, code Code, fields ...FieldError) *Error {
+	if code == "" {
+		code = CodeValidationFailed
+	}
+	return &Error{
+		Version: Version,
+		Code:    code,
+		Message: Message(l, code),
+		Fields:  fields,
+	}
+}
+
+// Field returns a FieldError whose message is formatted with args, given
+// as name/value pairs like i18n.Localizer.Format.
+func Field(l *i18n.Localizer, field string, code Code, args ...any) FieldError {
+	return FieldError{Field: field, Code: code, Message: l.Format("errors."+string(code), args...)}
+}
+
+// Message returns the localized message for code.
+func Message(l *i18n.Localizer, code Code) string {
+	return l.T("errors." + string(code))
+}
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{8,128}$`)
+
+// RequestID returns the caller's X-Request-ID when it looks sane, so IDs
+// can be correlated across services, and a fresh random ID otherwise.
+func RequestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID.MatchString(id) {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,210 @@
+// Package app wires configuration, transports and stores together. Every
+// endpoint in api/ shares the same lazily built App, so a serverless
+// instance pays the setup cost once rather than per request.
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"goalhero-emailer/pkg/captcha"
+	"goalhero-emailer/pkg/config"
+	"goalhero-emailer/pkg/cors"
+	"goalhero-emailer/pkg/docstore"
+	"goalhero-emailer/pkg/emailaddr"
+	"goalhero-emailer/pkg/idempotency"
+	"goalhero-emailer/pkg/mailer"
+	"goalhero-emailer/pkg/outbox"
+	"goalhero-emailer/pkg/ratelimit"
+	"goalhero-emailer/pkg/redis"
+	"goalhero-emailer/pkg/registration"
+	"goalhero-emailer/pkg/signing"
+	"goalhero-emailer/pkg/suppression"
+)
+
+type App struct {
+	Config *config.Config
+	// Mailer sends through Providers, retrying transient failures, behind
+	// a suppression check.
+	Mailer mailer.Mailer
+	// Providers holds the configured transports and their health.
+	Providers *mailer.Failover
+	// Capture holds the messages kept instead of sent with
+	// MAILER=capture, and is nil otherwise.
+	Capture *mailer.Capture
+	// Signer is nil when SIGNING_SECRET is unset; links that need a
+	// signature (unsubscribe) are then left out of emails.
+	Signer *signing.Signer
+	// The stores live in Redis when REDIS_URL is set, so every instance
+	// shares them, and in DATA_DIR otherwise.
+	Suppressions  suppression.Store
+	Registrations registration.Store
+	Idempotency   idempotency.Store
+	// Validator checks signup addresses before they are stored.
+	Validator *emailaddr.Validator
+	// RateLimits holds the token buckets, shared across instances when
+	// REDIS_URL is set.
+	RateLimits ratelimit.Store
+	// Captcha is nil when CAPTCHA_PROVIDER is unset.
+	Captcha captcha.Verifier
+	// CORS applies the origin allowlist for browser-facing endpoints.
+	CORS *cors.Middleware
+	// Outbox sends the emails queued in Registrations.
+	Outbox *outbox.Worker
+}
+
+var (
+	loadOnce sync.Once
+	loaded   *App
+	loadErr  error
+)
+
+// Load builds the App from the environment once per process.
+func Load() (*App, error) {
+	loadOnce.Do(func() {
+		cfg, err := config.Load()
+		if err != nil {
+			loadErr = err
+			return
+		}
+		loaded, loadErr = New(cfg)
+	})
+	return loaded, loadErr
+}
+
+// New builds an App from cfg.
+func New(cfg *config.Config) (*App, error) {
+	providers, err := newProviders(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// One Redis connection serves the rate limits and the stores.
+	var rdb *redis.Client
+	if cfg.RedisURL != "" {
+		if rdb, err = redis.New(cfg.RedisURL); err != nil {
+			return nil, err
+		}
+	}
+	doc := func(name string) (docstore.Store, error) {
+		if rdb != nil {
+			return docstore.NewRedis(rdb, "goalhero:"+name), nil
+		}
+		return docstore.NewFile(filepath.Join(cfg.DataDir, name+".json"))
+	}
+
+	suppressionDoc, err := doc("suppressions")
+	if err != nil {
+		return nil, err
+	}
+	suppressions := suppression.NewDocument(suppressionDoc)
+
+	// Registrations are written on every signup, so Redis keeps each one
+	// apart rather than in a single document.
+	var registrations registration.Store
+	if rdb != nil {
+		registrations = registration.NewRedis(rdb)
+	} else {
+		registrationDoc, err := doc("registrations")
+		if err != nil {
+			return nil, err
+		}
+		registrations = registration.NewDocument(registrationDoc)
+	}
+
+	idemDoc, err := doc("idempotency")
+	if err != nil {
+		return nil, err
+	}
+
+	a := &App{
+		Config:        cfg,
+		Mailer:        suppression.Filter(suppressions, withRetry(providers, cfg.SendRetry)),
+		Providers:     providers,
+		Suppressions:  suppressions,
+		Registrations: registrations,
+		Idempotency:   idempotency.NewDocument(idemDoc),
+		Validator:     &emailaddr.Validator{BlockDisposable: cfg.BlockDisposable},
+	}
+	a.Capture, _ = providers.Lookup("capture").(*mailer.Capture)
+	if a.CORS, err = cors.New(cfg.CORSOrigins, cfg.CORSMaxAge); err != nil {
+		return nil, err
+	}
+	if rdb != nil {
+		a.RateLimits = ratelimit.NewRedis(rdb)
+	} else {
+		a.RateLimits = ratelimit.NewMemory()
+	}
+	a.Outbox = &outbox.Worker{
+		Store:       registrations,
+		Deliver:     a.deliver,
+		Classify:    mailer.Classify,
+		Policy:      cfg.Outbox,
+		Sync:        cfg.OutboxSync,
+		SyncTimeout: cfg.OutboxSyncTimeout,
+	}
+	if cfg.Bots.CaptchaProvider != "" {
+		if a.Captcha, err = captcha.New(cfg.Bots.CaptchaProvider, cfg.Bots.CaptchaSecret); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ValidateMX {
+		a.Validator.Resolver = net.DefaultResolver
+	}
+	if cfg.SigningSecret != "" {
+		a.Signer = signing.New(cfg.SigningSecret)
+	}
+	return a, nil
+}
+
+// Throttle takes a request from the bucket for key and returns how long
+// the caller must wait, or zero if the request may proceed. A failing
+// backend lets requests through: losing rate limiting briefly is better
+// than losing signups.
+func (a *App) Throttle(ctx context.Context, key string, limit ratelimit.Limit) time.Duration {
+	if !limit.Enabled() {
+		return 0
+	}
+	wait, err := a.RateLimits.Take(ctx, key, limit, time.Now())
+	if err != nil {
+		log.Printf("Error checking rate limit %s: %v", key, err)
+		return 0
+	}
+	return wait
+}
+
+// AdminAuthorized reports whether r carries "Authorization: Bearer
+// $ADMIN_TOKEN". It is always false when ADMIN_TOKEN is unset.
+func (a *App) AdminAuthorized(r *http.Request) bool {
+	return bearer(r, a.Config.AdminToken)
+}
+
+// MailboxAuthorized reports whether r may use the dev mailbox: with the
+// admin token or, when ADMIN_TOKEN is unset, from this machine. The peer
+// address decides, never forwarded headers. On Vercel, whose runtime
+// connects from localhost, only the admin token will do.
+func (a *App) MailboxAuthorized(r *http.Request) bool {
+	if a.Config.AdminToken != "" || a.Config.Vercel {
+		return a.AdminAuthorized(r)
+	}
+	ip := net.ParseIP(ratelimit.ClientAddr(r, false))
+	return ip != nil && ip.IsLoopback()
+}
+
+// CronAuthorized reports whether r comes from the scheduler (Bearer
+// $CRON_SECRET) or an operator.
+func (a *App) CronAuthorized(r *http.Request) bool {
+	return bearer(r, a.Config.CronSecret) || a.AdminAuthorized(r)
+}
+
+func bearer(r *http.Request, secret string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
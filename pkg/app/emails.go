@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"goalhero-emailer/pkg/confirm"
+	"goalhero-emailer/pkg/mailer"
+	"goalhero-emailer/pkg/templates"
+	"goalhero-emailer/pkg/unsubscribe"
+)
+
+// SendWelcome renders and sends the welcome email, returning the provider
+// message ID.
+func (a *App) SendWelcome(ctx context.Context, email, locale string) (string, error) {
+	return a.send(ctx, "welcome", email, locale, templates.Data{})
+}
+
+// SendConfirmation sends the double opt-in email whose link confirms the
+// registration with key until expires.
+func (a *App) SendConfirmation(ctx context.Context, email, key, locale string, expires time.Time) (string, error) {
+	if a.Signer == nil || a.Config.BaseURL == "" {
+		return "", errors.New("confirmation links need PUBLIC_BASE_URL and SIGNING_SECRET")
+	}
+	data := templates.Data{
+		ConfirmURL:   confirm.URL(a.Signer, a.Config.BaseURL, key, expires),
+		ConfirmHours: int(a.Config.ConfirmTTL.Hours()),
+	}
+	return a.send(ctx, "confirm", email, locale, data)
+}
+
+func (a *App) send(ctx context.Context, name, email, locale string, data templates.Data) (string, error) {
+	sender := a.Config.SenderFor(locale)
+	msg := &mailer.Message{
+		From: mailer.Address{Name: sender.Name, Email: sender.Email},
+		To:   mailer.Address{Email: email},
+	}
+	if sender.ReplyTo != "" {
+		msg.ReplyTo = &mailer.Address{Email: sender.ReplyTo}
+	}
+
+	data.ContactEmail = sender.Contact()
+	if a.Signer != nil && a.Config.BaseURL != "" {
+		data.UnsubscribeURL = unsubscribe.URL(a.Signer, a.Config.BaseURL, email)
+		msg.Headers = unsubscribe.Headers(a.Signer, a.Config.BaseURL, email)
+	}
+
+	rendered, err := templates.Render(name, locale, data)
+	if err != nil {
+		return "", err
+	}
+	msg.Subject = rendered.Subject
+	msg.HTML = rendered.HTML
+	msg.Text = rendered.Text
+
+	id, err := a.Mailer.Send(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%s email sent (message id %q)", name, id)
+	return id, nil
+}
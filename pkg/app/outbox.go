@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"goalhero-emailer/pkg/outbox"
+	"goalhero-emailer/pkg/registration"
+	"goalhero-emailer/pkg/suppression"
+)
+
+// deliver sends the email for an outbox job. Jobs whose registration is
+// gone, has expired or no longer needs the email are dropped.
+func (a *App) deliver(ctx context.Context, job registration.Job) (registration.EmailStatus, string, error) {
+	reg, err := a.Registrations.Get(ctx, job.Key)
+	if errors.Is(err, registration.ErrNotFound) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	var messageID string
+	status := registration.EmailSent
+	switch job.Kind {
+	case registration.JobConfirm:
+		if reg.State != registration.StatePending || reg.ExpiresAt == nil || reg.Expired(time.Now()) {
+			return "", "", nil
+		}
+		messageID, err = a.SendConfirmation(ctx, reg.Email, reg.Key, reg.Language, *reg.ExpiresAt)
+		// EmailStatus tracks the welcome email, which hasn't been sent yet.
+		status = registration.EmailPending
+	case registration.JobWelcome:
+		messageID, err = a.SendWelcome(ctx, reg.Email, reg.Language)
+	default:
+		return "", "", outbox.Permanent(fmt.Errorf("unknown job kind %q", job.Kind))
+	}
+
+	if errors.Is(err, suppression.ErrSuppressed) {
+		log.Printf("Skipped email: %v", err)
+		return registration.EmailSuppressed, "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return status, messageID, nil
+}
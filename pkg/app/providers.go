@@ -0,0 +1,50 @@
+package app
+
+import (
+	"fmt"
+
+	"goalhero-emailer/pkg/config"
+	"goalhero-emailer/pkg/mailer"
+)
+
+// newProviders builds the transports listed in cfg.MailProviders, in
+// failover order, each behind a circuit breaker.
+func newProviders(cfg *config.Config) (*mailer.Failover, error) {
+	var providers []mailer.Provider
+	for _, name := range cfg.MailProviders {
+		switch name {
+		case "smtp":
+			providers = append(providers, mailer.Provider{Name: name, Mailer: mailer.NewSMTP(mailer.SMTPConfig{
+				Host:     cfg.SMTP.Host,
+				Port:     cfg.SMTP.Port,
+				Username: cfg.SMTP.Username,
+				Password: cfg.SMTP.Password,
+				TLSMode:  mailer.TLSMode(cfg.SMTP.TLS),
+				Timeout:  cfg.SMTP.Timeout,
+			})})
+		case "sendgrid":
+			providers = append(providers, mailer.Provider{Name: name, Mailer: mailer.NewSendGrid(cfg.SendGridAPIKey)})
+		case "capture":
+			capture, err := mailer.NewCapture(cfg.CaptureDir)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, mailer.Provider{Name: name, Mailer: capture})
+		default:
+			return nil, fmt.Errorf("unknown mail provider %q", name)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, mailer.ErrNotConfigured
+	}
+	return mailer.NewFailover(cfg.MailBreaker.Threshold, cfg.MailBreaker.Cooldown, providers...), nil
+}
+
+// withRetry wraps m to resend after transient failures as configured by
+// retry; m is returned as is when retries are disabled.
+func withRetry(m mailer.Mailer, retry config.SendRetry) mailer.Mailer {
+	if retry.Attempts <= 1 {
+		return m
+	}
+	return &mailer.Retry{Mailer: m, Attempts: retry.Attempts, MaxWait: retry.MaxWait}
+}
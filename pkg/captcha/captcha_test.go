@@ -0,0 +1,46 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name        string
+		reply       string
+		risk        bool
+		wantSuccess bool
+		wantScore   float64
+		wantExpired bool
+	}{
+		{name: "passed", reply: `{"success": true, "hostname": "goalhero.eu"}`, wantSuccess: true, wantScore: 1},
+		{name: "scored", reply: `{"success": true, "score": 0.7}`, wantSuccess: true, wantScore: 0.7},
+		{name: "risk score", reply: `{"success": true, "score": 0.25}`, risk: true, wantSuccess: true, wantScore: 0.75},
+		{name: "failed", reply: `{"success": false, "error-codes": ["invalid-input-response"]}`},
+		{name: "duplicate", reply: `{"success": false, "error-codes": ["timeout-or-duplicate"]}`, wantExpired: true},
+		{name: "already seen", reply: `{"success": false, "error-codes": ["invalid-or-already-seen-response"]}`, wantExpired: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.FormValue("secret") != "s3cret" || r.FormValue("response") != "token" || r.FormValue("remoteip") != "192.0.2.1" {
+					t.Errorf("form = %v", r.Form)
+				}
+				w.Write([]byte(tt.reply))
+			}))
+			defer srv.Close()
+
+			v := &SiteVerify{URL: srv.URL, Secret: "s3cret", Client: srv.Client(), RiskScore: tt.risk}
+			res, err := v.Verify(context.Background(), "token", "192.0.2.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Success != tt.wantSuccess || res.Score != tt.wantScore || res.Expired() != tt.wantExpired {
+				t.Errorf("Verify = %+v, expired %v", res, res.Expired())
+			}
+		})
+	}
+}
@@ -0,0 +1,586 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/mail"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goalhero-emailer/pkg/captcha"
+	"goalhero-emailer/pkg/cors"
+	"goalhero-emailer/pkg/outbox"
+	"goalhero-emailer/pkg/ratelimit"
+	"goalhero-emailer/pkg/redis"
+)
+
+const (
+	defaultFromName  = "GoalHero Team"
+	defaultFromEmail = "info@goalhero.eu"
+	defaultDataDir   = "data"
+
+	minSecretLength = 32
+
+	defaultConfirmTTL = 48 * time.Hour
+
+	defaultCORSMaxAge = time.Hour
+
+	defaultMaxBodyBytes = 16 << 10
+
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+
+	defaultSendRetryAttempts = 3
+	defaultSendRetryMaxWait  = 10 * time.Second
+
+	defaultOutboxMaxAttempts = 8
+	defaultOutboxBaseDelay   = 30 * time.Second
+	defaultOutboxMaxDelay    = time.Hour
+	defaultOutboxSyncTimeout = 5 * time.Second
+
+	defaultCaptchaMinScore = 0.5
+	defaultMinFillTime     = 2 * time.Second
+	defaultFormTokenMaxAge = 24 * time.Hour
+)
+
+// Default signup limits: generous for people, tight for scripts. The
+// per-domain limit is off by default, since most signups share a handful
+// of free-mail domains.
+var (
+	defaultRateLimitIP    = ratelimit.Limit{Burst: 10, Period: time.Minute}
+	defaultRateLimitEmail = ratelimit.Limit{Burst: 3, Period: time.Hour}
+)
+
+// Sender is the identity welcome emails are sent from.
+type Sender struct {
+	Name    string
+	Email   string
+	ReplyTo string
+}
+
+// Contact is the address recipients are told to write to.
+func (s Sender) Contact() string {
+	if s.ReplyTo != "" {
+		return s.ReplyTo
+	}
+	return s.Email
+}
+
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      string
+	Timeout  time.Duration
+}
+
+// MailBreaker configures the circuit breaker of every mail provider.
+type MailBreaker struct {
+	// Threshold is the number of consecutive transient failures that
+	// takes a provider out of rotation for Cooldown.
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// SendRetry bounds the immediate resends of an email after a transient
+// failure; longer waits are left to the outbox.
+type SendRetry struct {
+	// Attempts counts every send, so 1 disables retries.
+	Attempts int
+	MaxWait  time.Duration
+}
+
+// RateLimits are the signup limits per client IP, per normalized address
+// and per address domain.
+type RateLimits struct {
+	IP     ratelimit.Limit
+	Email  ratelimit.Limit
+	Domain ratelimit.Limit
+}
+
+// BotProtection configures the checks that silently drop automated
+// signups.
+type BotProtection struct {
+	// CaptchaProvider is "hcaptcha", "turnstile" or "recaptcha"; CAPTCHA
+	// checks are off when empty.
+	CaptchaProvider string
+	CaptchaSecret   string
+	// CaptchaMinScore is the lowest passing score (0-1) for providers
+	// that score requests.
+	CaptchaMinScore float64
+
+	// RequireFormToken rejects signups without a form token; a token is
+	// checked whenever one is sent.
+	RequireFormToken bool
+	// MinFillTime is how long a person needs at least to fill the form.
+	MinFillTime     time.Duration
+	FormTokenMaxAge time.Duration
+}
+
+type Config struct {
+	Sender Sender
+	// Senders holds per-language overrides keyed by lower-case language
+	// tag, e.g. FROM_NAME_ES populates Senders["es"].Name.
+	Senders map[string]Sender
+
+	SendGridAPIKey string
+	SMTP           SMTP
+	// MailProviders lists the transports to send through ("smtp",
+	// "sendgrid") in failover order. With MAILER=capture it is just
+	// "capture", which keeps messages for the mailbox viewer instead.
+	MailProviders []string
+	// CaptureDir stores captured messages as .eml files; they are kept
+	// in memory when empty.
+	CaptureDir  string
+	MailBreaker MailBreaker
+	SendRetry   SendRetry
+
+	// BaseURL is the public origin of this service, used to build links
+	// in emails (no trailing slash).
+	BaseURL string
+	// SigningSecret keys the HMAC tokens in those links.
+	SigningSecret string
+	// DataDir holds the stores when RedisURL is empty.
+	DataDir string
+	// Vercel is set when running on Vercel (VERCEL=1), where instances
+	// share no disk and don't outlive their requests.
+	Vercel bool
+	// AdminToken guards the admin endpoints; they are disabled when empty.
+	AdminToken string
+	// Previews serves /preview for rendering templates with sample data.
+	// It defaults to off except under vercel dev (VERCEL_ENV=development).
+	Previews bool
+
+	// DoubleOptIn makes signups confirm their address before the welcome
+	// email is sent; unconfirmed registrations expire after ConfirmTTL.
+	DoubleOptIn bool
+	ConfirmTTL  time.Duration
+
+	// NormalizeGmail treats Gmail dot and "+tag" variants of an address
+	// as the same registration.
+	NormalizeGmail bool
+
+	// BlockDisposable rejects signups from throwaway mailbox providers.
+	BlockDisposable bool
+	// ValidateMX rejects signups whose domain cannot receive mail.
+	ValidateMX bool
+
+	RateLimits RateLimits
+	// RedisURL selects Redis for the stores and rate limits, shared by
+	// every instance. When empty the stores live in DataDir and limits
+	// are kept per instance.
+	RedisURL string
+	// TrustProxy takes the client IP from X-Real-IP / X-Forwarded-For, as
+	// set by Vercel, rather than the peer address. It defaults to true on
+	// Vercel only, where clients can't forge those headers.
+	TrustProxy bool
+
+	Bots BotProtection
+
+	// CORSOrigins lists the origins browsers may call the API from;
+	// "*" allows any. CORSMaxAge is how long preflights are cached.
+	CORSOrigins []string
+	CORSMaxAge  time.Duration
+
+	// MaxBodyBytes caps request bodies.
+	MaxBodyBytes int64
+	// StrictRequests rejects request bodies with unknown fields.
+	StrictRequests bool
+
+	// Outbox is the retry policy for queued emails.
+	Outbox outbox.Policy
+	// OutboxSync sends a request's emails before answering it rather than
+	// in the background. It defaults to true on Vercel, which freezes
+	// instances between requests. The response then waits for the
+	// provider, for at most OutboxSyncTimeout; a send cut off is retried
+	// by the outbox drain.
+	OutboxSync        bool
+	OutboxSyncTimeout time.Duration
+	// CronSecret authorizes scheduled calls to the outbox drain endpoint,
+	// as sent by Vercel Cron.
+	CronSecret string
+}
+
+// SenderFor returns the sender for a language tag: the default sender,
+// overridden field by field by the settings for its base language ("es")
+// and then by those for the full tag ("es-AR").
+func (c *Config) SenderFor(language string) Sender {
+	s := c.Sender
+	language = strings.ToLower(language)
+	base, _, _ := strings.Cut(language, "-")
+	for _, tag := range []string{base, language} {
+		o := c.Senders[tag]
+		if o.Name != "" {
+			s.Name = o.Name
+		}
+		if o.Email != "" {
+			s.Email = o.Email
+		}
+		if o.ReplyTo != "" {
+			s.ReplyTo = o.ReplyTo
+		}
+	}
+	return s
+}
+
+var (
+	loadOnce sync.Once
+	loaded   *Config
+	loadErr  error
+)
+
+// Load parses the process environment once and caches the result.
+func Load() (*Config, error) {
+	loadOnce.Do(func() {
+		loaded, loadErr = Parse(os.Environ())
+	})
+	return loaded, loadErr
+}
+
+// Parse builds a Config from KEY=VALUE pairs and reports every invalid
+// setting at once.
+func Parse(environ []string) (*Config, error) {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = strings.TrimSpace(v)
+		}
+	}
+
+	var errs []error
+	invalid := func(key string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %v", key, err))
+	}
+
+	cfg := &Config{
+		Sender: Sender{
+			Name:    env["FROM_NAME"],
+			Email:   env["FROM_EMAIL"],
+			ReplyTo: env["REPLY_TO"],
+		},
+		Senders:         make(map[string]Sender),
+		SendGridAPIKey:  env["SENDGRID_API_KEY"],
+		BaseURL:         strings.TrimRight(env["PUBLIC_BASE_URL"], "/"),
+		SigningSecret:   env["SIGNING_SECRET"],
+		DataDir:         env["DATA_DIR"],
+		Vercel:          env["VERCEL"] != "",
+		AdminToken:      env["ADMIN_TOKEN"],
+		ConfirmTTL:      defaultConfirmTTL,
+		BlockDisposable: true,
+		CORSOrigins:     []string{"*"},
+		CORSMaxAge:      defaultCORSMaxAge,
+		MaxBodyBytes:    defaultMaxBodyBytes,
+		CronSecret:      env["CRON_SECRET"],
+		Previews:        env["VERCEL_ENV"] == "development",
+		MailBreaker: MailBreaker{
+			Threshold: defaultBreakerThreshold,
+			Cooldown:  defaultBreakerCooldown,
+		},
+		SendRetry: SendRetry{
+			Attempts: defaultSendRetryAttempts,
+			MaxWait:  defaultSendRetryMaxWait,
+		},
+		Outbox: outbox.Policy{
+			MaxAttempts: defaultOutboxMaxAttempts,
+			BaseDelay:   defaultOutboxBaseDelay,
+			MaxDelay:    defaultOutboxMaxDelay,
+		},
+		RedisURL:   env["REDIS_URL"],
+		TrustProxy: env["VERCEL_ENV"] != "",
+		Bots: BotProtection{
+			CaptchaProvider: strings.ToLower(env["CAPTCHA_PROVIDER"]),
+			CaptchaSecret:   env["CAPTCHA_SECRET"],
+			CaptchaMinScore: defaultCaptchaMinScore,
+			MinFillTime:     defaultMinFillTime,
+			FormTokenMaxAge: defaultFormTokenMaxAge,
+		},
+		RateLimits: RateLimits{
+			IP:    defaultRateLimitIP,
+			Email: defaultRateLimitEmail,
+		},
+		SMTP: SMTP{
+			Host:     env["SMTP_HOST"],
+			Username: env["SMTP_USER"],
+			Password: env["SMTP_PASS"],
+			TLS:      strings.ToLower(env["SMTP_TLS"]),
+		},
+	}
+	if cfg.Sender.Name == "" {
+		cfg.Sender.Name = defaultFromName
+	}
+	if cfg.Sender.Email == "" {
+		cfg.Sender.Email = defaultFromEmail
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = defaultDataDir
+	}
+
+	for key, value := range env {
+		if value == "" {
+			continue
+		}
+		for prefix, set := range map[string]func(*Sender, string){
+			"FROM_NAME_":  func(s *Sender, v string) { s.Name = v },
+			"FROM_EMAIL_": func(s *Sender, v string) { s.Email = v },
+			"REPLY_TO_":   func(s *Sender, v string) { s.ReplyTo = v },
+		} {
+			if lang, ok := strings.CutPrefix(key, prefix); ok && lang != "" {
+				lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+				s := cfg.Senders[lang]
+				set(&s, value)
+				cfg.Senders[lang] = s
+			}
+		}
+	}
+
+	checkAddress := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if _, err := mail.ParseAddress(value); err != nil {
+			invalid(key, fmt.Errorf("invalid email address %q", value))
+		}
+	}
+	checkAddress("FROM_EMAIL", cfg.Sender.Email)
+	checkAddress("REPLY_TO", cfg.Sender.ReplyTo)
+	for _, lang := range slices.Sorted(maps.Keys(cfg.Senders)) {
+		s := cfg.Senders[lang]
+		suffix := strings.ToUpper(strings.ReplaceAll(lang, "-", "_"))
+		checkAddress("FROM_EMAIL_"+suffix, s.Email)
+		checkAddress("REPLY_TO_"+suffix, s.ReplyTo)
+	}
+
+	if v := env["SMTP_PORT"]; v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 || port > 65535 {
+			invalid("SMTP_PORT", fmt.Errorf("invalid port %q", v))
+		}
+		cfg.SMTP.Port = port
+	}
+	if v := env["SMTP_TIMEOUT"]; v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			invalid("SMTP_TIMEOUT", fmt.Errorf("invalid duration %q", v))
+		}
+		cfg.SMTP.Timeout = timeout
+	}
+	switch cfg.SMTP.TLS {
+	case "", "starttls", "implicit", "none":
+	default:
+		invalid("SMTP_TLS", fmt.Errorf("invalid mode %q: want starttls, implicit or none", cfg.SMTP.TLS))
+	}
+	if cfg.SMTP.Host != "" && cfg.SMTP.Username != "" && cfg.SMTP.Password == "" {
+		invalid("SMTP_PASS", errors.New("required when SMTP_USER is set"))
+	}
+
+	switch mailer := strings.ToLower(env["MAILER"]); {
+	case mailer == "capture":
+		if env["MAIL_PROVIDERS"] != "" {
+			invalid("MAIL_PROVIDERS", errors.New("can't be combined with MAILER=capture"))
+		}
+		if env["VERCEL_ENV"] == "production" {
+			invalid("MAILER", errors.New("capture is for development and can't be used in production"))
+		}
+		if cfg.Vercel && cfg.AdminToken == "" {
+			// Vercel's runtime reaches functions from localhost, so the
+			// mailbox can't trust loopback peers there.
+			invalid("ADMIN_TOKEN", errors.New("required with MAILER=capture on Vercel, to guard the mailbox"))
+		}
+		cfg.MailProviders = []string{"capture"}
+		cfg.CaptureDir = env["CAPTURE_DIR"]
+	case mailer != "":
+		invalid("MAILER", fmt.Errorf("unknown mailer %q: want capture or leave unset", mailer))
+	case env["MAIL_PROVIDERS"] != "":
+		seen := map[string]bool{}
+		for _, name := range strings.Split(env["MAIL_PROVIDERS"], ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			switch {
+			case seen[name]:
+				invalid("MAIL_PROVIDERS", fmt.Errorf("%q is listed twice", name))
+			case name == "smtp" && cfg.SMTP.Host == "":
+				invalid("MAIL_PROVIDERS", errors.New("smtp needs SMTP_HOST"))
+			case name == "sendgrid" && cfg.SendGridAPIKey == "":
+				invalid("MAIL_PROVIDERS", errors.New("sendgrid needs SENDGRID_API_KEY"))
+			case name != "smtp" && name != "sendgrid":
+				invalid("MAIL_PROVIDERS", fmt.Errorf("unknown provider %q: want smtp or sendgrid", name))
+			}
+			seen[name] = true
+			cfg.MailProviders = append(cfg.MailProviders, name)
+		}
+	default:
+		if cfg.SMTP.Host != "" {
+			cfg.MailProviders = append(cfg.MailProviders, "smtp")
+		}
+		if cfg.SendGridAPIKey != "" {
+			cfg.MailProviders = append(cfg.MailProviders, "sendgrid")
+		}
+	}
+
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("PUBLIC_BASE_URL", fmt.Errorf("want an absolute http(s) URL, got %q", cfg.BaseURL))
+		}
+		if cfg.SigningSecret == "" {
+			invalid("SIGNING_SECRET", errors.New("required when PUBLIC_BASE_URL is set"))
+		}
+	}
+	if cfg.SigningSecret != "" && len(cfg.SigningSecret) < minSecretLength {
+		invalid("SIGNING_SECRET", fmt.Errorf("must be at least %d characters", minSecretLength))
+	}
+
+	parseBool := func(key string, dst *bool) {
+		if v := env[key]; v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				invalid(key, fmt.Errorf("invalid boolean %q", v))
+			}
+			*dst = b
+		}
+	}
+	parseBool("NORMALIZE_GMAIL", &cfg.NormalizeGmail)
+	parseBool("DOUBLE_OPT_IN", &cfg.DoubleOptIn)
+	parseBool("BLOCK_DISPOSABLE", &cfg.BlockDisposable)
+	parseBool("VALIDATE_MX", &cfg.ValidateMX)
+	parseBool("TRUST_PROXY", &cfg.TrustProxy)
+
+	parseLimit := func(key string, dst *ratelimit.Limit) {
+		if v := env[key]; v != "" {
+			l, err := ratelimit.ParseLimit(v)
+			if err != nil {
+				invalid(key, err)
+			}
+			*dst = l
+		}
+	}
+	parseLimit("RATE_LIMIT_IP", &cfg.RateLimits.IP)
+	parseLimit("RATE_LIMIT_EMAIL", &cfg.RateLimits.Email)
+	parseLimit("RATE_LIMIT_DOMAIN", &cfg.RateLimits.Domain)
+	bots := &cfg.Bots
+	if bots.CaptchaProvider != "" {
+		if _, ok := captcha.Providers[bots.CaptchaProvider]; !ok {
+			invalid("CAPTCHA_PROVIDER", fmt.Errorf("want one of %s, got %q",
+				strings.Join(slices.Sorted(maps.Keys(captcha.Providers)), ", "), bots.CaptchaProvider))
+		}
+		if bots.CaptchaSecret == "" {
+			invalid("CAPTCHA_SECRET", errors.New("required when CAPTCHA_PROVIDER is set"))
+		}
+	}
+	if v := env["CAPTCHA_MIN_SCORE"]; v != "" {
+		score, err := strconv.ParseFloat(v, 64)
+		if err != nil || score < 0 || score > 1 {
+			invalid("CAPTCHA_MIN_SCORE", fmt.Errorf("want a number between 0 and 1, got %q", v))
+		}
+		bots.CaptchaMinScore = score
+	}
+	parseBool("REQUIRE_FORM_TOKEN", &bots.RequireFormToken)
+	if bots.RequireFormToken && cfg.SigningSecret == "" {
+		invalid("SIGNING_SECRET", errors.New("required when REQUIRE_FORM_TOKEN is enabled"))
+	}
+	parseDuration := func(key string, dst *time.Duration) {
+		if v := env[key]; v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				invalid(key, fmt.Errorf("invalid duration %q", v))
+			}
+			*dst = d
+		}
+	}
+	parseDuration("FORM_MIN_FILL_TIME", &bots.MinFillTime)
+	parseDuration("FORM_TOKEN_MAX_AGE", &bots.FormTokenMaxAge)
+
+	if v := env["CORS_ALLOWED_ORIGINS"]; v != "" {
+		cfg.CORSOrigins = strings.Split(v, ",")
+		if _, err := cors.New(cfg.CORSOrigins, 0); err != nil {
+			invalid("CORS_ALLOWED_ORIGINS", err)
+		}
+	}
+	parseDuration("CORS_MAX_AGE", &cfg.CORSMaxAge)
+
+	if v := env["MAX_BODY_BYTES"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1024 {
+			invalid("MAX_BODY_BYTES", fmt.Errorf("want a byte count of at least 1024, got %q", v))
+		}
+		cfg.MaxBodyBytes = n
+	}
+	parseBool("STRICT_REQUESTS", &cfg.StrictRequests)
+	parseBool("PREVIEWS", &cfg.Previews)
+
+	if v := env["MAIL_RETRY_ATTEMPTS"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			invalid("MAIL_RETRY_ATTEMPTS", fmt.Errorf("want a positive number, got %q", v))
+		}
+		cfg.SendRetry.Attempts = n
+	}
+	parseDuration("MAIL_RETRY_MAX_WAIT", &cfg.SendRetry.MaxWait)
+	if v := env["MAIL_BREAKER_THRESHOLD"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			invalid("MAIL_BREAKER_THRESHOLD", fmt.Errorf("want a positive number, got %q", v))
+		}
+		cfg.MailBreaker.Threshold = n
+	}
+	parseDuration("MAIL_BREAKER_COOLDOWN", &cfg.MailBreaker.Cooldown)
+	if cfg.MailBreaker.Cooldown <= 0 {
+		invalid("MAIL_BREAKER_COOLDOWN", errors.New("must be positive"))
+	}
+
+	if v := env["OUTBOX_MAX_ATTEMPTS"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			invalid("OUTBOX_MAX_ATTEMPTS", fmt.Errorf("want a positive number, got %q", v))
+		}
+		cfg.Outbox.MaxAttempts = n
+	}
+	cfg.OutboxSync = cfg.Vercel
+	parseBool("OUTBOX_SYNC", &cfg.OutboxSync)
+	cfg.OutboxSyncTimeout = defaultOutboxSyncTimeout
+	parseDuration("OUTBOX_SYNC_TIMEOUT", &cfg.OutboxSyncTimeout)
+	if cfg.OutboxSyncTimeout <= 0 {
+		invalid("OUTBOX_SYNC_TIMEOUT", errors.New("must be positive"))
+	}
+	parseDuration("OUTBOX_BACKOFF_BASE", &cfg.Outbox.BaseDelay)
+	parseDuration("OUTBOX_BACKOFF_MAX", &cfg.Outbox.MaxDelay)
+	if cfg.Outbox.BaseDelay <= 0 || cfg.Outbox.MaxDelay < cfg.Outbox.BaseDelay {
+		invalid("OUTBOX_BACKOFF_MAX", errors.New("must be at least OUTBOX_BACKOFF_BASE, which must be positive"))
+	}
+	if cfg.CronSecret != "" && len(cfg.CronSecret) < minSecretLength {
+		invalid("CRON_SECRET", fmt.Errorf("must be at least %d characters", minSecretLength))
+	}
+
+	if v := env["CONFIRM_TTL"]; v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl < time.Hour {
+			invalid("CONFIRM_TTL", fmt.Errorf("want a duration of at least 1h, got %q", v))
+		}
+		cfg.ConfirmTTL = ttl
+	}
+	if cfg.DoubleOptIn && cfg.BaseURL == "" {
+		invalid("PUBLIC_BASE_URL", errors.New("required when DOUBLE_OPT_IN is enabled"))
+	}
+	if cfg.AdminToken != "" && len(cfg.AdminToken) < minSecretLength {
+		invalid("ADMIN_TOKEN", fmt.Errorf("must be at least %d characters", minSecretLength))
+	}
+
+	if cfg.RedisURL != "" {
+		if _, err := redis.New(cfg.RedisURL); err != nil {
+			invalid("REDIS_URL", err)
+		}
+	} else if cfg.Vercel {
+		invalid("REDIS_URL", errors.New("required on Vercel, whose instances share no disk for the stores; see Upgrading in the README"))
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
@@ -0,0 +1,53 @@
+// Package confirm mints and checks the expiring tokens in double opt-in
+// confirmation links.
+package confirm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"goalhero-emailer/pkg/signing"
+)
+
+const purpose = "beta-confirm"
+
+// Path is where the confirmation endpoint is served.
+const Path = "/api/beta-confirm"
+
+var ErrExpired = errors.New("confirmation link expired")
+
+type claims struct {
+	Key     string `json:"k"`
+	Expires int64  `json:"e"`
+}
+
+// Token returns a signed token for the registration key, valid until
+// expires.
+func Token(s *signing.Signer, key string, expires time.Time) string {
+	payload, _ := json.Marshal(claims{Key: key, Expires: expires.Unix()})
+	return s.Sign(purpose, payload)
+}
+
+// Verify returns the registration key of a token that is authentic and
+// not expired at now.
+func Verify(s *signing.Signer, token string, now time.Time) (string, error) {
+	payload, err := s.Verify(purpose, token)
+	if err != nil {
+		return "", err
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Key == "" {
+		return "", signing.ErrInvalidToken
+	}
+	if now.Unix() > c.Expires {
+		return "", ErrExpired
+	}
+	return c.Key, nil
+}
+
+// URL builds the confirmation link under baseURL.
+func URL(s *signing.Signer, baseURL, key string, expires time.Time) string {
+	return baseURL + Path + "?token=" + url.QueryEscape(Token(s, key, expires))
+}
@@ -0,0 +1,45 @@
+package confirm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/signing"
+)
+
+func TestVerify(t *testing.T) {
+	s := signing.New("0123456789abcdef0123456789abcdef")
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	token := Token(s, "jane@example.com", now.Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		want  string
+		err   error
+	}{
+		{name: "valid", token: token, now: now, want: "jane@example.com"},
+		{name: "last second", token: token, now: now.Add(time.Hour), want: "jane@example.com"},
+		{name: "expired", token: token, now: now.Add(time.Hour + time.Second), err: ErrExpired},
+		{name: "other secret", token: Token(signing.New("another secret"), "jane@example.com", now.Add(time.Hour)), now: now,
+			err: signing.ErrInvalidToken},
+		{name: "no key", token: s.Sign(purpose, []byte(`{"e": 1893456000}`)), now: now, err: signing.ErrInvalidToken},
+		{name: "not json", token: s.Sign(purpose, []byte("jane@example.com")), now: now, err: signing.ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := Verify(s, tt.token, tt.now)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Verify = %q, %v; want %v", key, err, tt.err)
+				}
+				return
+			}
+			if err != nil || key != tt.want {
+				t.Fatalf("Verify = %q, %v; want %q", key, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Package cors answers preflight requests and sets CORS headers for the
+// browser-facing endpoints. Vercel needs every endpoint to be a plain
+// handler function, so rather than wrapping handlers the middleware is a
+// call at the top of each one:
+//
+//	if !a.CORS.Handle(w, r, opts) {
+//		return
+//	}
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options are the per-endpoint parts of the policy.
+type Options struct {
+	// Methods lists the allowed methods besides OPTIONS.
+	Methods []string
+	// Headers lists the request headers clients may send.
+	Headers []string
+	// Expose lists the response headers scripts may read.
+	Expose []string
+	// Reject writes the response to a request from an origin that is not
+	// allowed, in the endpoint's error format. It defaults to a plain-text
+	// 403.
+	Reject func(w http.ResponseWriter, r *http.Request)
+}
+
+// Middleware applies an origin allowlist.
+type Middleware struct {
+	anyOrigin bool
+	exact     map[string]bool
+	wildcards []wildcard
+	maxAge    time.Duration
+}
+
+// wildcard matches any subdomain of suffix, which starts with ".", for
+// one scheme and port.
+type wildcard struct {
+	scheme, suffix, port string
+}
+
+// New builds a Middleware from an allowlist of origins. An entry is "*"
+// for any origin, an exact origin such as "https://goalhero.eu", or a
+// wildcard such as "https://*.goalhero.eu", which matches subdomains at
+// any depth but not goalhero.eu itself. Entries without a scheme are
+// taken as https. maxAge is how long browsers may cache a preflight.
+func New(origins []string, maxAge time.Duration) (*Middleware, error) {
+	m := &Middleware{exact: make(map[string]bool), maxAge: maxAge}
+	for _, o := range origins {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		if o == "*" {
+			m.anyOrigin = true
+			continue
+		}
+		if !strings.Contains(o, "://") {
+			o = "https://" + o
+		}
+		scheme, host, port, err := parseOrigin(o)
+		if err != nil {
+			return nil, fmt.Errorf("invalid origin %q: %w", o, err)
+		}
+		if rest, ok := strings.CutPrefix(host, "*."); ok {
+			if rest == "" || strings.Contains(rest, "*") {
+				return nil, fmt.Errorf("invalid origin %q: bad wildcard", o)
+			}
+			m.wildcards = append(m.wildcards, wildcard{scheme: scheme, suffix: "." + rest, port: port})
+			continue
+		}
+		if strings.Contains(host, "*") {
+			return nil, fmt.Errorf("invalid origin %q: wildcards must be a leading \"*.\"", o)
+		}
+		m.exact[joinOrigin(scheme, host, port)] = true
+	}
+	return m, nil
+}
+
+// parseOrigin splits an origin into lower-case scheme and host, and the
+// port with scheme defaults filled in.
+func parseOrigin(origin string) (scheme, host, port string, err error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", "", "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", "", fmt.Errorf("scheme must be http or https")
+	}
+	if u.Hostname() == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", "", "", fmt.Errorf("want scheme://host[:port]")
+	}
+	scheme, host, port = strings.ToLower(u.Scheme), strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = "443"
+		if scheme == "http" {
+			port = "80"
+		}
+	}
+	return scheme, host, port, nil
+}
+
+func joinOrigin(scheme, host, port string) string {
+	return scheme + "://" + host + ":" + port
+}
+
+// Allowed reports whether a request from origin may be served.
+func (m *Middleware) Allowed(origin string) bool {
+	if m.anyOrigin {
+		return true
+	}
+	scheme, host, port, err := parseOrigin(origin)
+	if err != nil {
+		return false
+	}
+	if m.exact[joinOrigin(scheme, host, port)] {
+		return true
+	}
+	for _, w := range m.wildcards {
+		if w.scheme == scheme && w.port == port && strings.HasSuffix(host, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedHost reports whether pages on host may call the API, i.e. whether
+// some allowed origin has that host, on any scheme and port.
+func (m *Middleware) AllowedHost(host string) bool {
+	if m.anyOrigin {
+		return true
+	}
+	host = strings.ToLower(host)
+	for origin := range m.exact {
+		if _, h, _, err := parseOrigin(origin); err == nil && h == host {
+			return true
+		}
+	}
+	for _, w := range m.wildcards {
+		if strings.HasSuffix(host, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle sets the CORS response headers for r and reports whether the
+// handler should go on. It answers preflight (OPTIONS) requests itself
+// and rejects requests from origins that are not allowed with
+// opts.Reject; same-origin requests and those without an Origin header,
+// such as server-to-server calls, always pass.
+func (m *Middleware) Handle(w http.ResponseWriter, r *http.Request, opts Options) bool {
+	h := w.Header()
+	origin := r.Header.Get("Origin")
+	if !m.anyOrigin {
+		// The response depends on the origin, so caches must key on it.
+		h.Add("Vary", "Origin")
+	}
+	preflight := r.Method == "OPTIONS"
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	allowed := origin == "" || sameOrigin(r, origin) || m.Allowed(origin)
+	if !allowed {
+		if opts.Reject != nil {
+			opts.Reject(w, r)
+		} else {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+		}
+		return false
+	}
+
+	if origin != "" {
+		if m.anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if len(opts.Expose) > 0 && !preflight {
+			h.Set("Access-Control-Expose-Headers", strings.Join(opts.Expose, ", "))
+		}
+	}
+
+	if !preflight {
+		return true
+	}
+	methods := strings.Join(append(append([]string{}, opts.Methods...), "OPTIONS"), ", ")
+	h.Set("Allow", methods)
+	h.Set("Access-Control-Allow-Methods", methods)
+	if len(opts.Headers) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(opts.Headers, ", "))
+	}
+	if m.maxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(m.maxAge.Seconds())))
+	}
+	h.Del("Content-Type")
+	w.WriteHeader(http.StatusNoContent)
+	return false
+}
+
+// sameOrigin reports whether origin is the host serving r, e.g. a page
+// served by this deployment posting back to it.
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
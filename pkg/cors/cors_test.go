@@ -0,0 +1,183 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAllowedHost(t *testing.T) {
+	m, err := New([]string{"https://goalhero.eu", "http://localhost:3000", "https://*.preview.goalhero.eu"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]bool{
+		"goalhero.eu":              true,
+		"GoalHero.eu":              true,
+		"localhost":                true,
+		"pr-1.preview.goalhero.eu": true,
+		"preview.goalhero.eu":      false,
+		"www.goalhero.eu":          false,
+		"evil.example":             false,
+	} {
+		if got := m.AllowedHost(host); got != want {
+			t.Errorf("AllowedHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	all, _ := New([]string{"*"}, 0)
+	if !all.AllowedHost("evil.example") {
+		t.Errorf("* does not allow every host")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	m, err := New([]string{"https://goalhero.eu", "http://localhost:3000", "https://*.goalhero.app", "staging.goalhero.eu"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for origin, want := range map[string]bool{
+		"https://goalhero.eu":         true,
+		"https://GoalHero.eu":         true,
+		"https://goalhero.eu:443":     true,
+		"http://goalhero.eu":          false,
+		"https://goalhero.eu:8443":    false,
+		"https://www.goalhero.eu":     false,
+		"http://localhost:3000":       true,
+		"http://localhost":            false,
+		"http://localhost:3001":       false,
+		"https://staging.goalhero.eu": true,
+		"http://staging.goalhero.eu":  false,
+
+		"https://pr-1.goalhero.app":      true,
+		"https://a.b.goalhero.app":       true,
+		"https://goalhero.app":           false,
+		"http://pr-1.goalhero.app":       false,
+		"https://pr-1.goalhero.app:8443": false,
+		"https://evilgoalhero.app":       false,
+		"https://pr-1.goalhero.app.evil": false,
+		"null":                           false,
+		"https://goalhero.eu/signup":     false,
+		"https://user@goalhero.eu":       false,
+		"ftp://goalhero.eu":              false,
+	} {
+		if got := m.Allowed(origin); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", origin, got, want)
+		}
+	}
+
+	all, _ := New([]string{"*"}, 0)
+	if !all.Allowed("https://evil.example") {
+		t.Errorf("* does not allow every origin")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, origin := range []string{"ftp://goalhero.eu", "https://goalhero.eu/path", "https://*.", "https://a.*.goalhero.eu", "https://*.*.goalhero.eu"} {
+		if _, err := New([]string{origin}, 0); err == nil {
+			t.Errorf("New(%q) succeeded", origin)
+		}
+	}
+}
+
+var opts = Options{Methods: []string{"POST"}, Headers: []string{"Content-Type", "Idempotency-Key"}, Expose: []string{"Retry-After"}}
+
+// handle runs m.Handle for a request with method and origin to host.
+func handle(m *Middleware, method, origin string, opts Options) (*httptest.ResponseRecorder, bool) {
+	r := httptest.NewRequest(method, "https://emailer.goalhero.eu/api/beta-register", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	if method == "OPTIONS" {
+		r.Header.Set("Access-Control-Request-Method", "POST")
+	}
+	w := httptest.NewRecorder()
+	next := m.Handle(w, r, opts)
+	return w, next
+}
+
+func TestHandle(t *testing.T) {
+	m, _ := New([]string{"https://goalhero.eu"}, time.Hour)
+
+	w, next := handle(m, "POST", "https://goalhero.eu", opts)
+	h := w.Header()
+	if !next || h.Get("Access-Control-Allow-Origin") != "https://goalhero.eu" ||
+		h.Get("Access-Control-Expose-Headers") != "Retry-After" {
+		t.Errorf("allowed POST: next %v, headers %v", next, h)
+	}
+	if !slices.Contains(h.Values("Vary"), "Origin") {
+		t.Errorf("Vary = %v, want Origin", h.Values("Vary"))
+	}
+
+	// Requests without an Origin, and from this host, pass without CORS
+	// headers to check.
+	for _, origin := range []string{"", "https://emailer.goalhero.eu"} {
+		if w, next := handle(m, "POST", origin, opts); !next || w.Code != http.StatusOK {
+			t.Errorf("POST from %q: next %v, status %d", origin, next, w.Code)
+		}
+	}
+
+	all, _ := New([]string{"*"}, 0)
+	w, next = handle(all, "POST", "https://evil.example", opts)
+	if !next || w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Vary") != "" {
+		t.Errorf("POST with *: next %v, headers %v", next, w.Header())
+	}
+}
+
+func TestHandlePreflight(t *testing.T) {
+	m, _ := New([]string{"https://goalhero.eu"}, 90*time.Minute)
+	w, next := handle(m, "OPTIONS", "https://goalhero.eu", opts)
+	if next {
+		t.Errorf("preflight passed on to the handler")
+	}
+	h := w.Header()
+	for key, want := range map[string]string{
+		"Access-Control-Allow-Origin":   "https://goalhero.eu",
+		"Access-Control-Allow-Methods":  "POST, OPTIONS",
+		"Access-Control-Allow-Headers":  "Content-Type, Idempotency-Key",
+		"Access-Control-Max-Age":        "5400",
+		"Access-Control-Expose-Headers": "",
+	} {
+		if got := h.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status %d, want 204", w.Code)
+	}
+	if vary := h.Values("Vary"); !slices.Contains(vary, "Origin") || !slices.Contains(vary, "Access-Control-Request-Method") {
+		t.Errorf("Vary = %v", vary)
+	}
+
+	noCache, _ := New([]string{"https://goalhero.eu"}, 0)
+	if w, _ := handle(noCache, "OPTIONS", "https://goalhero.eu", opts); w.Header().Get("Access-Control-Max-Age") != "" {
+		t.Errorf("Max-Age sent without a max age")
+	}
+}
+
+func TestHandleReject(t *testing.T) {
+	m, _ := New([]string{"https://goalhero.eu"}, time.Hour)
+
+	w, next := handle(m, "POST", "https://evil.example", Options{})
+	if next || w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed POST: next %v, status %d, headers %v", next, w.Code, w.Header())
+	}
+
+	rejected := 0
+	custom := opts
+	custom.Reject = func(w http.ResponseWriter, r *http.Request) {
+		rejected++
+		w.WriteHeader(http.StatusTeapot)
+	}
+	for _, method := range []string{"POST", "OPTIONS"} {
+		w, next := handle(m, method, "https://evil.example", custom)
+		if next || w.Code != http.StatusTeapot {
+			t.Errorf("disallowed %s: next %v, status %d, want Reject's", method, next, w.Code)
+		}
+	}
+	if rejected != 2 {
+		t.Errorf("Reject called %d times, want 2", rejected)
+	}
+}
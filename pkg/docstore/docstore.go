@@ -0,0 +1,25 @@
+// Package docstore keeps a JSON document that every instance of the
+// service reads and updates. Update re-reads the document and writes it
+// back in one exclusive step, so instances sharing a disk or a Redis server
+// never overwrite each other's changes. It suits the low write volume of a
+// signup list.
+package docstore
+
+import (
+	"context"
+	"errors"
+)
+
+// Store holds one document.
+type Store interface {
+	// Load returns the document, or nil if it doesn't exist yet.
+	Load(ctx context.Context) ([]byte, error)
+	// Update calls fn with the current document (nil if there is none)
+	// and stores what it returns, excluding other updates meanwhile. If
+	// fn fails nothing is written and its error is returned. fn may be
+	// called more than once.
+	Update(ctx context.Context, fn func(doc []byte) ([]byte, error)) error
+}
+
+// ErrConflict means an update kept losing races with other instances.
+var ErrConflict = errors.New("document changed concurrently, giving up")
@@ -0,0 +1,147 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"goalhero-emailer/pkg/redis"
+	"goalhero-emailer/pkg/redistest"
+)
+
+// swap is swapScript in Go for redistest.
+func swap(db *redistest.DB, keys, args []string) any {
+	version, _ := db.HGet(keys[0], "version")
+	if version != args[0] {
+		return int64(0)
+	}
+	db.HSet(keys[0], "version", args[1])
+	db.HSet(keys[0], "data", args[2])
+	return int64(1)
+}
+
+// increment adds one to the counter stored as the document.
+func increment(doc []byte) ([]byte, error) {
+	n, _ := strconv.Atoi(string(doc))
+	return []byte(strconv.Itoa(n + 1)), nil
+}
+
+// testConcurrentUpdates runs updates from several stores on the same
+// document, as separate instances would, and checks none was lost.
+func testConcurrentUpdates(t *testing.T, stores []Store) {
+	const perStore = 25
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perStore {
+				if err := s.Update(ctx, increment); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	doc, err := stores[0].Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.Itoa(len(stores) * perStore); string(doc) != want {
+		t.Errorf("counter = %s, want %s", doc, want)
+	}
+}
+
+func testUpdateError(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.Update(ctx, func([]byte) ([]byte, error) { return []byte("1"), nil }); err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	err := s.Update(ctx, func([]byte) ([]byte, error) { return []byte("2"), boom })
+	if !errors.Is(err, boom) {
+		t.Errorf("Update = %v, want %v", err, boom)
+	}
+	if doc, _ := s.Load(ctx); string(doc) != "1" {
+		t.Errorf("failed update wrote %q", doc)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "doc.json")
+	var stores []Store
+	for range 4 {
+		f, err := NewFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores = append(stores, f)
+	}
+
+	if doc, err := stores[0].Load(context.Background()); doc != nil || err != nil {
+		t.Fatalf("Load of a new document = %q, %v", doc, err)
+	}
+	testConcurrentUpdates(t, stores)
+	testUpdateError(t, stores[0])
+}
+
+func TestNewFileUnwritable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(filepath.Join(dir, "doc.json")); err == nil {
+		t.Error("NewFile succeeded under a file")
+	}
+}
+
+func TestRedis(t *testing.T) {
+	srv := redistest.NewServer(t)
+	srv.HandleScript(swapScript, swap)
+
+	var stores []Store
+	for range 4 {
+		client, err := redis.New(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores = append(stores, NewRedis(client, "test:doc"))
+	}
+
+	if doc, err := stores[0].Load(context.Background()); doc != nil || err != nil {
+		t.Fatalf("Load of a new document = %q, %v", doc, err)
+	}
+	testConcurrentUpdates(t, stores)
+	testUpdateError(t, stores[0])
+
+	evals := 0
+	for _, c := range srv.Calls() {
+		if c == "EVAL" {
+			evals++
+		}
+	}
+	if evals > len(stores) {
+		t.Errorf("%d EVALs; the script should be sent once per connection at most", evals)
+	}
+}
+
+func TestRedisGivesUp(t *testing.T) {
+	srv := redistest.NewServer(t)
+	srv.HandleScript(swapScript, func(*redistest.DB, []string, []string) any { return int64(0) })
+	client, err := redis.New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewRedis(client, "test:doc").Update(context.Background(), increment)
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Update = %v, want ErrConflict", err)
+	}
+}
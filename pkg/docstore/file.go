@@ -0,0 +1,84 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File keeps the document at a path, for a single server or several
+// sharing a volume. Updates hold an exclusive lock on path+".lock" and
+// replace the document with a rename, so readers never see a half-written
+// one.
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFile returns a File for path, creating its directory. It fails if the
+// directory isn't writable, rather than on the first update.
+func NewFile(path string) (*File, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("data directory %s is not writable: %v", dir, err)
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("data directory %s is not writable: %v", dir, err)
+	}
+	lock.Close()
+	return &File{path: path}, nil
+}
+
+func (f *File) Load(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f *File) Update(ctx context.Context, fn func(doc []byte) ([]byte, error)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	lock, err := os.OpenFile(f.path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("lock %s: %v", f.path, err)
+	}
+	defer unlockFile(lock)
+
+	doc, err := f.Load(ctx)
+	if err != nil {
+		return err
+	}
+	next, err := fn(doc)
+	if err != nil {
+		return err
+	}
+	return writeFile(f.path, next)
+}
+
+// writeFile atomically replaces path with data.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package docstore
+
+import "os"
+
+// Without flock, File only excludes updates within this process.
+
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
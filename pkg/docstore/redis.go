@@ -0,0 +1,99 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"goalhero-emailer/pkg/redis"
+)
+
+// swapScript writes a new version of the document only if nobody else has
+// since the caller read it.
+const swapScript = `
+local version = redis.call('HGET', KEYS[1], 'version') or ''
+if version ~= ARGV[1] then return 0 end
+redis.call('HSET', KEYS[1], 'version', ARGV[2], 'data', ARGV[3])
+return 1
+`
+
+var swapDoc = redis.NewScript(swapScript)
+
+const (
+	maxSwapAttempts = 20
+	swapBackoff     = 10 * time.Millisecond
+)
+
+// Redis keeps the document in a Redis hash, next to a version number that
+// makes updates a compare-and-swap: one that lost a race re-reads the
+// document and tries again.
+type Redis struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedis(client *redis.Client, key string) *Redis {
+	return &Redis{client: client, key: key}
+}
+
+func (r *Redis) Load(ctx context.Context) ([]byte, error) {
+	_, doc, err := r.get(ctx)
+	return doc, err
+}
+
+func (r *Redis) Update(ctx context.Context, fn func(doc []byte) ([]byte, error)) error {
+	for attempt := 1; ; attempt++ {
+		version, doc, err := r.get(ctx)
+		if err != nil {
+			return err
+		}
+		next, err := fn(doc)
+		if err != nil {
+			return err
+		}
+		ok, err := r.swap(ctx, version, next)
+		if err != nil || ok {
+			return err
+		}
+		if attempt == maxSwapAttempts {
+			return ErrConflict
+		}
+		select {
+		case <-time.After(rand.N(time.Duration(attempt) * swapBackoff)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Redis) get(ctx context.Context) (version string, doc []byte, err error) {
+	reply, err := r.client.Do(ctx, "HMGET", r.key, "version", "data")
+	if err != nil {
+		return "", nil, err
+	}
+	fields, ok := reply.([]any)
+	if !ok || len(fields) != 2 {
+		return "", nil, fmt.Errorf("redis: unexpected reply %T to HMGET %s", reply, r.key)
+	}
+	version, _ = fields[0].(string)
+	if data, ok := fields[1].(string); ok {
+		doc = []byte(data)
+	}
+	return version, doc, nil
+}
+
+func (r *Redis) swap(ctx context.Context, version string, doc []byte) (bool, error) {
+	n, _ := strconv.Atoi(version)
+	reply, err := r.client.Eval(ctx, swapDoc, []string{r.key}, version, strconv.Itoa(n+1), string(doc))
+	if err != nil {
+		return false, err
+	}
+	swapped, ok := reply.(int64)
+	if !ok {
+		return false, errors.New("redis: unexpected reply to document swap script")
+	}
+	return swapped == 1, nil
+}
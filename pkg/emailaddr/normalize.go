@@ -0,0 +1,34 @@
+// Package emailaddr validates and normalizes email addresses.
+package emailaddr
+
+import "strings"
+
+// gmailDomains are the domains that ignore dots and "+tag" suffixes in the
+// local part.
+var gmailDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+}
+
+// Normalize returns the canonical form used to detect duplicate signups:
+// trimmed and case-folded, and, when gmail is true, with Gmail's dots and
+// "+tag" suffix removed and googlemail.com folded into gmail.com.
+func Normalize(email string, gmail bool) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !gmail {
+		return email
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if !gmailDomains[domain] {
+		return email
+	}
+
+	local, _, _ = strings.Cut(local, "+")
+	local = strings.ReplaceAll(local, ".", "")
+	return local + "@gmail.com"
+}
@@ -0,0 +1,97 @@
+package emailaddr
+
+import "strings"
+
+// popularDomains are providers most signups use. A domain one edit away
+// from one of these (and not itself listed) is probably a typo. Real
+// providers that are one edit from another, such as ymail.com and
+// gmail.com, are listed so they are never "corrected".
+var popularDomains = []string{
+	"gmail.com", "googlemail.com", "yahoo.com", "yahoo.es", "yahoo.co.uk",
+	"yahoo.fr", "yahoo.de", "yahoo.it", "ymail.com", "rocketmail.com",
+	"hotmail.com", "hotmail.es", "hotmail.co.uk", "hotmail.it", "hotmail.fr",
+	"hotmail.de", "outlook.com", "outlook.es", "outlook.fr", "outlook.de",
+	"live.com", "live.co.uk", "msn.com", "icloud.com", "me.com", "mac.com",
+	"aol.com", "aim.com", "mail.com", "email.com", "gmx.com", "gmx.de",
+	"gmx.net", "gmx.es", "web.de", "protonmail.com", "proton.me", "pm.me",
+	"fastmail.com", "zoho.com", "yandex.com", "mail.ru", "libero.it",
+	"orange.fr", "telefonica.net", "movistar.es",
+}
+
+// knownTypos maps frequent misspellings that are more than one edit away,
+// which the edit distance check in suggestDomain misses.
+var knownTypos = map[string]string{
+	"gmail.es":   "gmail.com",
+	"gmial.co":   "gmail.com",
+	"gamil.co":   "gmail.com",
+	"gmal.con":   "gmail.com",
+	"hotmial.co": "hotmail.com",
+	"hotmal.co":  "hotmail.com",
+	"yaho.con":   "yahoo.com",
+	"outlok.co":  "outlook.com",
+}
+
+// Suggest returns address with its domain corrected when the domain looks
+// like a typo of a popular provider, and "" otherwise. It is only a hint:
+// the address may be right as typed, so it is never rejected for it.
+func Suggest(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	if fixed := suggestDomain(strings.ToLower(address[at+1:])); fixed != "" {
+		return address[:at+1] + fixed
+	}
+	return ""
+}
+
+// suggestDomain returns the domain the user probably meant, or "".
+func suggestDomain(domain string) string {
+	if fixed, ok := knownTypos[domain]; ok {
+		return fixed
+	}
+	for _, d := range popularDomains {
+		if d == domain {
+			return ""
+		}
+	}
+	// Short domains are too close to each other for a one-edit match
+	// to mean anything.
+	if len(domain) < 8 {
+		return ""
+	}
+	for _, d := range popularDomains {
+		if editDistanceAtMostOne(domain, d) {
+			return d
+		}
+	}
+	return ""
+}
+
+// editDistanceAtMostOne reports whether a and b differ by at most one
+// insertion, deletion, substitution or adjacent transposition.
+func editDistanceAtMostOne(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if len(a) == len(b) {
+		// substitution or transposition
+		if a[i+1:] == b[i+1:] {
+			return true
+		}
+		return i+1 < len(a) && a[i] == b[i+1] && a[i+1] == b[i] && a[i+2:] == b[i+2:]
+	}
+	// insertion into a
+	return a[i:] == b[i+1:]
+}
@@ -0,0 +1,235 @@
+package emailaddr
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// RFC 5321 limits.
+const (
+	maxAddressLength = 254
+	maxLocalLength   = 64
+	maxDomainLength  = 253
+	maxLabelLength   = 63
+)
+
+// defaultLookupTimeout bounds the MX checks of a signup when
+// Validator.LookupTimeout is unset.
+const defaultLookupTimeout = 2 * time.Second
+
+// Reason says why an address was rejected.
+type Reason string
+
+const (
+	ReasonSyntax     Reason = "syntax"
+	ReasonTooLong    Reason = "too_long"
+	ReasonDisposable Reason = "disposable"
+	ReasonNoMX       Reason = "no_mx"
+)
+
+// InvalidError is returned for addresses that fail validation.
+type InvalidError struct {
+	Reason Reason
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("invalid email address (%s)", e.Reason)
+}
+
+// Resolver is the subset of *net.Resolver used for MX checks, so tests can
+// substitute a fake and run offline.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Validator checks signup addresses. The zero value checks syntax only.
+type Validator struct {
+	// BlockDisposable rejects throwaway mailbox providers.
+	BlockDisposable bool
+	// Resolver, when set, requires the domain to accept mail (MX, or
+	// A/AAAA as the implicit MX). Lookup failures other than "no such
+	// host" are not held against the address, nor are lookups that time
+	// out.
+	Resolver Resolver
+	// LookupTimeout bounds the lookups for one address; 2s if zero.
+	LookupTimeout time.Duration
+}
+
+//go:embed disposable.txt
+var disposableList string
+
+var disposableDomains = func() map[string]bool {
+	domains := make(map[string]bool)
+	s := bufio.NewScanner(strings.NewReader(disposableList))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			domains[strings.ToLower(line)] = true
+		}
+	}
+	return domains
+}()
+
+// Validate parses input as a bare RFC 5322 address and returns it with
+// the domain in lower-case ASCII (IDN domains are punycode-encoded), ready
+// to hand to a transport. It returns *InvalidError for rejected addresses.
+func (v *Validator) Validate(ctx context.Context, input string) (string, error) {
+	input = strings.TrimSpace(input)
+
+	parsed, err := mail.ParseAddress(input)
+	if err != nil || parsed.Name != "" || parsed.Address != input {
+		return "", &InvalidError{Reason: ReasonSyntax}
+	}
+
+	at := strings.LastIndex(parsed.Address, "@")
+	local, domain := parsed.Address[:at], strings.TrimSuffix(parsed.Address[at+1:], ".")
+	if strings.HasPrefix(domain, "[") {
+		// Address literals are legal but never a real signup.
+		return "", &InvalidError{Reason: ReasonSyntax}
+	}
+
+	domain, err = toASCII(domain)
+	if err != nil {
+		return "", &InvalidError{Reason: ReasonSyntax}
+	}
+	if reason := checkDomain(domain); reason != "" {
+		return "", &InvalidError{Reason: reason}
+	}
+	address := local + "@" + domain
+	if len(local) > maxLocalLength || len(address) > maxAddressLength {
+		return "", &InvalidError{Reason: ReasonTooLong}
+	}
+
+	if v.BlockDisposable && isDisposable(domain) {
+		return "", &InvalidError{Reason: ReasonDisposable}
+	}
+	if v.Resolver != nil {
+		timeout := v.LookupTimeout
+		if timeout <= 0 {
+			timeout = defaultLookupTimeout
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := checkMX(lookupCtx, v.Resolver, domain)
+		cancel()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return address, nil
+}
+
+// toASCII converts an internationalized domain to its ASCII (A-label)
+// form, mapping case and width the way browsers do: "Bücher.de" becomes
+// "xn--bcher-kva.de" and "ｇｍａｉｌ.com" becomes "gmail.com". Beyond
+// what UTS 46 allows, labels may only hold letters, marks and digits, as
+// in IDNA2008, which rules out emoji and other symbols.
+func toASCII(domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	decoded, err := idna.Lookup.ToUnicode(ascii)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range decoded {
+		if r >= utf8.RuneSelf && !unicode.In(r, unicode.L, unicode.M, unicode.Nd) {
+			return "", fmt.Errorf("idna: disallowed rune %U", r)
+		}
+	}
+	return ascii, nil
+}
+
+// checkDomain enforces hostname syntax on an ASCII domain and requires at
+// least two labels with an alphabetic (or punycode) TLD, which rules out
+// "a@b" and "a@localhost".
+func checkDomain(domain string) Reason {
+	if len(domain) > maxDomainLength {
+		return ReasonTooLong
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return ReasonSyntax
+	}
+	for _, label := range labels {
+		if len(label) > maxLabelLength {
+			return ReasonTooLong
+		}
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return ReasonSyntax
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return ReasonSyntax
+			}
+		}
+	}
+	tld := labels[len(labels)-1]
+	if !strings.HasPrefix(tld, "xn--") {
+		if len(tld) < 2 {
+			return ReasonSyntax
+		}
+		for i := 0; i < len(tld); i++ {
+			if tld[i] < 'a' || tld[i] > 'z' {
+				return ReasonSyntax
+			}
+		}
+	}
+	return ""
+}
+
+func isDisposable(domain string) bool {
+	for d := domain; ; {
+		if disposableDomains[d] {
+			return true
+		}
+		i := strings.Index(d, ".")
+		if i < 0 {
+			return false
+		}
+		d = d[i+1:]
+	}
+}
+
+func checkMX(ctx context.Context, r Resolver, domain string) error {
+	mxs, err := r.LookupMX(ctx, domain)
+	if err == nil && len(mxs) > 0 {
+		// RFC 7505 null MX: the domain explicitly accepts no mail.
+		if len(mxs) == 1 && (mxs[0].Host == "." || mxs[0].Host == "") {
+			return &InvalidError{Reason: ReasonNoMX}
+		}
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return nil
+	}
+
+	// No MX records: RFC 5321 falls back to the A/AAAA records.
+	addrs, err := r.LookupHost(ctx, domain)
+	if err == nil && len(addrs) > 0 {
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return nil
+	}
+	return &InvalidError{Reason: ReasonNoMX}
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,233 @@
+package emailaddr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers from maps; unknown names are NXDOMAIN.
+type fakeResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+	err   error // returned for every lookup when set
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (f *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if mx, ok := f.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, notFound(name)
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if addrs, ok := f.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, notFound(host)
+}
+
+// slowResolver never answers, like an unreachable DNS server, and returns
+// the context's error once it is done.
+type slowResolver struct{}
+
+func (slowResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	<-ctx.Done()
+	return nil, &net.DNSError{Err: ctx.Err().Error(), Name: name, IsTimeout: true}
+}
+
+func (slowResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	<-ctx.Done()
+	return nil, &net.DNSError{Err: ctx.Err().Error(), Name: host, IsTimeout: true}
+}
+
+func reasonOf(err error) Reason {
+	var invalid *InvalidError
+	if errors.As(err, &invalid) {
+		return invalid.Reason
+	}
+	return ""
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		reason Reason
+	}{
+		{input: "jane@example.com", want: "jane@example.com"},
+		{input: "  Jane.Doe+beta@Example.COM ", want: "Jane.Doe+beta@example.com"},
+
+		// Typos of popular domains are accepted; see Suggest.
+		{input: "jane@gmial.com", want: "jane@gmial.com"},
+		{input: "jane@ymail.com", want: "jane@ymail.com"},
+		{input: "jane@mail.com", want: "jane@mail.com"},
+
+		// Internationalized domains are stored as punycode.
+		{input: "jane@bücher.de", want: "jane@xn--bcher-kva.de"},
+		{input: "jane@MÜNCHEN.de", want: "jane@xn--mnchen-3ya.de"},
+		{input: "jane@españa.com", want: "jane@xn--espaa-rta.com"},
+		{input: "jane@例え.jp", want: "jane@xn--r8jz45g.jp"},
+		{input: "jane@почта.рф", want: "jane@xn--80a1acny.xn--p1ai"},
+		{input: "jane@xn--bcher-kva.de", want: "jane@xn--bcher-kva.de"},
+		{input: "jane@ｇｍａｉｌ.com", want: "jane@gmail.com"},
+		{input: "jane@😀.com", reason: ReasonSyntax},
+		{input: "jane@xn--e28h.com", reason: ReasonSyntax},
+		{input: "jane@xn--zz.com", reason: ReasonSyntax},
+
+		{input: "", reason: ReasonSyntax},
+		{input: "foo", reason: ReasonSyntax},
+		{input: "a@b", reason: ReasonSyntax},
+		{input: "jane@localhost", reason: ReasonSyntax},
+		{input: "Jane <jane@example.com>", reason: ReasonSyntax},
+		{input: "jane@[127.0.0.1]", reason: ReasonSyntax},
+		{input: "jane@-example.com", reason: ReasonSyntax},
+		{input: "jane@example.c", reason: ReasonSyntax},
+		{input: "jane@example.123", reason: ReasonSyntax},
+		{input: "jane@exa_mple.com", reason: ReasonSyntax},
+		{input: "jane@example..com", reason: ReasonSyntax},
+		{input: strings.Repeat("a", 65) + "@example.com", reason: ReasonTooLong},
+		{input: "jane@" + strings.Repeat("a", 64) + ".com", reason: ReasonTooLong},
+		{input: "jane@" + strings.Repeat("abcdefghi.", 26) + "com", reason: ReasonTooLong},
+
+		{input: "jane@mailinator.com", reason: ReasonDisposable},
+		{input: "jane@eu.mailinator.com", reason: ReasonDisposable},
+	}
+	v := &Validator{BlockDisposable: true}
+	for _, tt := range tests {
+		got, err := v.Validate(context.Background(), tt.input)
+		if reason := reasonOf(err); reason != tt.reason || (err != nil && reason == "") {
+			t.Errorf("Validate(%q) error = %v, want reason %q", tt.input, err, tt.reason)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Validate(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAllowsDisposable(t *testing.T) {
+	got, err := (&Validator{}).Validate(context.Background(), "jane@mailinator.com")
+	if err != nil || got != "jane@mailinator.com" {
+		t.Errorf("Validate = %q, %v", got, err)
+	}
+}
+
+func TestValidateMX(t *testing.T) {
+	r := &fakeResolver{
+		mx: map[string][]*net.MX{
+			"example.com":      {{Host: "mx.example.com.", Pref: 10}},
+			"nullmx.example":   {{Host: ".", Pref: 0}},
+			"xn--bcher-kva.de": {{Host: "mx.xn--bcher-kva.de.", Pref: 10}},
+			"several.example":  {{Host: ".", Pref: 0}, {Host: "mx.several.example.", Pref: 10}},
+		},
+		hosts: map[string][]string{"a-only.example": {"192.0.2.1"}},
+	}
+	tests := []struct {
+		input  string
+		reason Reason
+	}{
+		{input: "jane@example.com"},
+		{input: "jane@bücher.de"},
+		{input: "jane@a-only.example"},
+		{input: "jane@several.example"},
+		{input: "jane@nullmx.example", reason: ReasonNoMX},
+		{input: "jane@missing.example", reason: ReasonNoMX},
+	}
+	v := &Validator{Resolver: r}
+	for _, tt := range tests {
+		_, err := v.Validate(context.Background(), tt.input)
+		if reason := reasonOf(err); reason != tt.reason || (err != nil && reason == "") {
+			t.Errorf("Validate(%q) error = %v, want reason %q", tt.input, err, tt.reason)
+		}
+	}
+
+	// A failing resolver is not held against the address.
+	v.Resolver = &fakeResolver{err: &net.DNSError{Err: "server misbehaving", Name: "example.org", IsTemporary: true}}
+	if _, err := v.Validate(context.Background(), "jane@example.org"); err != nil {
+		t.Errorf("Validate with a failing resolver: %v", err)
+	}
+
+	// Nor is one that doesn't answer in time.
+	v = &Validator{Resolver: slowResolver{}, LookupTimeout: 10 * time.Millisecond}
+	start := time.Now()
+	if _, err := v.Validate(context.Background(), "jane@example.org"); err != nil {
+		t.Errorf("Validate with a slow resolver: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Validate with a slow resolver took %v", elapsed)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := map[string]string{
+		"jane@gmial.com":      "jane@gmail.com",
+		"Jane@GMAIL.CON":      "Jane@gmail.com",
+		"jane@hotmial.com":    "jane@hotmail.com",
+		"jane@gmaill.com":     "jane@gmail.com",
+		"jane@outlok.com":     "jane@outlook.com",
+		"jane@yahho.com":      "jane@yahoo.com",
+		"jane@iclould.com":    "jane@icloud.com",
+		"jane@protonmial.com": "jane@protonmail.com",
+		"jane@gmail.co":       "jane@gmail.com",
+		"jane@yaho.com":       "jane@yahoo.com",
+		// Two edits away, from knownTypos.
+		"jane@gmial.co":  "jane@gmail.com",
+		"jane@hotmal.co": "jane@hotmail.com",
+		"jane@gmail.es":  "jane@gmail.com",
+
+		// Real providers close to a popular one.
+		"jane@ymail.com":        "",
+		"jane@mail.com":         "",
+		"jane@email.com":        "",
+		"jane@gmx.com":          "",
+		"jane@hotmail.de":       "",
+		"jane@outlook.fr":       "",
+		"jane@gmail.com":        "",
+		"jane@example.com":      "",
+		"jane@xn--bcher-kva.de": "",
+		// Too short to judge.
+		"jane@gmx.dx":    "",
+		"not-an-address": "",
+	}
+	for input, want := range tests {
+		if got := Suggest(input); got != want {
+			t.Errorf("Suggest(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := map[string]string{
+		"example.com": "example.com",
+		"Bücher.de":   "xn--bcher-kva.de",
+		"例え.jp":       "xn--r8jz45g.jp",
+		"почта.рф":    "xn--80a1acny.xn--p1ai",
+		"ñ.ñ.example": "xn--ida.xn--ida.example",
+		"ｇｍａｉｌ.com":   "gmail.com",
+		"a。example":   "a.example",
+	}
+	for input, want := range tests {
+		if got, err := toASCII(input); err != nil || got != want {
+			t.Errorf("toASCII(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"😀.com", "♥.com", "xn--e28h.com", "exa_mple.com"} {
+		if got, err := toASCII(input); err == nil {
+			t.Errorf("toASCII(%q) = %q, want an error", input, got)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Package formtoken mints and checks the signed timestamps a signup form
+// embeds when it is rendered. A submission carrying one proves the form
+// was fetched first and shows how long it took to fill in; bots tend to
+// post instantly or replay a stale token.
+//
+// A token is not tied to a client or a submission: whoever fetched one may
+// send it with any number of signups until it expires. Binding it to the
+// client address would silently drop people whose address changes while
+// the form is open, so reuse is left to the rate limits and CAPTCHA, and
+// a shorter maximum age narrows the window.
+package formtoken
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"goalhero-emailer/pkg/signing"
+)
+
+const purpose = "form"
+
+// Path is where forms fetch a token.
+const Path = "/api/form-token"
+
+var (
+	ErrTooFast = errors.New("form submitted too quickly")
+	ErrExpired = errors.New("form token expired")
+)
+
+// Token returns a signed token recording that a form was rendered at
+// issued.
+func Token(s *signing.Signer, issued time.Time) string {
+	return s.Sign(purpose, []byte(strconv.FormatInt(issued.UnixMilli(), 10)))
+}
+
+// Verify checks that token is authentic and that between minAge and
+// maxAge passed from issue to now.
+func Verify(s *signing.Signer, token string, now time.Time, minAge, maxAge time.Duration) error {
+	payload, err := s.Verify(purpose, token)
+	if err != nil {
+		return err
+	}
+	ms, err := strconv.ParseInt(string(payload), 10, 64)
+	if err != nil {
+		return signing.ErrInvalidToken
+	}
+	age := now.Sub(time.UnixMilli(ms))
+	switch {
+	case age < minAge:
+		return ErrTooFast
+	case age > maxAge:
+		return ErrExpired
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package formtoken
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/signing"
+	"goalhero-emailer/pkg/unsubscribe"
+)
+
+func TestVerify(t *testing.T) {
+	s := signing.New("0123456789abcdef0123456789abcdef")
+	issued := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	token := Token(s, issued)
+	p, m, _ := strings.Cut(token, ".")
+	// An earlier timestamp under the genuine MAC, to pass the fill time.
+	forged, _, _ := strings.Cut(Token(s, issued.Add(-time.Hour)), ".")
+	tampered := "A" + m[1:]
+	if m[0] == 'A' {
+		tampered = "B" + m[1:]
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		age   time.Duration
+		err   error
+	}{
+		{name: "valid", token: token, age: time.Minute},
+		{name: "at min age", token: token, age: 2 * time.Second},
+		{name: "at max age", token: token, age: time.Hour},
+		{name: "too fast", token: token, age: time.Second, err: ErrTooFast},
+		{name: "issued in the future", token: token, age: -time.Minute, err: ErrTooFast},
+		{name: "expired", token: token, age: time.Hour + time.Millisecond, err: ErrExpired},
+		{name: "other secret", token: Token(signing.New("another secret"), issued), age: time.Minute, err: signing.ErrInvalidToken},
+		{name: "forged timestamp", token: forged + "." + m, age: time.Minute, err: signing.ErrInvalidToken},
+		{name: "tampered mac", token: p + "." + tampered, age: time.Minute, err: signing.ErrInvalidToken},
+		{name: "other purpose", token: unsubscribe.Token(s, strconv.FormatInt(issued.UnixMilli(), 10)), age: time.Minute, err: signing.ErrInvalidToken},
+		{name: "not a timestamp", token: s.Sign(purpose, []byte("soon")), age: time.Minute, err: signing.ErrInvalidToken},
+		{name: "empty", token: "", age: time.Minute, err: signing.ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(s, tt.token, issued.Add(tt.age), 2*time.Second, time.Hour)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Verify = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
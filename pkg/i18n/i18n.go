@@ -0,0 +1,185 @@
+// Package i18n holds the message catalogs used by the email templates,
+// pages and API responses. Each file in locales/ is one catalog named
+// after its BCP 47 tag; adding a language is a matter of adding a file.
+// Lookups fall back from the requested tag to its parents and finally to
+// DefaultLocale, so a regional catalog like es-AR.json only needs the
+// strings that differ from es.json.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalogs maps canonical tag -> flattened key ("welcome.subject") -> value.
+// Values are either string or []string.
+var catalogs = mustLoad()
+
+func mustLoad() map[string]map[string]any {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	catalogs := make(map[string]map[string]any)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		tag, err := ParseTag(name)
+		if err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", e.Name(), err))
+		}
+
+		data, err := files.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var raw map[string]any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", e.Name(), err))
+		}
+
+		messages := make(map[string]any)
+		if err := flatten("", raw, messages); err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", e.Name(), err))
+		}
+		catalogs[tag] = messages
+	}
+
+	if _, ok := catalogs[DefaultLocale]; !ok {
+		panic("i18n: missing default catalog " + DefaultLocale)
+	}
+	return catalogs
+}
+
+func flatten(prefix string, in map[string]any, out map[string]any) error {
+	for k, v := range in {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case string:
+			out[key] = v
+		case []any:
+			list := make([]string, len(v))
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("%s[%d]: want string, got %T", key, i, item)
+				}
+				list[i] = s
+			}
+			out[key] = list
+		case map[string]any:
+			if err := flatten(key, v, out); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: want string, list or object, got %T", key, v)
+		}
+	}
+	return nil
+}
+
+// Supported lists the tags that have a catalog, sorted.
+func Supported() []string {
+	tags := make([]string, 0, len(catalogs))
+	for tag := range catalogs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// UnsupportedError reports a tag with no catalog for it or its parents.
+type UnsupportedError struct {
+	Tag string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("language %q is not supported (supported: %s)", e.Tag, strings.Join(Supported(), ", "))
+}
+
+// Match resolves a requested tag to the most specific supported locale:
+// "es-AR" matches es-AR.json if present, else es.json. Tags whose language
+// has no catalog at all are reported as *UnsupportedError rather than
+// silently served in DefaultLocale.
+func Match(tag string) (string, error) {
+	canonical, err := ParseTag(tag)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range Parents(canonical) {
+		if _, ok := catalogs[t]; ok {
+			return t, nil
+		}
+	}
+	return "", &UnsupportedError{Tag: canonical}
+}
+
+// Localizer looks up messages for one locale with fallback.
+type Localizer struct {
+	Locale string
+	chain  []map[string]any
+}
+
+// New returns a Localizer for a tag previously resolved by Match. Lookups
+// walk the tag, its parents, then DefaultLocale.
+func New(locale string) *Localizer {
+	l := &Localizer{Locale: locale}
+	for _, t := range append(Parents(locale), DefaultLocale) {
+		if c, ok := catalogs[t]; ok {
+			l.chain = append(l.chain, c)
+		}
+	}
+	return l
+}
+
+func (l *Localizer) lookup(key string) (any, bool) {
+	for _, c := range l.chain {
+		if v, ok := c[key]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// T returns the string for key, or the key itself if no catalog has it so
+// missing copy is visible rather than blank.
+func (l *Localizer) T(key string) string {
+	if v, ok := l.lookup(key); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return key
+}
+
+// List returns the list for key, or nil.
+func (l *Localizer) List(key string) []string {
+	if v, ok := l.lookup(key); ok {
+		if s, ok := v.([]string); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// Format returns T(key) with "{name}" placeholders replaced from args,
+// given as name/value pairs: Format("confirm.expiry", "hours", 48).
+func (l *Localizer) Format(key string, args ...any) string {
+	s := l.T(key)
+	for i := 0; i+1 < len(args); i += 2 {
+		s = strings.ReplaceAll(s, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{input: "es", want: "es"},
+		{input: "ES_ar", want: "es-AR"},
+		{input: " es-ar ", want: "es-AR"},
+		{input: "es-419", want: "es-419"},
+		{input: "zh-hant-tw", want: "zh-Hant-TW"},
+		{input: "de-DE-1996", want: "de-DE-1996"},
+		{input: "sl-rozaj", want: "sl-rozaj"},
+		{input: "", err: true},
+		{input: "e", err: true},
+		{input: "abcd", err: true},
+		{input: "123", err: true},
+		{input: "es-", err: true},
+		{input: "es-A", err: true},
+		{input: "en-u-ca-gregory", err: true},
+		{input: "x-private", err: true},
+		{input: "es;q=0.5", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTag(tt.input)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseTag(%q) = %q, %v", tt.input, got, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        string
+		unsupported bool
+		invalid     bool
+	}{
+		{input: "en", want: "en"},
+		{input: "EN-gb", want: "en"},
+		{input: "es", want: "es"},
+		// No es-AR catalog, so its parent serves it.
+		{input: "es-AR", want: "es"},
+		{input: "es_419", want: "es"},
+		// No pt catalog at all: an error, not English.
+		{input: "pt-BR", unsupported: true},
+		{input: "pt", unsupported: true},
+		{input: "not a tag", invalid: true},
+	}
+	for _, tt := range tests {
+		got, err := Match(tt.input)
+		var unsupported *UnsupportedError
+		switch {
+		case tt.unsupported:
+			if !errors.As(err, &unsupported) {
+				t.Errorf("Match(%q) = %q, %v; want *UnsupportedError", tt.input, got, err)
+			}
+		case tt.invalid:
+			if err == nil || errors.As(err, &unsupported) {
+				t.Errorf("Match(%q) = %q, %v; want a syntax error", tt.input, got, err)
+			}
+		case err != nil || got != tt.want:
+			t.Errorf("Match(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+// TestCatalogKeys checks every catalog against the default one: a
+// language's catalog must have exactly its keys, and a regional catalog
+// such as es-AR may only override some of them.
+func TestCatalogKeys(t *testing.T) {
+	want := catalogs[DefaultLocale]
+	for tag, messages := range catalogs {
+		for key, v := range messages {
+			def, ok := want[key]
+			if !ok {
+				t.Errorf("%s.json: %s is not in %s.json", tag, key, DefaultLocale)
+				continue
+			}
+			if isListValue(v) != isListValue(def) {
+				t.Errorf("%s.json: %s is %T, %s.json has %T", tag, key, v, DefaultLocale, def)
+			}
+		}
+		if len(Parents(tag)) > 1 {
+			continue
+		}
+		for _, key := range slices.Sorted(maps.Keys(want)) {
+			if _, ok := messages[key]; !ok {
+				t.Errorf("%s.json: missing %s", tag, key)
+			}
+		}
+	}
+}
+
+func isListValue(v any) bool {
+	_, ok := v.([]string)
+	return ok
+}
+
+func TestLocalizer(t *testing.T) {
+	l := New("es")
+	if got := l.T("register.already_registered"); got == "" || got == New("en").T("register.already_registered") {
+		t.Errorf("es T = %q", got)
+	}
+	if got := l.T("no.such.key"); got != "no.such.key" {
+		t.Errorf("missing key = %q", got)
+	}
+	if got := New("en").Format("errors.idempotency_key_invalid", "max", 255); got != "Idempotency-Key must be at most 255 characters" {
+		t.Errorf("Format = %q", got)
+	}
+	if got := New("es").List("welcome.features"); len(got) != 3 {
+		t.Errorf("List = %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source records what decided a request's locale.
+type Source string
+
+const (
+	SourceRequest        Source = "request"
+	SourceAcceptLanguage Source = "accept-language"
+	SourceDefault        Source = "default"
+)
+
+// Negotiate picks the best supported locale from an Accept-Language
+// header (RFC 9110 §12.5.4). Ranges are tried in descending quality,
+// ties keeping header order; q=0 and "*" are ignored. It reports false if
+// nothing in the header is supported.
+func Negotiate(header string) (string, bool) {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+	for _, item := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(k), "q") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+		if q == 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{tag: tag, q: q})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	for _, c := range candidates {
+		if locale, err := Match(c.tag); err == nil {
+			return locale, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,37 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "empty", header: ""},
+		{name: "single", header: "es", want: "es"},
+		{name: "regional", header: "es-AR,es;q=0.9", want: "es"},
+		{name: "unsupported first", header: "pt-BR,pt;q=0.9,es;q=0.5", want: "es"},
+		{name: "nothing supported", header: "pt-BR, fr;q=0.8"},
+		{name: "by quality", header: "es;q=0.5, en;q=0.9", want: "en"},
+		{name: "tie keeps header order", header: "en;q=0.8, es;q=0.8", want: "en"},
+		{name: "tie keeps header order, reversed", header: "es;q=0.8, en;q=0.8", want: "es"},
+		{name: "q=0 excludes", header: "es;q=0, en;q=0.1", want: "en"},
+		{name: "only q=0", header: "es;q=0"},
+		{name: "wildcard ignored", header: "*, es;q=0.5", want: "es"},
+		{name: "only wildcard", header: "*"},
+		{name: "spaces and case", header: "  ES-ar ; Q=0.7 ,fr", want: "es"},
+		{name: "malformed q", header: "es;q=abc, en;q=0.2", want: "en"},
+		{name: "q out of range", header: "es;q=2, en;q=0.2", want: "en"},
+		{name: "malformed tags", header: ";;,, ,en-, 12, es", want: "es"},
+		{name: "garbage", header: "\x00\xff;q=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Negotiate(tt.header)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("Negotiate(%q) = %q, %v; want %q", tt.header, got, ok, tt.want)
+			}
+		})
+	}
+}
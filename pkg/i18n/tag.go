@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseTag validates a BCP 47 language tag and returns it in canonical
+// case ("ES_ar" -> "es-AR", "zh-hant-tw" -> "zh-Hant-TW"). Only the
+// language-script-region-variant subset is accepted; extensions and
+// private-use subtags are rejected.
+func ParseTag(s string) (string, error) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, "_", "-"))
+	if s == "" {
+		return "", fmt.Errorf("empty language tag")
+	}
+
+	parts := strings.Split(s, "-")
+	lang := strings.ToLower(parts[0])
+	if !isAlpha(lang) || len(lang) < 2 || len(lang) > 8 || len(lang) == 4 {
+		return "", fmt.Errorf("invalid language tag %q", s)
+	}
+
+	out := []string{lang}
+	for i, p := range parts[1:] {
+		switch {
+		case i == 0 && len(p) == 4 && isAlpha(p):
+			// script, e.g. Hant
+			out = append(out, strings.ToUpper(p[:1])+strings.ToLower(p[1:]))
+		case (len(p) == 2 && isAlpha(p)) || (len(p) == 3 && isDigit(p)):
+			// region, e.g. AR or 419
+			out = append(out, strings.ToUpper(p))
+		case (len(p) >= 5 && len(p) <= 8 && isAlnum(p)) || (len(p) == 4 && isDigit(p[:1]) && isAlnum(p)):
+			// variant
+			out = append(out, strings.ToLower(p))
+		default:
+			return "", fmt.Errorf("invalid language tag %q", s)
+		}
+	}
+	return strings.Join(out, "-"), nil
+}
+
+// Parents returns tag followed by its successively truncated prefixes:
+// "zh-Hant-TW" -> ["zh-Hant-TW", "zh-Hant", "zh"].
+func Parents(tag string) []string {
+	chain := []string{tag}
+	for {
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			return chain
+		}
+		tag = tag[:i]
+		chain = append(chain, tag)
+	}
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isDigit(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isAlnum(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,67 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"goalhero-emailer/pkg/docstore"
+)
+
+// Document is a Store kept in a single JSON document shared by every
+// instance, see docstore, so a retry reaching another instance is still
+// recognized.
+type Document struct {
+	doc docstore.Store
+}
+
+func NewDocument(doc docstore.Store) *Document {
+	return &Document{doc: doc}
+}
+
+// update applies fn to the current records and saves them; nothing is
+// saved if fn fails.
+func (d *Document) update(ctx context.Context, fn func(records map[string]Record) error) error {
+	return d.doc.Update(ctx, func(raw []byte) ([]byte, error) {
+		records := make(map[string]Record)
+		if raw != nil {
+			if err := json.Unmarshal(raw, &records); err != nil {
+				return nil, fmt.Errorf("parse idempotency keys: %v", err)
+			}
+		}
+		if err := fn(records); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(records, "", "  ")
+	})
+}
+
+func (d *Document) Reserve(ctx context.Context, key, fingerprint string) (*Record, error) {
+	var replay *Record
+	err := d.update(ctx, func(records map[string]Record) error {
+		var err error
+		replay, err = reserve(records, key, fingerprint, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+func (d *Document) Complete(ctx context.Context, key string, status int, body []byte) error {
+	return d.update(ctx, func(records map[string]Record) error {
+		r := records[key]
+		r.Status, r.Body, r.Done = status, body, true
+		records[key] = r
+		return nil
+	})
+}
+
+func (d *Document) Release(ctx context.Context, key string) error {
+	return d.update(ctx, func(records map[string]Record) error {
+		delete(records, key)
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+// Package idempotency makes client retries of a POST safe: the first
+// response for an Idempotency-Key is stored and replayed for later requests
+// carrying the same key.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// TTL is how long a key and its response are remembered.
+const TTL = 24 * time.Hour
+
+// Lease is how long a reservation holds its key. It is a little longer than
+// a function may run (5 minutes on Vercel), so a request that crashed or
+// timed out without releasing its key no longer blocks retries.
+const Lease = 6 * time.Minute
+
+var (
+	// ErrInFlight means another request with the same key has not finished.
+	ErrInFlight = errors.New("request with this idempotency key is in progress")
+	// ErrMismatch means the key was used before for a different request.
+	ErrMismatch = errors.New("idempotency key reused with a different request")
+)
+
+type Record struct {
+	Fingerprint string    `json:"fingerprint"`
+	Status      int       `json:"status,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	Done        bool      `json:"done"`
+	CreatedAt   time.Time `json:"created_at"`
+	// LeaseUntil is when an unfinished reservation lapses.
+	LeaseUntil time.Time `json:"lease_until,omitempty"`
+}
+
+// Store remembers keys and their responses.
+type Store interface {
+	// Reserve claims key for a request with fingerprint. It returns the
+	// completed record to replay, ErrInFlight, ErrMismatch, or (nil, nil)
+	// if the caller now owns the key and must Complete or Release it.
+	Reserve(ctx context.Context, key, fingerprint string) (*Record, error)
+	// Complete stores the response for a reserved key.
+	Complete(ctx context.Context, key string, status int, body []byte) error
+	// Release forgets a reserved key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// reserve implements Reserve over a plain map for the Store types.
+func reserve(records map[string]Record, key, fingerprint string, now time.Time) (*Record, error) {
+	for k, r := range records {
+		if now.Sub(r.CreatedAt) > TTL {
+			delete(records, k)
+		}
+	}
+
+	if r, ok := records[key]; ok {
+		switch {
+		case !r.Done && now.After(r.LeaseUntil):
+			// The reservation was abandoned; the key is free again.
+		case r.Fingerprint != fingerprint:
+			return nil, ErrMismatch
+		case !r.Done:
+			return nil, ErrInFlight
+		default:
+			return &r, nil
+		}
+	}
+
+	records[key] = Record{Fingerprint: fingerprint, CreatedAt: now, LeaseUntil: now.Add(Lease)}
+	return nil, nil
+}
+
+// Recorder captures a response so it can be stored with Complete.
+type Recorder struct {
+	http.ResponseWriter
+	Status int
+	Body   []byte
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if r.Status == 0 {
+		r.Status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	r.Body = append(r.Body, b...)
+	return r.ResponseWriter.Write(b)
+}
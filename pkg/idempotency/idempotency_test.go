@@ -0,0 +1,99 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/docstore"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestReserve(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      *Record
+		fingerprint string
+		at          time.Duration
+		wantReplay  bool
+		wantErr     error
+	}{
+		{name: "new key", fingerprint: "a"},
+		{name: "in flight", record: &Record{Fingerprint: "a", LeaseUntil: epoch.Add(Lease)}, fingerprint: "a", at: time.Minute, wantErr: ErrInFlight},
+		{name: "in flight, other request", record: &Record{Fingerprint: "a", LeaseUntil: epoch.Add(Lease)}, fingerprint: "b", at: time.Minute, wantErr: ErrMismatch},
+		{name: "lease lapsed", record: &Record{Fingerprint: "a", LeaseUntil: epoch.Add(Lease)}, fingerprint: "a", at: Lease + time.Second},
+		{name: "lease lapsed, other request", record: &Record{Fingerprint: "a", LeaseUntil: epoch.Add(Lease)}, fingerprint: "b", at: Lease + time.Second},
+		{name: "no lease", record: &Record{Fingerprint: "a"}, fingerprint: "a", at: time.Minute},
+		{name: "done", record: &Record{Fingerprint: "a", Status: 200, Done: true}, fingerprint: "a", at: time.Hour, wantReplay: true},
+		{name: "done, other request", record: &Record{Fingerprint: "a", Status: 200, Done: true}, fingerprint: "b", at: time.Hour, wantErr: ErrMismatch},
+		{name: "expired", record: &Record{Fingerprint: "a", Status: 200, Done: true}, fingerprint: "b", at: TTL + time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make(map[string]Record)
+			if tt.record != nil {
+				r := *tt.record
+				r.CreatedAt = epoch
+				records["key"] = r
+			}
+			now := epoch.Add(tt.at)
+			replay, err := reserve(records, "key", tt.fingerprint, now)
+			if !errors.Is(err, tt.wantErr) || (replay != nil) != tt.wantReplay {
+				t.Fatalf("reserve = %v, %v", replay, err)
+			}
+			if tt.wantErr != nil || tt.wantReplay {
+				return
+			}
+			r := records["key"]
+			if r.Fingerprint != tt.fingerprint || r.Done || !r.CreatedAt.Equal(now) || !r.LeaseUntil.Equal(now.Add(Lease)) {
+				t.Errorf("reserved %+v", r)
+			}
+		})
+	}
+}
+
+// testStore runs a key through reserve, complete and replay, and release.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if r, err := s.Reserve(ctx, "k", "a"); r != nil || err != nil {
+		t.Fatalf("Reserve = %v, %v", r, err)
+	}
+	if _, err := s.Reserve(ctx, "k", "a"); !errors.Is(err, ErrInFlight) {
+		t.Errorf("second Reserve: %v, want ErrInFlight", err)
+	}
+	if err := s.Complete(ctx, "k", 200, []byte("ok")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Reserve(ctx, "k", "a")
+	if err != nil || r == nil || r.Status != 200 || string(r.Body) != "ok" {
+		t.Errorf("Reserve after Complete = %+v, %v", r, err)
+	}
+	if _, err := s.Reserve(ctx, "k", "b"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Reserve for another request: %v, want ErrMismatch", err)
+	}
+
+	if _, err := s.Reserve(ctx, "j", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release(ctx, "j"); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := s.Reserve(ctx, "j", "b"); r != nil || err != nil {
+		t.Errorf("Reserve after Release = %v, %v", r, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := docstore.NewFile(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, NewDocument(doc))
+}
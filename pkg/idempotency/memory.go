@@ -0,0 +1,39 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Store for tests and local development.
+type Memory struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemory() *Memory {
+	return &Memory{records: make(map[string]Record)}
+}
+
+func (m *Memory) Reserve(ctx context.Context, key, fingerprint string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return reserve(m.records, key, fingerprint, time.Now().UTC())
+}
+
+func (m *Memory) Complete(ctx context.Context, key string, status int, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.records[key]
+	r.Status, r.Body, r.Done = status, body, true
+	m.records[key] = r
+	return nil
+}
+
+func (m *Memory) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
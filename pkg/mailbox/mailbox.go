@@ -0,0 +1,111 @@
+// Package mailbox renders the web viewer for emails kept by the capture
+// mailer (MAILER=capture).
+package mailbox
+
+import (
+	"html/template"
+	"net/http"
+
+	"goalhero-emailer/pkg/mailer"
+)
+
+const style = `
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background: #f8fafc; color: #333; margin: 0; padding: 24px; }
+        h1 { font-size: 22px; color: #1a1a1a; margin: 0 0 16px; }
+        a { color: #00994d; }
+        table { width: 100%; border-collapse: collapse; background: #fff; }
+        th, td { text-align: left; padding: 8px 12px; border-bottom: 1px solid #e2e8f0; font-size: 14px; }
+        pre { background: #fff; border: 1px solid #e2e8f0; padding: 12px; overflow: auto; font-size: 13px; white-space: pre-wrap; word-break: break-all; }
+        iframe { width: 100%; height: 70vh; border: 1px solid #e2e8f0; background: #fff; }
+        summary { cursor: pointer; font-weight: 600; margin: 16px 0 8px; }
+        dl { display: grid; grid-template-columns: max-content auto; gap: 4px 16px; font-size: 14px; }
+        dt { font-weight: 600; }
+        button { background: #fff; border: 1px solid #cbd5e1; border-radius: 6px; padding: 6px 14px; cursor: pointer; }
+`
+
+var listPage = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Mailbox</title>
+    <style>` + style + `</style>
+</head>
+<body>
+    <h1>Mailbox ({{len .}})</h1>
+    {{if .}}
+    <p><button onclick="fetch(location.pathname, {method: 'DELETE'}).then(() => location.reload())">Clear</button></p>
+    <table>
+        <tr><th>Date</th><th>To</th><th>Subject</th></tr>
+        {{range .}}<tr>
+            <td>{{.Date.Format "2006-01-02 15:04:05"}}</td>
+            <td>{{.To}}</td>
+            <td><a href="?id={{.ID}}">{{.Subject}}</a></td>
+        </tr>{{end}}
+    </table>
+    {{else}}
+    <p>No emails captured yet.</p>
+    {{end}}
+</body>
+</html>
+`))
+
+var messagePage = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Subject}}</title>
+    <style>` + style + `</style>
+</head>
+<body>
+    <p><a href="?">&larr; Mailbox</a></p>
+    <h1>{{.Subject}}</h1>
+    <dl>
+        <dt>From</dt><dd>{{.From}}</dd>
+        <dt>To</dt><dd>{{.To}}</dd>
+        <dt>Date</dt><dd>{{.Date.Format "2006-01-02 15:04:05 -0700"}}</dd>
+        <dt>Message-ID</dt><dd>{{.MessageID}}</dd>
+    </dl>
+    {{if .HTML}}<details open><summary>HTML</summary>
+        <iframe sandbox src="?id={{.ID}}&amp;part=html" title="HTML part"></iframe>
+    </details>{{end}}
+    {{if .Text}}<details open><summary>Text</summary><pre>{{.Text}}</pre></details>{{end}}
+    <details><summary>Headers</summary><pre>{{.Header}}</pre></details>
+    <details><summary>Raw MIME (<a href="?id={{.ID}}&amp;part=raw">download .eml</a>)</summary><pre>{{printf "%s" .Raw}}</pre></details>
+</body>
+</html>
+`))
+
+// List writes the page listing msgs.
+func List(w http.ResponseWriter, msgs []mailer.Captured) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	listPage.Execute(w, msgs)
+}
+
+// Message writes the page showing m.
+func Message(w http.ResponseWriter, m mailer.Captured) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	messagePage.Execute(w, m)
+}
+
+// Part writes one part of m as is: "html", "text" or "raw" (the .eml
+// file). The HTML is served in a sandbox, so scripts in a template can't
+// run against this origin.
+func Part(w http.ResponseWriter, m mailer.Captured, part string) {
+	switch part {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Write([]byte(m.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(m.Text))
+	case "raw":
+		w.Header().Set("Content-Type", "message/rfc822")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+m.ID+`.eml"`)
+		w.Write(m.Raw)
+	default:
+		http.Error(w, "Unknown part", http.StatusNotFound)
+	}
+}
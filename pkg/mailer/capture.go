@@ -0,0 +1,267 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCaptured bounds the messages a memory Capture keeps.
+const maxCaptured = 200
+
+var ErrCapturedNotFound = errors.New("captured message not found")
+
+var captureIDPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}\.[0-9]{9}-[0-9a-f]{8}$`)
+
+// Captured is a message kept by a Capture. The body fields are only set
+// by Get.
+type Captured struct {
+	ID        string    `json:"id"`
+	MessageID string    `json:"message_id"`
+	Date      time.Time `json:"date"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Subject   string    `json:"subject"`
+
+	// Header is the header section as sent.
+	Header string `json:"header,omitempty"`
+	HTML   string `json:"html,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Raw    []byte `json:"-"`
+}
+
+// Capture is a Mailer that keeps messages instead of sending them, for
+// developing templates. Messages are stored as .eml files in a directory,
+// or the most recent ones in memory.
+type Capture struct {
+	dir string
+
+	mu  sync.Mutex
+	mem []Captured // oldest first
+}
+
+// NewCapture stores messages in dir, or in memory when dir is empty.
+func NewCapture(dir string) (*Capture, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Capture{dir: dir}, nil
+}
+
+func (c *Capture) Send(ctx context.Context, msg *Message) (string, error) {
+	now := time.Now().UTC()
+	messageID := newMessageID(msg.From.Email)
+	raw, err := buildMIME(msg, messageID, now)
+	if err != nil {
+		return "", &SendError{Err: fmt.Errorf("error encoding email: %v", err)}
+	}
+
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	id := now.Format("20060102T150405.000000000") + "-" + hex.EncodeToString(buf)
+
+	if c.dir != "" {
+		if err := os.WriteFile(filepath.Join(c.dir, id+".eml"), raw, 0o644); err != nil {
+			return "", err
+		}
+	} else {
+		c.mu.Lock()
+		c.mem = append(c.mem, Captured{ID: id, Raw: raw})
+		if len(c.mem) > maxCaptured {
+			c.mem = slices.Delete(c.mem, 0, len(c.mem)-maxCaptured)
+		}
+		c.mu.Unlock()
+	}
+
+	log.Printf("Captured email %s to %s: %q", id, msg.To.Email, msg.Subject)
+	return messageID, nil
+}
+
+// List returns the captured messages, newest first, without bodies.
+func (c *Capture) List(ctx context.Context) ([]Captured, error) {
+	raws, err := c.raws()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Captured, 0, len(raws))
+	for i := len(raws) - 1; i >= 0; i-- {
+		m, err := parseCaptured(raws[i].ID, raws[i].Raw)
+		if err != nil {
+			log.Printf("Skipping captured email %s: %v", raws[i].ID, err)
+			continue
+		}
+		m.Header, m.HTML, m.Text, m.Raw = "", "", "", nil
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+// Get returns a captured message with its header, bodies and raw MIME.
+func (c *Capture) Get(ctx context.Context, id string) (Captured, error) {
+	if !captureIDPattern.MatchString(id) {
+		return Captured{}, ErrCapturedNotFound
+	}
+	if c.dir != "" {
+		raw, err := os.ReadFile(filepath.Join(c.dir, id+".eml"))
+		if errors.Is(err, os.ErrNotExist) {
+			return Captured{}, ErrCapturedNotFound
+		}
+		if err != nil {
+			return Captured{}, err
+		}
+		return parseCaptured(id, raw)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.mem {
+		if m.ID == id {
+			return parseCaptured(id, m.Raw)
+		}
+	}
+	return Captured{}, ErrCapturedNotFound
+}
+
+// Clear deletes every captured message.
+func (c *Capture) Clear(ctx context.Context) error {
+	if c.dir == "" {
+		c.mu.Lock()
+		c.mem = nil
+		c.mu.Unlock()
+		return nil
+	}
+	raws, err := c.raws()
+	if err != nil {
+		return err
+	}
+	for _, m := range raws {
+		if err := os.Remove(filepath.Join(c.dir, m.ID+".eml")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// raws returns the stored messages oldest first, with only ID and Raw
+// set.
+func (c *Capture) raws() ([]Captured, error) {
+	if c.dir == "" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return slices.Clone(c.mem), nil
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	var raws []Captured
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".eml")
+		if !ok || !captureIDPattern.MatchString(id) {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(c.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, Captured{ID: id, Raw: raw})
+	}
+	// IDs start with the capture time, and ReadDir sorts by name.
+	return raws, nil
+}
+
+// parseCaptured decodes a message built by buildMIME.
+func parseCaptured(id string, raw []byte) (Captured, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Captured{}, err
+	}
+	dec := new(mime.WordDecoder)
+	decode := func(key string) string {
+		v, err := dec.DecodeHeader(msg.Header.Get(key))
+		if err != nil {
+			return msg.Header.Get(key)
+		}
+		return v
+	}
+
+	m := Captured{
+		ID:        id,
+		MessageID: msg.Header.Get("Message-ID"),
+		From:      decode("From"),
+		To:        decode("To"),
+		Subject:   decode("Subject"),
+		Raw:       raw,
+	}
+	m.Date, _ = msg.Header.Date()
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+		m.Header = string(raw[:i])
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return m, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := decodeBody(msg.Body, msg.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return m, err
+		}
+		m.setBody(mediaType, body)
+		return m, nil
+	}
+
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		// NextPart undoes quoted-printable transfer encoding.
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return m, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(part)
+		if err != nil {
+			return m, err
+		}
+		m.setBody(partType, string(body))
+	}
+}
+
+func (m *Captured) setBody(mediaType, body string) {
+	switch mediaType {
+	case "text/html":
+		m.HTML = body
+	case "text/plain":
+		m.Text = body
+	}
+}
+
+func decodeBody(r io.Reader, encoding string) (string, error) {
+	if strings.EqualFold(encoding, "quoted-printable") {
+		r = quotedprintable.NewReader(r)
+	}
+	body, err := io.ReadAll(r)
+	return string(body), err
+}
@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	for name, dir := range map[string]string{"memory": "", "dir": t.TempDir()} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c, err := NewCapture(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := testMessage()
+			messageID, err := c.Send(ctx, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second := testMessage()
+			second.To = Address{Email: "john@example.com"}
+			if _, err := c.Send(ctx, second); err != nil {
+				t.Fatal(err)
+			}
+
+			list, err := c.List(ctx)
+			if err != nil || len(list) != 2 {
+				t.Fatalf("List = %+v, %v", list, err)
+			}
+			if list[0].To != "<john@example.com>" || list[1].To != "<jane@example.com>" {
+				t.Errorf("List not newest first: %s, %s", list[0].To, list[1].To)
+			}
+			first := list[1]
+			if first.MessageID != messageID || first.Subject != msg.Subject || first.From != `"GoalHero Team" <info@goalhero.eu>` || first.Date.IsZero() {
+				t.Errorf("listed %+v", first)
+			}
+			if first.HTML != "" || first.Text != "" || first.Header != "" || first.Raw != nil {
+				t.Errorf("List returned bodies: %+v", first)
+			}
+
+			got, err := c.Get(ctx, first.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.HTML != msg.HTML || got.Text != msg.Text {
+				t.Errorf("Get bodies:\nhtml %q\ntext %q", got.HTML, got.Text)
+			}
+			if !strings.Contains(got.Header, "List-Unsubscribe: <https://example.com/u>") || strings.Contains(got.Header, msg.Text) {
+				t.Errorf("Get header:\n%s", got.Header)
+			}
+			if !strings.Contains(string(got.Raw), "Content-Type: multipart/alternative") {
+				t.Errorf("Get raw:\n%s", got.Raw)
+			}
+
+			for _, id := range []string{"", "../" + first.ID, "20250301T120000.000000000-00000000"} {
+				if _, err := c.Get(ctx, id); !errors.Is(err, ErrCapturedNotFound) {
+					t.Errorf("Get(%q) = %v, want ErrCapturedNotFound", id, err)
+				}
+			}
+
+			if err := c.Clear(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if list, err := c.List(ctx); err != nil || len(list) != 0 {
+				t.Errorf("List after Clear = %+v, %v", list, err)
+			}
+			if _, err := c.Get(ctx, first.ID); !errors.Is(err, ErrCapturedNotFound) {
+				t.Errorf("Get after Clear = %v", err)
+			}
+		})
+	}
+}
+
+func TestCaptureMemoryCap(t *testing.T) {
+	ctx := context.Background()
+	c, _ := NewCapture("")
+	for i := range maxCaptured + 5 {
+		msg := testMessage()
+		msg.Subject = fmt.Sprintf("Message %d", i)
+		if _, err := c.Send(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := c.List(ctx)
+	if err != nil || len(list) != maxCaptured {
+		t.Fatalf("List kept %d, %v; want %d", len(list), err, maxCaptured)
+	}
+	newest, oldest := list[0].Subject, list[len(list)-1].Subject
+	if newest != fmt.Sprintf("Message %d", maxCaptured+4) || oldest != "Message 5" {
+		t.Errorf("kept %q to %q, want the latest %d", oldest, newest, maxCaptured)
+	}
+}
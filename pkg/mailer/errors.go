@@ -0,0 +1,178 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrInvalidRecipient matches send errors caused by the recipient address
+// being rejected.
+var ErrInvalidRecipient = errors.New("recipient rejected")
+
+// SendError is a failed send, classified so callers know whether sending
+// again can help.
+type SendError struct {
+	// Code is the provider's HTTP status or SMTP reply code; 0 when the
+	// request never got an answer.
+	Code int
+	// Temporary is set for failures worth retrying: rate limiting,
+	// provider outages, timeouts and dropped connections.
+	Temporary bool
+	// RetryAfter is how long the provider asked to wait, if it did.
+	RetryAfter time.Duration
+	// Recipient is set when the recipient address was rejected.
+	Recipient bool
+	// Provider is set for permanent failures of the provider rather than
+	// the message: rejected credentials or sender, or a setup error such
+	// as a bad certificate or unknown host. Another provider may still
+	// send the message.
+	Provider bool
+	Err      error
+}
+
+func (e *SendError) Error() string { return e.Err.Error() }
+
+func (e *SendError) Unwrap() error { return e.Err }
+
+func (e *SendError) Is(target error) bool {
+	return target == ErrInvalidRecipient && e.Recipient
+}
+
+// Classify reports whether sending again may succeed and how long the
+// provider asked to wait first, if it did. Errors that were not
+// classified by a transport are assumed to be transient.
+func Classify(err error) (retry bool, after time.Duration) {
+	var se *SendError
+	if errors.As(err, &se) {
+		return se.Temporary, se.RetryAfter
+	}
+	return err != nil, 0
+}
+
+// providerFault reports whether err is a permanent failure of the
+// provider, see SendError.Provider.
+func providerFault(err error) bool {
+	var se *SendError
+	return errors.As(err, &se) && se.Provider
+}
+
+// netError classifies an error that got no answer from the provider:
+// transient per temporaryNetError, and otherwise the provider's fault
+// unless the caller gave up.
+func netError(err error) *SendError {
+	se := &SendError{Temporary: temporaryNetError(err), Err: err}
+	se.Provider = !se.Temporary && !errors.Is(err, context.Canceled)
+	return se
+}
+
+// temporaryNetError reports whether a transport error is a timeout, a
+// dropped, refused or reset connection, an unreachable network or a DNS
+// failure other than a missing host. Certificate problems and unknown
+// hosts are not: they need a configuration change. Neither is
+// cancellation, since the caller has given up.
+func temporaryNetError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		verify           *tls.CertificateVerificationError
+	)
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) ||
+		errors.As(err, &invalid) || errors.As(err, &verify) {
+		return false
+	}
+
+	var (
+		netErr net.Error
+		dnsErr *net.DNSError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &dnsErr):
+		return !dnsErr.IsNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	return false
+}
+
+// smtpError classifies a failed SMTP step. 4xx replies are transient and
+// 5xx replies permanent (RFC 5321); a 5xx answer to RCPT TO that names
+// the mailbox marks the recipient as invalid. Permanent replies before
+// RCPT TO, and authentication failures, are the provider's fault: it
+// refused us or our sender, not the message.
+func smtpError(op string, err error) error {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		se := netError(err)
+		se.Err = fmt.Errorf("smtp %s: %w", op, err)
+		return se
+	}
+	se := &SendError{Code: reply.Code, Err: fmt.Errorf("smtp %s: %w", op, err)}
+	se.Temporary = reply.Code >= 400 && reply.Code < 500
+	switch {
+	case se.Temporary:
+	case reply.Code == 530, reply.Code == 534, reply.Code == 535, reply.Code == 538:
+		se.Provider = true
+	case op == "RCPT TO":
+		switch reply.Code {
+		case 550, 551, 553:
+			se.Recipient = true
+		}
+	case op != "DATA" && op != "write":
+		se.Provider = true
+	}
+	return se
+}
+
+// httpError classifies a provider's HTTP error status: 408, 429 and 5xx
+// are transient, other 4xx permanent. 401 and 403 mean the provider
+// rejected our credentials or sender, other 4xx the message.
+func httpError(status int, header http.Header, now time.Time, err error) *SendError {
+	return &SendError{
+		Code:       status,
+		Temporary:  status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500,
+		RetryAfter: retryAfter(status, header, now),
+		Provider:   status == http.StatusUnauthorized || status == http.StatusForbidden,
+		Err:        err,
+	}
+}
+
+// retryAfter reads Retry-After (seconds or an HTTP date). A 429 without
+// it falls back to X-RateLimit-Reset (Unix time) as sent by SendGrid.
+func retryAfter(status int, header http.Header, now time.Time) time.Duration {
+	if v := header.Get("Retry-After"); v != "" {
+		if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+			return time.Duration(s) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+	if v := header.Get("X-RateLimit-Reset"); v != "" && status == http.StatusTooManyRequests {
+		if s, err := strconv.ParseInt(v, 10, 64); err == nil {
+			if t := time.Unix(s, 0); t.After(now) {
+				return t.Sub(now)
+			}
+		}
+	}
+	return 0
+}
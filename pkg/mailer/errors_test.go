@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	dial := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+	tests := []struct {
+		name     string
+		err      error
+		retry    bool
+		after    time.Duration
+		provider bool
+	}{
+		{name: "unclassified", err: errors.New("boom"), retry: true},
+		{name: "timeout", err: smtpError("dial", context.DeadlineExceeded), retry: true},
+		{name: "connection refused", err: smtpError("dial", dial(os.NewSyscallError("connect", syscall.ECONNREFUSED))), retry: true},
+		{name: "connection reset", err: smtpError("DATA", dial(os.NewSyscallError("read", syscall.ECONNRESET))), retry: true},
+		{name: "network unreachable", err: smtpError("dial", dial(os.NewSyscallError("connect", syscall.ENETUNREACH))), retry: true},
+		{name: "host unreachable", err: smtpError("dial", dial(os.NewSyscallError("connect", syscall.EHOSTUNREACH))), retry: true},
+		{name: "dropped connection", err: smtpError("DATA", io.ErrUnexpectedEOF), retry: true},
+		{name: "dns failure", err: smtpError("dial", dial(&net.DNSError{Err: "server misbehaving", Name: "smtp.example.com", IsTemporary: true})), retry: true},
+		{name: "dns timeout", err: smtpError("dial", dial(&net.DNSError{Err: "i/o timeout", Name: "smtp.example.com", IsTimeout: true})), retry: true},
+		{name: "unknown host", err: smtpError("dial", dial(&net.DNSError{Err: "no such host", Name: "smtp.exmaple.com", IsNotFound: true})), retry: false, provider: true},
+		{name: "canceled", err: smtpError("dial", dial(context.Canceled)), retry: false},
+		{name: "other network error", err: smtpError("dial", dial(os.NewSyscallError("socket", syscall.EAFNOSUPPORT))), retry: false, provider: true},
+		{name: "bad certificate", err: smtpError("STARTTLS", x509.UnknownAuthorityError{}), retry: false, provider: true},
+		{name: "smtp 4xx", err: smtpError("DATA", &textproto.Error{Code: 451, Msg: "try later"}), retry: true},
+		{name: "smtp 5xx", err: smtpError("DATA", &textproto.Error{Code: 554, Msg: "rejected"}), retry: false},
+		{name: "smtp auth failed", err: smtpError("auth", &textproto.Error{Code: 535, Msg: "bad credentials"}), retry: false, provider: true},
+		{name: "smtp sender rejected", err: smtpError("MAIL FROM", &textproto.Error{Code: 550, Msg: "not allowed to send as"}), retry: false, provider: true},
+		{name: "smtp recipient rejected", err: smtpError("RCPT TO", &textproto.Error{Code: 550, Msg: "no such user"}), retry: false},
+		{name: "sendgrid network", err: &SendError{Temporary: temporaryNetError(io.EOF), Err: fmt.Errorf("error sending email: %w", io.EOF)}, retry: true},
+		{name: "http 429", err: httpError(http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}}, time.Now(), errors.New("429")), retry: true, after: 30 * time.Second},
+		{name: "http 503", err: httpError(http.StatusServiceUnavailable, nil, time.Now(), errors.New("503")), retry: true},
+		{name: "http 401", err: httpError(http.StatusUnauthorized, nil, time.Now(), errors.New("401")), retry: false, provider: true},
+		{name: "http 403", err: httpError(http.StatusForbidden, nil, time.Now(), errors.New("403")), retry: false, provider: true},
+		{name: "http 400", err: httpError(http.StatusBadRequest, nil, time.Now(), errors.New("400")), retry: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, after := Classify(tt.err)
+			if retry != tt.retry || after != tt.after {
+				t.Errorf("Classify(%v) = %v, %v; want %v, %v", tt.err, retry, after, tt.retry, tt.after)
+			}
+			if got := providerFault(tt.err); got != tt.provider {
+				t.Errorf("providerFault(%v) = %v, want %v", tt.err, got, tt.provider)
+			}
+		})
+	}
+}
+
+// mailerFunc is a Mailer made of a function.
+type mailerFunc func(ctx context.Context, msg *Message) (string, error)
+
+func (f mailerFunc) Send(ctx context.Context, msg *Message) (string, error) {
+	return f(ctx, msg)
+}
+
+func TestRetryStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sends := 0
+	r := &Retry{
+		Mailer: mailerFunc(func(ctx context.Context, msg *Message) (string, error) {
+			sends++
+			cancel()
+			return "", &SendError{Temporary: true, Err: errors.New("503")}
+		}),
+		Attempts: 3,
+		MaxWait:  time.Second,
+	}
+	if _, err := r.Send(ctx, &Message{}); err == nil || sends != 1 {
+		t.Errorf("Send = %v after %d sends, want one failed send", err, sends)
+	}
+}
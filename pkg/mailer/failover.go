@@ -0,0 +1,216 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a provider's circuit breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // sending normally
+	BreakerOpen     BreakerState = "open"      // skipped until the cooldown ends
+	BreakerHalfOpen BreakerState = "half_open" // one probe send in flight
+)
+
+// Provider is a named transport in a Failover.
+type Provider struct {
+	Name   string
+	Mailer Mailer
+}
+
+// Health is a snapshot of a provider's circuit breaker.
+type Health struct {
+	Name  string       `json:"name"`
+	State BreakerState `json:"state"`
+	// Failures counts consecutive failures that moved on from the provider.
+	Failures int `json:"failures"`
+	// OpenUntil is set while open; once it has passed, the next send
+	// probes the provider.
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Failover sends through the first healthy provider in order, moving on
+// to the next one after a transient failure or one of the provider's own,
+// such as rejected credentials (see SendError.Provider). Other permanent
+// failures, such as a rejected recipient, are returned at once since
+// another provider would fail the same way.
+//
+// Each provider has a circuit breaker: after Threshold consecutive
+// failures it moved on from, it is skipped for Cooldown, then a single
+// send probes whether it has recovered. Breakers live in memory, so every
+// instance tracks health on its own.
+type Failover struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	providers []*breaker
+}
+
+type breaker struct {
+	Provider
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+	lastError string
+}
+
+func NewFailover(threshold int, cooldown time.Duration, providers ...Provider) *Failover {
+	f := &Failover{Threshold: threshold, Cooldown: cooldown}
+	for _, p := range providers {
+		f.providers = append(f.providers, &breaker{Provider: p, state: BreakerClosed})
+	}
+	return f
+}
+
+func (f *Failover) Send(ctx context.Context, msg *Message) (string, error) {
+	var errs []error
+	// temporary is unset while every failure was the provider's fault
+	// and permanent.
+	temporary := false
+	// wait is the soonest any provider may be tried again; -1 until known.
+	wait := time.Duration(-1)
+	hint := func(d time.Duration) {
+		if wait < 0 || d < wait {
+			wait = d
+		}
+	}
+	for _, b := range f.providers {
+		if ok, until := b.allow(time.Now()); !ok {
+			hint(until)
+			continue
+		}
+
+		id, err := b.Mailer.Send(ctx, msg)
+		if err == nil {
+			b.succeeded()
+			return id, nil
+		}
+		if ctx.Err() != nil {
+			// Canceled by the caller, which says nothing about the provider.
+			b.release(time.Now())
+			return "", err
+		}
+		retry, after := Classify(err)
+		if !retry && !providerFault(err) {
+			// The message was refused, not the provider; it stays healthy.
+			b.succeeded()
+			return "", err
+		}
+		if b.failed(err, f.Threshold, f.Cooldown, time.Now()) {
+			log.Printf("Mail provider %s unavailable for %s: %v", b.Name, f.Cooldown, err)
+		}
+		if retry {
+			temporary = true
+			hint(after)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+	}
+
+	if len(errs) == 0 {
+		temporary = true
+		errs = append(errs, errors.New("all mail providers are unavailable"))
+	}
+	return "", &SendError{Temporary: temporary, RetryAfter: max(wait, 0), Err: providerErrors(errs)}
+}
+
+// providerErrors lists the failure of every provider tried, on one line.
+type providerErrors []error
+
+func (e providerErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e providerErrors) Unwrap() []error { return e }
+
+// Lookup returns the transport of the named provider, or nil.
+func (f *Failover) Lookup(name string) Mailer {
+	for _, b := range f.providers {
+		if b.Name == name {
+			return b.Mailer
+		}
+	}
+	return nil
+}
+
+// Health reports every provider's breaker, in failover order.
+func (f *Failover) Health() []Health {
+	health := make([]Health, 0, len(f.providers))
+	for _, b := range f.providers {
+		b.mu.Lock()
+		h := Health{Name: b.Name, State: b.state, Failures: b.failures, LastError: b.lastError}
+		if b.state == BreakerOpen {
+			until := b.openUntil.UTC()
+			h.OpenUntil = &until
+		}
+		b.mu.Unlock()
+		health = append(health, h)
+	}
+	return health
+}
+
+// allow reports whether a send may go to the provider now, and otherwise
+// how long until it may. An open breaker whose cooldown has ended lets a
+// single probe through.
+func (b *breaker) allow(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openUntil) {
+			return false, b.openUntil.Sub(now)
+		}
+		b.state = BreakerHalfOpen
+		return true, 0
+	case BreakerHalfOpen:
+		return false, time.Second
+	default:
+		return true, 0
+	}
+}
+
+func (b *breaker) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+}
+
+// release ends a probe that gave no verdict, so the next send probes
+// again rather than finding the breaker stuck half-open.
+func (b *breaker) release(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+		b.openUntil = now
+	}
+}
+
+// failed records a transient failure, or one of the provider's own, and
+// reports whether it opened the breaker.
+func (b *breaker) failed(err error, threshold int, cooldown time.Duration, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastError = err.Error()
+	if b.state != BreakerHalfOpen && b.failures < threshold {
+		return false
+	}
+	b.state = BreakerOpen
+	b.openUntil = now.Add(cooldown)
+	return true
+}
@@ -0,0 +1,226 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+var errUnavailable = &SendError{Temporary: true, Err: errors.New("503 service unavailable")}
+
+func TestFailover(t *testing.T) {
+	var sent []string
+	provider := func(name string, err *error) Provider {
+		return Provider{Name: name, Mailer: mailerFunc(func(ctx context.Context, msg *Message) (string, error) {
+			sent = append(sent, name)
+			return name + "-id", *err
+		})}
+	}
+	var primaryErr, backupErr error
+	f := NewFailover(2, time.Hour, provider("primary", &primaryErr), provider("backup", &backupErr))
+	ctx := context.Background()
+
+	if id, err := f.Send(ctx, &Message{}); id != "primary-id" || err != nil {
+		t.Fatalf("Send = %q, %v", id, err)
+	}
+
+	primaryErr = errUnavailable
+	for range 2 {
+		if id, err := f.Send(ctx, &Message{}); id != "backup-id" || err != nil {
+			t.Fatalf("Send with the primary down = %q, %v", id, err)
+		}
+	}
+	if h := f.Health(); h[0].State != BreakerOpen || h[0].Failures != 2 || h[0].OpenUntil == nil {
+		t.Errorf("primary health = %+v, want open", h[0])
+	}
+	sent = nil
+	f.Send(ctx, &Message{})
+	if len(sent) != 1 || sent[0] != "backup" {
+		t.Errorf("sent through %v with the primary open, want backup only", sent)
+	}
+
+	backupErr = errUnavailable
+	_, err := f.Send(ctx, &Message{})
+	if retry, _ := Classify(err); !retry {
+		t.Errorf("Send with every provider down = %v, want a transient error", err)
+	}
+
+	// A permanent failure is returned at once and doesn't count against
+	// the provider.
+	backupErr = &SendError{Err: errors.New("550 no such user"), Recipient: true}
+	sent = nil
+	if _, err := f.Send(ctx, &Message{}); !errors.Is(err, ErrInvalidRecipient) || len(sent) != 1 {
+		t.Errorf("Send = %v through %v, want the rejection from backup alone", err, sent)
+	}
+	if h := f.Health(); h[1].State != BreakerClosed || h[1].Failures != 0 {
+		t.Errorf("backup health = %+v, want closed", h[1])
+	}
+}
+
+func TestFailoverProviderFault(t *testing.T) {
+	var sent []string
+	provider := func(name string, err *error) Provider {
+		return Provider{Name: name, Mailer: mailerFunc(func(ctx context.Context, msg *Message) (string, error) {
+			sent = append(sent, name)
+			return name + "-id", *err
+		})}
+	}
+	var sendgridErr, smtpErr error
+	f := NewFailover(2, time.Hour, provider("sendgrid", &sendgridErr), provider("smtp", &smtpErr))
+	ctx := context.Background()
+
+	// A revoked API key counts against SendGrid and fails over to SMTP.
+	sendgridErr = httpError(http.StatusUnauthorized, nil, time.Now(), errors.New("401 unauthorized"))
+	for range 2 {
+		if id, err := f.Send(ctx, &Message{}); id != "smtp-id" || err != nil {
+			t.Fatalf("Send with a rejected API key = %q, %v", id, err)
+		}
+	}
+	if h := f.Health(); h[0].State != BreakerOpen || h[0].Failures != 2 {
+		t.Errorf("sendgrid health = %+v, want open", h[0])
+	}
+	sent = nil
+	f.Send(ctx, &Message{})
+	if len(sent) != 1 || sent[0] != "smtp" {
+		t.Errorf("sent through %v with sendgrid open, want smtp only", sent)
+	}
+
+	// A payload SendGrid rejects is returned without trying SMTP.
+	f = NewFailover(2, time.Hour, provider("sendgrid", &sendgridErr), provider("smtp", &smtpErr))
+	sendgridErr = httpError(http.StatusBadRequest, nil, time.Now(), errors.New("400 bad request"))
+	sent = nil
+	if _, err := f.Send(ctx, &Message{}); err != sendgridErr || len(sent) != 1 {
+		t.Errorf("Send = %v through %v, want the rejection from sendgrid alone", err, sent)
+	}
+
+	// When every provider refuses to work, retrying won't help.
+	sendgridErr = httpError(http.StatusForbidden, nil, time.Now(), errors.New("403 forbidden"))
+	smtpErr = smtpError("auth", &textproto.Error{Code: 535, Msg: "authentication failed"})
+	_, err := f.Send(ctx, &Message{})
+	if retry, _ := Classify(err); retry || err == nil {
+		t.Errorf("Send with every provider misconfigured = %v, want a permanent error", err)
+	}
+	if h := f.Health(); h[0].Failures != 1 || h[1].Failures != 1 {
+		t.Errorf("health = %+v, want a failure counted against each provider", h)
+	}
+}
+
+func TestFailoverProbe(t *testing.T) {
+	// The provider fails until it is told otherwise, and a send blocks
+	// until its result arrives or the context ends.
+	started, results := make(chan struct{}, 1), make(chan error, 1)
+	f := NewFailover(1, time.Millisecond, Provider{Name: "only", Mailer: mailerFunc(func(ctx context.Context, msg *Message) (string, error) {
+		started <- struct{}{}
+		select {
+		case err := <-results:
+			return "id", err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})})
+	ctx := context.Background()
+
+	results <- errUnavailable
+	f.Send(ctx, &Message{})
+	<-started
+	time.Sleep(2 * time.Millisecond)
+
+	// A probe canceled by its caller leaves the breaker ready to probe
+	// again instead of stuck half-open.
+	canceled, cancel := context.WithCancel(ctx)
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := f.Send(canceled, &Message{}); err == nil {
+		t.Fatal("canceled probe succeeded")
+	}
+	if h := f.Health(); h[0].State != BreakerOpen {
+		t.Fatalf("health after a canceled probe = %+v, want open", h[0])
+	}
+
+	results <- nil
+	if _, err := f.Send(ctx, &Message{}); err != nil {
+		t.Fatalf("second probe: %v", err)
+	}
+	<-started
+	if h := f.Health(); h[0].State != BreakerClosed {
+		t.Errorf("health after a good probe = %+v, want closed", h[0])
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	const threshold, cooldown = 2, time.Minute
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		do        string // "fail", "succeed", "release" or "allow"
+		at        time.Duration
+		wantAllow bool
+		wantState BreakerState
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{name: "below threshold", steps: []step{
+			{do: "fail", wantState: BreakerClosed},
+			{do: "allow", wantAllow: true, wantState: BreakerClosed},
+		}},
+		{name: "success resets failures", steps: []step{
+			{do: "fail", wantState: BreakerClosed},
+			{do: "succeed", wantState: BreakerClosed},
+			{do: "fail", wantState: BreakerClosed},
+		}},
+		{name: "opens at threshold", steps: []step{
+			{do: "fail", wantState: BreakerClosed},
+			{do: "fail", wantState: BreakerOpen},
+			{do: "allow", at: cooldown - time.Second, wantState: BreakerOpen},
+		}},
+		{name: "probe after cooldown", steps: []step{
+			{do: "fail"}, {do: "fail"},
+			{do: "allow", at: cooldown, wantAllow: true, wantState: BreakerHalfOpen},
+			{do: "allow", at: cooldown, wantState: BreakerHalfOpen},
+			{do: "succeed", wantState: BreakerClosed},
+			{do: "allow", at: cooldown, wantAllow: true, wantState: BreakerClosed},
+		}},
+		{name: "failed probe reopens", steps: []step{
+			{do: "fail"}, {do: "fail"},
+			{do: "allow", at: cooldown, wantAllow: true, wantState: BreakerHalfOpen},
+			{do: "fail", at: cooldown, wantState: BreakerOpen},
+			{do: "allow", at: 2*cooldown - time.Second, wantState: BreakerOpen},
+			{do: "allow", at: 2 * cooldown, wantAllow: true, wantState: BreakerHalfOpen},
+		}},
+		{name: "released probe", steps: []step{
+			{do: "fail"}, {do: "fail"},
+			{do: "allow", at: cooldown, wantAllow: true, wantState: BreakerHalfOpen},
+			{do: "release", at: cooldown, wantState: BreakerOpen},
+			{do: "allow", at: cooldown, wantAllow: true, wantState: BreakerHalfOpen},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &breaker{state: BreakerClosed}
+			for i, s := range tt.steps {
+				at := now.Add(s.at)
+				switch s.do {
+				case "fail":
+					b.failed(errUnavailable, threshold, cooldown, at)
+				case "succeed":
+					b.succeeded()
+				case "release":
+					b.release(at)
+				case "allow":
+					if ok, _ := b.allow(at); ok != s.wantAllow {
+						t.Errorf("step %d: allow = %v, want %v", i, ok, s.wantAllow)
+					}
+				}
+				if s.wantState != "" && b.state != s.wantState {
+					t.Errorf("step %d (%s): state %s, want %s", i, s.do, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
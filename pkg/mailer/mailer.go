@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+)
+
+// Address is a mailbox with an optional display name.
+type Address struct {
+	Name  string
+	Email string
+}
+
+// Message is a fully built email ready to be handed to a transport.
+type Message struct {
+	From    Address
+	To      Address
+	ReplyTo *Address
+	Subject string
+	HTML    string
+	Text    string
+	// Headers are extra RFC 5322 headers such as List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Mailer sends a message and returns the provider's message ID.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) (string, error)
+}
+
+var ErrNotConfigured = errors.New("no mail transport configured")
@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+)
+
+// newMessageID returns a unique RFC 5322 Message-ID rooted at the sender's domain.
+func newMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return fmt.Sprintf("<%s.%d@%s>", hex.EncodeToString(buf), time.Now().UnixNano(), domain)
+}
+
+func formatAddress(a Address) string {
+	return (&mail.Address{Name: a.Name, Address: a.Email}).String()
+}
+
+// buildMIME encodes msg as an RFC 5322 message with a multipart/alternative
+// body. Both parts are quoted-printable so long lines and non-ASCII copy
+// survive 7-bit relays.
+func buildMIME(msg *Message, messageID string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	var out bytes.Buffer
+	writeHeader := func(key, value string) {
+		fmt.Fprintf(&out, "%s: %s\r\n", key, value)
+	}
+	writeHeader("From", formatAddress(msg.From))
+	writeHeader("To", formatAddress(msg.To))
+	if msg.ReplyTo != nil {
+		writeHeader("Reply-To", formatAddress(*msg.ReplyTo))
+	}
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader("Date", date.Format(time.RFC1123Z))
+	writeHeader("Message-ID", messageID)
+	for _, key := range slices.Sorted(maps.Keys(msg.Headers)) {
+		writeHeader(textproto.CanonicalMIMEHeaderKey(key), msg.Headers[key])
+	}
+	writeHeader("MIME-Version", "1.0")
+	writeHeader("Content-Type", "multipart/alternative; boundary="+body.Boundary())
+	out.WriteString("\r\n")
+
+	if msg.Text != "" {
+		if err := writeQPPart(body, "text/plain; charset=utf-8", msg.Text); err != nil {
+			return nil, err
+		}
+	}
+	if msg.HTML != "" {
+		if err := writeQPPart(body, "text/html; charset=utf-8", msg.HTML); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+func writeQPPart(w *multipart.Writer, contentType, content string) error {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
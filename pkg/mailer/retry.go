@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const retryBaseDelay = 500 * time.Millisecond
+
+// Retry wraps a Mailer and sends again after transient failures, waiting
+// with exponential backoff or as long as the provider asked. It gives up
+// early when the wait would exceed MaxWait, leaving longer retries to
+// the caller (the outbox).
+type Retry struct {
+	Mailer Mailer
+	// Attempts bounds the sends per message, including the first.
+	Attempts int
+	// MaxWait is the longest single wait between attempts.
+	MaxWait time.Duration
+}
+
+func (r *Retry) Send(ctx context.Context, msg *Message) (string, error) {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		id, err := r.Mailer.Send(ctx, msg)
+		retry, after := Classify(err)
+		if !retry || attempt >= r.Attempts || ctx.Err() != nil {
+			return id, err
+		}
+
+		wait := max(delay, after)
+		if wait > r.MaxWait {
+			return "", err
+		}
+		log.Printf("Send attempt %d failed, retrying in %s: %v", attempt, wait, err)
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return "", err
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
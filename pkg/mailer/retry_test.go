@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	transient := &SendError{Temporary: true, Err: errors.New("503")}
+	permanent := &SendError{Err: errors.New("550 no such user")}
+	throttled := httpError(http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}}, time.Now(), errors.New("429"))
+
+	tests := []struct {
+		name      string
+		errs      []error // returned by successive sends; nil once exhausted
+		attempts  int
+		maxWait   time.Duration
+		wantSends int
+		wantErr   error
+	}{
+		{name: "first send", attempts: 3, maxWait: time.Second, wantSends: 1},
+		{name: "transient then sent", errs: []error{transient}, attempts: 3, maxWait: time.Second, wantSends: 2},
+		{name: "permanent", errs: []error{permanent}, attempts: 3, maxWait: time.Second, wantSends: 1, wantErr: permanent},
+		{name: "out of attempts", errs: []error{transient, transient, transient}, attempts: 2, maxWait: time.Second, wantSends: 2, wantErr: transient},
+		{name: "single attempt", errs: []error{transient}, attempts: 1, maxWait: time.Second, wantSends: 1, wantErr: transient},
+		{name: "backoff over max wait", errs: []error{transient}, attempts: 3, maxWait: 100 * time.Millisecond, wantSends: 1, wantErr: transient},
+		{name: "retry-after over max wait", errs: []error{throttled}, attempts: 3, maxWait: time.Second, wantSends: 1, wantErr: throttled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sends := 0
+			r := &Retry{
+				Mailer: mailerFunc(func(ctx context.Context, msg *Message) (string, error) {
+					sends++
+					if sends <= len(tt.errs) {
+						return "", tt.errs[sends-1]
+					}
+					return "id", nil
+				}),
+				Attempts: tt.attempts,
+				MaxWait:  tt.maxWait,
+			}
+			id, err := r.Send(context.Background(), &Message{})
+			if sends != tt.wantSends || err != tt.wantErr {
+				t.Errorf("Send = %q, %v after %d sends; want %v after %d", id, err, sends, tt.wantErr, tt.wantSends)
+			}
+			if err == nil && id != "id" {
+				t.Errorf("Send = %q, want the provider's id", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+type SendGrid struct {
+	client *sendgrid.Client
+}
+
+func NewSendGrid(apiKey string) *SendGrid {
+	return &SendGrid{client: sendgrid.NewSendClient(apiKey)}
+}
+
+func (s *SendGrid) Send(ctx context.Context, msg *Message) (string, error) {
+	from := mail.NewEmail(msg.From.Name, msg.From.Email)
+	to := mail.NewEmail(msg.To.Name, msg.To.Email)
+	message := mail.NewSingleEmail(from, msg.Subject, to, msg.Text, msg.HTML)
+	if msg.ReplyTo != nil {
+		message.SetReplyTo(mail.NewEmail(msg.ReplyTo.Name, msg.ReplyTo.Email))
+	}
+	for key, value := range msg.Headers {
+		message.SetHeader(key, value)
+	}
+
+	response, err := s.client.SendWithContext(ctx, message)
+	if err != nil {
+		se := netError(err)
+		se.Err = fmt.Errorf("error sending email: %w", err)
+		return "", se
+	}
+
+	if response.StatusCode >= 400 {
+		detail, recipient := sendGridErrors(response.Body)
+		msg := fmt.Sprintf("sendgrid error: status code %d", response.StatusCode)
+		if detail != "" {
+			msg += ": " + detail
+		}
+		se := httpError(response.StatusCode, http.Header(response.Headers), time.Now(), errors.New(msg))
+		se.Recipient = recipient && response.StatusCode == http.StatusBadRequest
+		return "", se
+	}
+
+	return firstHeader(response.Headers, "X-Message-Id"), nil
+}
+
+func firstHeader(headers map[string][]string, key string) string {
+	for k, v := range headers {
+		if len(v) > 0 && http.CanonicalHeaderKey(k) == key {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// sendGridErrors summarizes the errors array of a v3 API error response
+// and reports whether one of them concerns a recipient address.
+func sendGridErrors(body string) (string, bool) {
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Field   string `json:"field"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal([]byte(body), &resp) != nil {
+		return "", false
+	}
+	var msgs []string
+	recipient := false
+	for _, e := range resp.Errors {
+		msgs = append(msgs, e.Message)
+		if strings.HasPrefix(e.Field, "personalizations.") && strings.Contains(e.Field, ".to.") {
+			recipient = true
+		}
+	}
+	return strings.Join(msgs, "; "), recipient
+}
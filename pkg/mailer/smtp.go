@@ -0,0 +1,217 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLSMode controls how the SMTP connection is secured.
+type TLSMode string
+
+const (
+	TLSStartTLS TLSMode = "starttls" // plain connect, upgrade with STARTTLS (port 587)
+	TLSImplicit TLSMode = "implicit" // TLS from the first byte (port 465)
+	TLSNone     TLSMode = "none"     // no encryption; only for local relays and tests
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLSMode  TLSMode
+	Timeout  time.Duration
+
+	// TLSConfig overrides the default TLS settings, e.g. to trust a test CA.
+	TLSConfig *tls.Config
+	// LocalName is the hostname sent with EHLO. Defaults to "localhost".
+	LocalName string
+}
+
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.TLSMode == "" {
+		if cfg.Port == 465 {
+			cfg.TLSMode = TLSImplicit
+		} else {
+			cfg.TLSMode = TLSStartTLS
+		}
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.LocalName == "" {
+		cfg.LocalName = "localhost"
+	}
+	return &SMTP{cfg: cfg}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg *Message) (string, error) {
+	messageID := newMessageID(msg.From.Email)
+	data, err := buildMIME(msg, messageID, time.Now())
+	if err != nil {
+		return "", &SendError{Err: fmt.Errorf("error encoding email: %v", err)}
+	}
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return "", smtpError("dial "+s.addr(), err)
+	}
+	defer conn.Close()
+
+	// A single deadline bounds the whole SMTP conversation.
+	deadline := time.Now().Add(s.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		return "", smtpError("handshake", err)
+	}
+	defer c.Close()
+
+	if err := c.Hello(s.cfg.LocalName); err != nil {
+		return "", smtpError("ehlo", err)
+	}
+
+	if s.cfg.TLSMode == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return "", &SendError{Provider: true, Err: errors.New("smtp server does not support STARTTLS")}
+		}
+		if err := c.StartTLS(s.tlsConfig()); err != nil {
+			return "", smtpError("starttls", err)
+		}
+	}
+
+	if s.cfg.Username != "" {
+		auth, err := s.auth(c)
+		if err != nil {
+			return "", &SendError{Provider: true, Err: err}
+		}
+		if err := c.Auth(auth); err != nil {
+			return "", smtpError("auth", err)
+		}
+	}
+
+	if err := c.Mail(msg.From.Email); err != nil {
+		return "", smtpError("MAIL FROM", err)
+	}
+	if err := c.Rcpt(msg.To.Email); err != nil {
+		return "", smtpError("RCPT TO", err)
+	}
+
+	wc, err := c.Data()
+	if err != nil {
+		return "", smtpError("DATA", err)
+	}
+	if _, err := wc.Write(data); err != nil {
+		return "", smtpError("write", err)
+	}
+	if err := wc.Close(); err != nil {
+		return "", smtpError("DATA", err)
+	}
+
+	// The message is accepted once DATA is; failing to say goodbye must
+	// not cause it to be sent again.
+	c.Quit()
+
+	return messageID, nil
+}
+
+func (s *SMTP) addr() string {
+	return net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+}
+
+func (s *SMTP) tlsConfig() *tls.Config {
+	if s.cfg.TLSConfig != nil {
+		return s.cfg.TLSConfig
+	}
+	return &tls.Config{ServerName: s.cfg.Host}
+}
+
+func (s *SMTP) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	if s.cfg.TLSMode == TLSImplicit {
+		td := &tls.Dialer{NetDialer: dialer, Config: s.tlsConfig()}
+		return td.DialContext(ctx, "tcp", s.addr())
+	}
+	return dialer.DialContext(ctx, "tcp", s.addr())
+}
+
+// auth picks PLAIN when the server offers it and falls back to LOGIN,
+// which Outlook/Office 365 still require.
+func (s *SMTP) auth(c *smtp.Client) (smtp.Auth, error) {
+	_, mechs := c.Extension("AUTH")
+	offered := strings.Fields(strings.ToUpper(mechs))
+	has := func(m string) bool {
+		for _, o := range offered {
+			if o == m {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("PLAIN"):
+		return &plainAuth{username: s.cfg.Username, password: s.cfg.Password}, nil
+	case has("LOGIN"):
+		return &loginAuth{username: s.cfg.Username, password: s.cfg.Password}, nil
+	default:
+		return nil, fmt.Errorf("smtp server offers no supported auth mechanism (got %q)", mechs)
+	}
+}
+
+// plainAuth is smtp.PlainAuth without the host check; the TLS decision is
+// made explicitly through TLSMode instead.
+type plainAuth struct {
+	username, password string
+}
+
+func (a *plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("unexpected server challenge")
+	}
+	return nil, nil
+}
+
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
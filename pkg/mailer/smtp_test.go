@@ -0,0 +1,349 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is a minimal in-process SMTP server. It speaks just enough
+// ESMTP for the client: EHLO, STARTTLS, AUTH PLAIN/LOGIN, MAIL, RCPT and
+// DATA.
+type smtpStandIn struct {
+	t        *testing.T
+	tls      *tls.Config
+	implicit bool   // TLS from the first byte
+	startTLS bool   // advertise STARTTLS
+	auth     string // mechanisms to advertise, e.g. "PLAIN LOGIN"
+	replies  map[string]string
+
+	mu       sync.Mutex
+	user     string
+	pass     string
+	mech     string
+	from     string
+	rcpt     string
+	data     string
+	upgraded bool
+}
+
+func (s *smtpStandIn) start() int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if s.implicit {
+				c = tls.Server(c, s.tls)
+			}
+			go s.serve(c)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	reply := func(line string) { c.Write([]byte(line + "\r\n")) }
+	readLine := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err == nil
+	}
+
+	reply("220 stand-in ESMTP")
+	for {
+		line, ok := readLine()
+		if !ok {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		if canned, ok := s.replies[verb]; ok {
+			reply(canned)
+			continue
+		}
+
+		switch verb {
+		case "EHLO":
+			var ext []string
+			if s.startTLS && !s.upgraded {
+				ext = append(ext, "STARTTLS")
+			}
+			if s.auth != "" {
+				ext = append(ext, "AUTH "+s.auth)
+			}
+			reply("250-stand-in")
+			for _, e := range ext {
+				reply("250-" + e)
+			}
+			reply("250 8BITMIME")
+		case "STARTTLS":
+			reply("220 go ahead")
+			tc := tls.Server(c, s.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			c, r = tc, bufio.NewReader(tc)
+			s.mu.Lock()
+			s.upgraded = true
+			s.mu.Unlock()
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			s.mu.Lock()
+			s.mech = mech
+			s.mu.Unlock()
+			switch mech {
+			case "PLAIN":
+				raw, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(raw), "\x00")
+				if len(parts) == 3 {
+					s.setCreds(parts[1], parts[2])
+				}
+			case "LOGIN":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				u, _ := readLine()
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				p, _ := readLine()
+				user, _ := base64.StdEncoding.DecodeString(u)
+				pass, _ := base64.StdEncoding.DecodeString(p)
+				s.setCreds(string(user), string(pass))
+			}
+			reply("235 authenticated")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = arg
+			s.mu.Unlock()
+			reply("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpt = arg
+			s.mu.Unlock()
+			reply("250 ok")
+		case "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				l, ok := readLine()
+				if !ok {
+					return
+				}
+				if l == "." {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, ".") + "\r\n")
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpStandIn) setCreds(user, pass string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user, s.pass = user, pass
+}
+
+// testTLS returns a server config with a fresh self-signed certificate
+// for 127.0.0.1 and a client config that trusts it.
+func testTLS(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "stand-in"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
+
+func testMessage() *Message {
+	return &Message{
+		From:    Address{Name: "GoalHero Team", Email: "info@goalhero.eu"},
+		To:      Address{Email: "jane@example.com"},
+		ReplyTo: &Address{Email: "support@goalhero.eu"},
+		Subject: "¡Bienvenido a GoalHero! ⚽",
+		HTML:    `<p style="color: #00C851">Hola, ` + strings.Repeat("línea larga ", 20) + `</p>`,
+		Text:    "Hola, " + strings.Repeat("línea larga ", 20),
+		Headers: map[string]string{"List-Unsubscribe": "<https://example.com/u>"},
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	serverTLS, clientTLS := testTLS(t)
+
+	tests := []struct {
+		name     string
+		mode     TLSMode
+		implicit bool
+		startTLS bool
+		auth     string
+		user     string
+		wantMech string
+	}{
+		{name: "starttls plain", mode: TLSStartTLS, startTLS: true, auth: "PLAIN LOGIN", user: "jane", wantMech: "PLAIN"},
+		{name: "implicit login", mode: TLSImplicit, implicit: true, auth: "LOGIN", user: "jane", wantMech: "LOGIN"},
+		{name: "plaintext no auth", mode: TLSNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &smtpStandIn{t: t, tls: serverTLS, implicit: tt.implicit, startTLS: tt.startTLS, auth: tt.auth}
+			port := srv.start()
+
+			s := NewSMTP(SMTPConfig{
+				Host: "127.0.0.1", Port: port, TLSMode: tt.mode, TLSConfig: clientTLS,
+				Username: tt.user, Password: "s3cret", Timeout: 5 * time.Second,
+			})
+			id, err := s.Send(context.Background(), testMessage())
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			if tt.startTLS && !srv.upgraded {
+				t.Error("connection was not upgraded with STARTTLS")
+			}
+			if srv.mech != tt.wantMech {
+				t.Errorf("auth mechanism = %q, want %q", srv.mech, tt.wantMech)
+			}
+			if tt.user != "" && (srv.user != tt.user || srv.pass != "s3cret") {
+				t.Errorf("credentials = %q/%q, want %q/s3cret", srv.user, srv.pass, tt.user)
+			}
+			if !strings.HasPrefix(srv.from, "FROM:<info@goalhero.eu>") || !strings.HasPrefix(srv.rcpt, "TO:<jane@example.com>") {
+				t.Errorf("envelope = %q -> %q", srv.from, srv.rcpt)
+			}
+
+			got, err := parseCaptured("test", []byte(srv.data))
+			if err != nil {
+				t.Fatalf("parsing sent message: %v", err)
+			}
+			want := testMessage()
+			if got.MessageID != id {
+				t.Errorf("Message-ID = %q, Send returned %q", got.MessageID, id)
+			}
+			if got.Subject != want.Subject {
+				t.Errorf("Subject = %q, want %q", got.Subject, want.Subject)
+			}
+			if got.HTML != want.HTML || got.Text != want.Text {
+				t.Errorf("parts did not round-trip:\nhtml %q\ntext %q", got.HTML, got.Text)
+			}
+			for _, h := range []string{"Reply-To: <support@goalhero.eu>", "List-Unsubscribe: <https://example.com/u>", "Content-Type: multipart/alternative"} {
+				if !strings.Contains(got.Header, h) {
+					t.Errorf("header missing %q in\n%s", h, got.Header)
+				}
+			}
+			if n := strings.Count(srv.data, "Content-Transfer-Encoding: quoted-printable"); n != 2 {
+				t.Errorf("%d quoted-printable parts, want 2", n)
+			}
+			// The parts are quoted-printable, so no body line may exceed 76
+			// characters even though the text has long runs of UTF-8.
+			_, body, _ := strings.Cut(srv.data, "\r\n\r\n")
+			for _, line := range strings.Split(body, "\r\n") {
+				if len(line) > 76 {
+					t.Errorf("body line longer than 76 characters: %q", line)
+				}
+			}
+		})
+	}
+}
+
+func TestSMTPSendErrors(t *testing.T) {
+	serverTLS, clientTLS := testTLS(t)
+
+	tests := []struct {
+		name          string
+		startTLS      bool
+		replies       map[string]string
+		wantRetry     bool
+		wantRecipient bool
+	}{
+		{name: "no starttls offered", startTLS: false},
+		{name: "mailbox unavailable", startTLS: true, replies: map[string]string{"RCPT": "550 5.1.1 no such user"}, wantRecipient: true},
+		{name: "greylisted", startTLS: true, replies: map[string]string{"MAIL": "451 4.7.1 try again later"}, wantRetry: true},
+		{name: "policy rejection", startTLS: true, replies: map[string]string{"MAIL": "554 5.7.1 rejected"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &smtpStandIn{t: t, tls: serverTLS, startTLS: tt.startTLS, replies: tt.replies}
+			port := srv.start()
+			s := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: port, TLSConfig: clientTLS, Timeout: 5 * time.Second})
+
+			_, err := s.Send(context.Background(), testMessage())
+			if err == nil {
+				t.Fatal("Send succeeded")
+			}
+			if retry, _ := Classify(err); retry != tt.wantRetry {
+				t.Errorf("retryable = %v, want %v (%v)", retry, tt.wantRetry, err)
+			}
+			if got := errors.Is(err, ErrInvalidRecipient); got != tt.wantRecipient {
+				t.Errorf("invalid recipient = %v, want %v (%v)", got, tt.wantRecipient, err)
+			}
+		})
+	}
+}
+
+func TestSMTPDialRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	s := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: port, TLSMode: TLSNone, Timeout: time.Second})
+	_, err = s.Send(context.Background(), testMessage())
+	if retry, _ := Classify(err); err == nil || !retry {
+		t.Errorf("refused connection: err = %v, retryable = %v", err, retry)
+	}
+	if !strings.Contains(err.Error(), strconv.Itoa(port)) {
+		t.Errorf("error does not name the address: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+// Package outbox delivers the emails queued in the registration store.
+// Handlers enqueue a job in the same write as the registration and
+// dispatch it; a Worker claims due jobs, sends them and retries transient
+// failures with exponential backoff until they succeed or are
+// dead-lettered. Permanent failures are dead-lettered at once.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goalhero-emailer/pkg/registration"
+)
+
+// Policy controls retries.
+type Policy struct {
+	// MaxAttempts is how many sends a job gets before it is
+	// dead-lettered.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with
+	// every further attempt, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Backoff returns the wait after the given failed attempt (1-based). The
+// result is jittered uniformly over the upper half of the exponential
+// delay, so jobs that failed together don't retry together.
+func (p Policy) Backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// Deliver sends the email for job and returns the status and message ID
+// to record on the registration; an empty status leaves it unchanged.
+type Deliver func(ctx context.Context, job registration.Job) (registration.EmailStatus, string, error)
+
+const (
+	defaultLease       = 2 * time.Minute
+	defaultSyncTimeout = 5 * time.Second
+	defaultBatchSize   = 20
+	pollInterval       = 15 * time.Second
+)
+
+type Worker struct {
+	Store   registration.Store
+	Deliver Deliver
+	Policy  Policy
+	// Lease is how long a claimed job is reserved for one send.
+	Lease time.Duration
+	// BatchSize bounds the jobs claimed at once.
+	BatchSize int
+	// Classify tells transient Deliver errors, retried no sooner than
+	// after, from permanent ones. Every error is transient when nil.
+	Classify func(err error) (retry bool, after time.Duration)
+	// Sync makes Dispatch send in the caller and Kick do nothing, for
+	// platforms that freeze the process once the response is sent.
+	Sync bool
+	// SyncTimeout caps each send Dispatch makes with Sync, retries by the
+	// Deliver function included, so a slow provider delays the caller by
+	// at most that much. A send cut off counts as a failed attempt.
+	SyncTimeout time.Duration
+
+	running  atomic.Bool
+	kickOnce sync.Once
+	kick     chan struct{}
+
+	mu        sync.Mutex
+	nextRetry time.Time
+	// ctx is the context given to Start, which Kick runs under.
+	ctx context.Context
+}
+
+// Drain sends every due job and returns how many it processed. Jobs that
+// fail are rescheduled or dead-lettered; only store errors are returned.
+func (w *Worker) Drain(ctx context.Context) (int, error) {
+	lease, batch := w.lease(), w.BatchSize
+	if batch <= 0 {
+		batch = defaultBatchSize
+	}
+
+	processed := 0
+	for ctx.Err() == nil {
+		jobs, err := w.Store.ClaimJobs(ctx, time.Now().UTC(), lease, batch)
+		if err != nil {
+			return processed, err
+		}
+		if len(jobs) == 0 {
+			break
+		}
+		for _, job := range jobs {
+			if err := w.process(ctx, job, lease); err != nil {
+				return processed, err
+			}
+			processed++
+		}
+	}
+	return processed, ctx.Err()
+}
+
+// Dispatch gets the jobs just queued for the registration with key going.
+// With Sync it sends them before returning, one attempt each of at most
+// SyncTimeout, and leaves failures to be retried by a later Drain; the
+// send goes on if ctx is canceled, so a client hanging up doesn't strand
+// a leased job. Otherwise it Kicks the background worker.
+func (w *Worker) Dispatch(ctx context.Context, key string) {
+	if !w.Sync {
+		w.Kick()
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	lease := w.lease()
+	jobs, err := w.Store.ClaimJobsFor(ctx, key, time.Now().UTC(), lease)
+	if err != nil {
+		log.Printf("Outbox: %v", err)
+		return
+	}
+	timeout := w.SyncTimeout
+	if timeout <= 0 {
+		timeout = defaultSyncTimeout
+	}
+	for _, job := range jobs {
+		if err := w.process(ctx, job, min(timeout, lease)); err != nil {
+			log.Printf("Outbox: %v", err)
+		}
+	}
+}
+
+func (w *Worker) lease() time.Duration {
+	if w.Lease <= 0 {
+		return defaultLease
+	}
+	return w.Lease
+}
+
+// process makes one attempt at job, giving the send up to timeout, and
+// records the outcome.
+func (w *Worker) process(ctx context.Context, job registration.Job, timeout time.Duration) error {
+	sendCtx, cancel := context.WithTimeout(ctx, timeout)
+	status, messageID, err := w.Deliver(sendCtx, job)
+	cancel()
+	if err == nil {
+		return w.Store.CompleteJob(ctx, job.ID, status, messageID)
+	}
+
+	if ctx.Err() != nil {
+		// The worker was stopped, not the email refused: put the job back
+		// for the next Drain.
+		log.Printf("Outbox: %s email job %s interrupted: %v", job.Kind, job.ID, err)
+		return w.Store.RetryJob(context.WithoutCancel(ctx), job.ID, time.Now().UTC(), err.Error())
+	}
+	retry, after := w.classify(err)
+	if !retry {
+		log.Printf("Outbox: %s email job %s failed permanently: %v", job.Kind, job.ID, err)
+		return w.Store.BuryJob(ctx, job.ID, err.Error())
+	}
+	if job.Attempts >= w.Policy.MaxAttempts {
+		log.Printf("Outbox: %s email job %s dead after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		return w.Store.BuryJob(ctx, job.ID, err.Error())
+	}
+	wait := max(w.Policy.Backoff(job.Attempts), after)
+	at := time.Now().UTC().Add(wait)
+	log.Printf("Outbox: %s email job %s attempt %d failed, retrying in %s: %v", job.Kind, job.ID, job.Attempts, wait.Round(time.Millisecond), err)
+
+	w.mu.Lock()
+	if w.nextRetry.IsZero() || at.Before(w.nextRetry) || w.nextRetry.Before(time.Now()) {
+		w.nextRetry = at
+	}
+	w.mu.Unlock()
+	return w.Store.RetryJob(ctx, job.ID, at, err.Error())
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a Deliver error as one that retrying can't fix, so the
+// job is dead-lettered at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// classify applies Classify to errors not marked Permanent.
+func (w *Worker) classify(err error) (bool, time.Duration) {
+	var p permanentError
+	if errors.As(err, &p) {
+		return false, 0
+	}
+	if w.Classify == nil {
+		return true, 0
+	}
+	return w.Classify(err)
+}
+
+// Start runs the worker in the background until ctx is done: it drains
+// the outbox whenever it is kicked, when a retry falls due and every poll
+// interval. The returned channel is closed once it has stopped. Call it
+// before serving requests, so their Kicks wake this run rather than
+// starting another; once ctx is done Kick does nothing, so no send
+// outlives it.
+func (w *Worker) Start(ctx context.Context) <-chan struct{} {
+	w.mu.Lock()
+	w.ctx = ctx
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	if !w.running.CompareAndSwap(false, true) {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		w.run(ctx)
+	}()
+	return done
+}
+
+func (w *Worker) run(ctx context.Context) {
+	defer w.running.Store(false)
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for {
+		if _, err := w.Drain(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Outbox: %v", err)
+		}
+		t.Reset(w.sleep())
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.kicks():
+		case <-t.C:
+		}
+	}
+}
+
+// sleep returns how long run may idle: until the earliest retry this
+// worker scheduled, or the poll interval for jobs queued elsewhere.
+func (w *Worker) sleep() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nextRetry.IsZero() {
+		return pollInterval
+	}
+	until := time.Until(w.nextRetry)
+	if until <= 0 {
+		// Due: the next drain picks it up.
+		w.nextRetry = time.Time{}
+		return 0
+	}
+	return min(until, pollInterval)
+}
+
+// Kick asks for the outbox to be drained soon, starting a background run
+// if none is going, under the context given to Start if any. It does
+// nothing once that context is done, and with Sync: a background run
+// would be frozen with the process, holding its leases, so jobs wait for
+// Dispatch or Drain.
+func (w *Worker) Kick() {
+	w.mu.Lock()
+	ctx := w.ctx
+	w.mu.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if w.Sync || ctx.Err() != nil {
+		return
+	}
+	if w.running.CompareAndSwap(false, true) {
+		go w.run(ctx)
+	}
+	select {
+	case w.kicks() <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) kicks() chan struct{} {
+	w.kickOnce.Do(func() {
+		w.kick = make(chan struct{}, 1)
+	})
+	return w.kick
+}
@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/registration"
+)
+
+func TestDispatchSync(t *testing.T) {
+	ctx := context.Background()
+	store := registration.NewMemory()
+	for _, email := range []string{"jane@example.com", "bob@example.com"} {
+		if err := store.Create(ctx, &registration.Registration{Email: email}, registration.JobWelcome); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sent []string
+	fail := false
+	w := &Worker{
+		Store: store,
+		Deliver: func(ctx context.Context, job registration.Job) (registration.EmailStatus, string, error) {
+			if ctx.Err() != nil {
+				return "", "", ctx.Err()
+			}
+			if fail {
+				return "", "", errors.New("connection reset")
+			}
+			sent = append(sent, job.Key)
+			return registration.EmailSent, "msg-1", nil
+		},
+		Policy: Policy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		Sync:   true,
+	}
+
+	// A canceled request still sends: the client only hung up.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	w.Dispatch(canceled, "jane@example.com")
+	if len(sent) != 1 || sent[0] != "jane@example.com" {
+		t.Fatalf("sent %v, want only jane's email", sent)
+	}
+	if r, _ := store.Get(ctx, "jane@example.com"); r.EmailStatus != registration.EmailSent {
+		t.Errorf("email status %s, want sent", r.EmailStatus)
+	}
+
+	// A failed send is left queued for a later Drain.
+	fail = true
+	w.Dispatch(ctx, "bob@example.com")
+	jobs, _ := store.Jobs(ctx)
+	if len(jobs) != 1 || jobs[0].State != registration.JobQueued || jobs[0].Attempts != 1 || !jobs[0].NextAttemptAt.After(time.Now()) {
+		t.Errorf("jobs after a failed send = %+v", jobs)
+	}
+
+	// Kick starts nothing in the background.
+	w.Kick()
+	if w.running.Load() {
+		t.Error("Kick started a background run with Sync")
+	}
+}
+
+func TestDispatchSyncTimeout(t *testing.T) {
+	ctx := context.Background()
+	store := registration.NewMemory()
+	if err := store.Create(ctx, &registration.Registration{Email: "jane@example.com"}, registration.JobWelcome); err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{
+		Store: store,
+		// A provider that never answers.
+		Deliver: func(ctx context.Context, job registration.Job) (registration.EmailStatus, string, error) {
+			<-ctx.Done()
+			return "", "", ctx.Err()
+		},
+		Policy:      Policy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		Sync:        true,
+		SyncTimeout: 50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	w.Dispatch(ctx, "jane@example.com")
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("Dispatch took %v", waited)
+	}
+	jobs, _ := store.Jobs(ctx)
+	if len(jobs) != 1 || jobs[0].State != registration.JobQueued || jobs[0].Attempts != 1 || !jobs[0].NextAttemptAt.After(time.Now()) {
+		t.Errorf("jobs after a send that timed out = %+v", jobs)
+	}
+}
+
+func TestDrainInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := registration.NewMemory()
+	if err := store.Create(ctx, &registration.Registration{Email: "jane@example.com"}, registration.JobWelcome); err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{
+		Store: store,
+		Deliver: func(ctx context.Context, job registration.Job) (registration.EmailStatus, string, error) {
+			cancel()
+			return "", "", Permanent(ctx.Err())
+		},
+		Policy: Policy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	}
+	w.Drain(ctx)
+
+	jobs, _ := store.Jobs(context.Background())
+	if len(jobs) != 1 || jobs[0].State != registration.JobQueued || jobs[0].NextAttemptAt.After(time.Now()) {
+		t.Errorf("jobs after an interrupted drain = %+v, want the job queued and due", jobs)
+	}
+}
+
+func TestStart(t *testing.T) {
+	store := registration.NewMemory()
+	sent := make(chan string, 10)
+	w := &Worker{
+		Store: store,
+		Deliver: func(ctx context.Context, job registration.Job) (registration.EmailStatus, string, error) {
+			sent <- job.Key
+			return registration.EmailSent, "", nil
+		},
+		Policy: Policy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := w.Start(ctx)
+
+	create := func(email string) {
+		if err := store.Create(context.Background(), &registration.Registration{Email: email}, registration.JobWelcome); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create("jane@example.com")
+	w.Kick()
+	select {
+	case key := <-sent:
+		if key != "jane@example.com" {
+			t.Errorf("sent %s", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("kicked job not sent")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker still running after its context ended")
+	}
+
+	// Kicks after shutdown start nothing.
+	create("bob@example.com")
+	w.Kick()
+	if w.running.Load() {
+		t.Error("Kick restarted the worker after its context ended")
+	}
+}
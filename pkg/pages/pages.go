@@ -0,0 +1,50 @@
+// Package pages renders the small standalone HTML pages that links in
+// emails land on (unsubscribe, confirmation).
+package pages
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background: #f8fafc; color: #333; margin: 0; padding: 40px 20px; }
+        .card { max-width: 480px; margin: 0 auto; background: #fff; border-radius: 16px; padding: 40px 30px; text-align: center; box-shadow: 0 10px 40px rgba(0, 0, 0, 0.1); }
+        h1 { font-size: 24px; color: #1a1a1a; margin: 0 0 16px; }
+        p { font-size: 16px; color: #4a4a4a; }
+        button { background: #00C851; color: #fff; border: 0; border-radius: 50px; padding: 14px 40px; font-size: 16px; font-weight: 700; cursor: pointer; }
+    </style>
+</head>
+<body>
+    <div class="card">
+        <h1>{{.Heading}}</h1>
+        <p>{{.Text}}</p>
+        {{if .Action}}<form method="post" action="{{.Action}}"><button type="submit">{{.Button}}</button></form>{{end}}
+    </div>
+</body>
+</html>
+`))
+
+// Page is the content of one page. Action and Button add a form that
+// POSTs to Action.
+type Page struct {
+	Locale  string
+	Title   string
+	Heading string
+	Text    string
+	Button  string
+	Action  string
+}
+
+// Render writes p with status.
+func Render(w http.ResponseWriter, status int, p Page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.Execute(w, p)
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps buckets in process memory. On serverless platforms every
+// instance has its own, so limits are per instance; use Redis to share
+// them.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time
+	swept   time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]time.Time)}
+}
+
+// sweepInterval bounds how often Take scans for buckets that have fully
+// refilled and can be forgotten.
+const sweepInterval = time.Minute
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.swept) > sweepInterval {
+		for k, tat := range m.buckets {
+			if tat.Before(now) {
+				delete(m.buckets, k)
+			}
+		}
+		m.swept = now
+	}
+
+	next, wait := take(m.buckets[key], now, limit)
+	m.buckets[key] = next
+	return wait, nil
+}
@@ -0,0 +1,130 @@
+// Package ratelimit throttles requests with token buckets kept in a
+// pluggable Store. Buckets are implemented with GCRA (the generic cell
+// rate algorithm), which behaves like a token bucket refilled continuously
+// but needs a single timestamp per key, so a shared backend can update it
+// in one atomic step.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled evenly over Period: a
+// Limit of 10 per minute admits 10 back-to-back requests, then one every
+// 6 seconds.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Enabled reports whether the limit applies at all.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Period > 0
+}
+
+// interval is the time it takes to earn back one request.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Burst)
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// ParseLimit parses "<burst>/<period>", e.g. "10/1m" or "3/1h". "off" and
+// "0" disable the limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+	burst, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("want <count>/<duration>, got %q", s)
+	}
+	n, err := strconv.Atoi(burst)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid count in %q", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid duration in %q", s)
+	}
+	if d/time.Duration(n) < time.Millisecond {
+		return Limit{}, fmt.Errorf("%q is finer than one request per millisecond", s)
+	}
+	return Limit{Burst: n, Period: d}, nil
+}
+
+// Store holds the buckets. Take spends one request from key's bucket and
+// returns zero if it was allowed, or how long until it would be. Denied
+// requests don't count against the bucket.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error)
+}
+
+// take applies GCRA to a bucket whose theoretical arrival time is tat and
+// returns the new tat, or the wait when the request is denied.
+func take(tat, now time.Time, limit Limit) (next time.Time, wait time.Duration) {
+	if tat.Before(now) {
+		tat = now
+	}
+	next = tat.Add(limit.interval())
+	allowAt := next.Add(-limit.Period)
+	if now.Before(allowAt) {
+		return tat, allowAt.Sub(now)
+	}
+	return next, 0
+}
+
+// RetryAfter formats a wait for the Retry-After header, in whole seconds
+// rounded up.
+func RetryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// ClientAddr returns the client's IP address. Behind a trusted proxy
+// (Vercel overwrites these headers) it is the forwarded client address;
+// otherwise the peer address.
+func ClientAddr(r *http.Request, trustProxy bool) string {
+	var addr string
+	if trustProxy {
+		addr = strings.TrimSpace(r.Header.Get("X-Real-IP"))
+		if addr == "" {
+			addr, _, _ = strings.Cut(r.Header.Get("X-Forwarded-For"), ",")
+			addr = strings.TrimSpace(addr)
+		}
+	}
+	if addr == "" {
+		addr = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+	}
+	return addr
+}
+
+// ClientIP returns the address to throttle r by: ClientAddr, with IPv6
+// clients grouped by /64 since a single host typically controls a whole
+// prefix.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	addr := ClientAddr(r, trustProxy)
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return ip.String()
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestTake(t *testing.T) {
+	limit := Limit{Burst: 3, Period: 3 * time.Second} // one per second
+	tests := []struct {
+		name     string
+		tat      time.Time
+		now      time.Duration
+		wantTAT  time.Duration
+		wantWait time.Duration
+	}{
+		{name: "new bucket", now: 0, wantTAT: time.Second},
+		{name: "refilled bucket", tat: epoch.Add(-time.Hour), now: 0, wantTAT: time.Second},
+		{name: "second of the burst", tat: epoch.Add(time.Second), now: 0, wantTAT: 2 * time.Second},
+		{name: "last of the burst", tat: epoch.Add(2 * time.Second), now: 0, wantTAT: 3 * time.Second},
+		{name: "burst spent", tat: epoch.Add(3 * time.Second), now: 0, wantTAT: 3 * time.Second, wantWait: time.Second},
+		{name: "partly refilled", tat: epoch.Add(3 * time.Second), now: 400 * time.Millisecond, wantTAT: 3 * time.Second, wantWait: 600 * time.Millisecond},
+		{name: "one earned back", tat: epoch.Add(3 * time.Second), now: time.Second, wantTAT: 4 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tat := tt.tat
+			if tat.IsZero() {
+				tat = epoch
+			}
+			next, wait := take(tat, epoch.Add(tt.now), limit)
+			if want := epoch.Add(tt.wantTAT); !next.Equal(want) || wait != tt.wantWait {
+				t.Errorf("take = %v, %v; want %v, %v", next.Sub(epoch), wait, want.Sub(epoch), tt.wantWait)
+			}
+		})
+	}
+}
+
+// testStore spends a bucket of 3 per 3 seconds and checks the burst, the
+// denial and the refill, with separate keys kept apart.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	limit := Limit{Burst: 3, Period: 3 * time.Second}
+	take := func(key string, at time.Duration) time.Duration {
+		t.Helper()
+		wait, err := s.Take(ctx, key, limit, epoch.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wait
+	}
+
+	for i := range 3 {
+		if wait := take("a", 0); wait != 0 {
+			t.Fatalf("request %d of the burst waited %v", i+1, wait)
+		}
+	}
+	if wait := take("a", 0); wait != time.Second {
+		t.Errorf("request over the burst: wait %v, want 1s", wait)
+	}
+	// The denied request did not count, so one second earns one back.
+	if wait := take("a", 500*time.Millisecond); wait != 500*time.Millisecond {
+		t.Errorf("wait %v, want 500ms", wait)
+	}
+	if wait := take("a", time.Second); wait != 0 {
+		t.Errorf("request after a refill waited %v", wait)
+	}
+	if wait := take("b", time.Second); wait != 0 {
+		t.Errorf("other key waited %v", wait)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestMemorySweep(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	limit := Limit{Burst: 1, Period: time.Second}
+	m.Take(ctx, "a", limit, epoch)
+	m.Take(ctx, "b", limit, epoch.Add(2*sweepInterval))
+	if len(m.buckets) != 1 {
+		t.Errorf("%d buckets after a sweep, want 1", len(m.buckets))
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Limit
+		err   bool
+	}{
+		{input: "10/1m", want: Limit{Burst: 10, Period: time.Minute}},
+		{input: " 3/1h ", want: Limit{Burst: 3, Period: time.Hour}},
+		{input: "off"},
+		{input: "0"},
+		{input: "10", err: true},
+		{input: "0/1m", err: true},
+		{input: "-1/1m", err: true},
+		{input: "x/1m", err: true},
+		{input: "10/forever", err: true},
+		{input: "10/0s", err: true},
+		{input: "2000/1s", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.input)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, %v", tt.input, got, err)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remote     string
+		headers    map[string]string
+		trustProxy bool
+		want       string
+	}{
+		{name: "peer", remote: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "untrusted headers", remote: "192.0.2.1:1234", headers: map[string]string{"X-Real-IP": "198.51.100.7"}, want: "192.0.2.1"},
+		{name: "real ip", remote: "192.0.2.1:1234", headers: map[string]string{"X-Real-IP": "198.51.100.7"}, trustProxy: true, want: "198.51.100.7"},
+		{name: "forwarded for", remote: "192.0.2.1:1234", headers: map[string]string{"X-Forwarded-For": "198.51.100.7, 10.0.0.1"}, trustProxy: true, want: "198.51.100.7"},
+		{name: "no headers behind proxy", remote: "192.0.2.1:1234", trustProxy: true, want: "192.0.2.1"},
+		{name: "ipv6 prefix", remote: "[2001:db8:1:2:3:4:5:6]:1234", want: "2001:db8:1:2::/64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := ClientIP(r, tt.trustProxy); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for wait, want := range map[time.Duration]string{
+		time.Second:             "1",
+		1500 * time.Millisecond: "2",
+		time.Millisecond:        "1",
+	} {
+		if got := RetryAfter(wait); got != want {
+			t.Errorf("RetryAfter(%v) = %q, want %q", wait, got, want)
+		}
+	}
+}
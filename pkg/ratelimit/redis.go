@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"goalhero-emailer/pkg/redis"
+)
+
+// gcraScript is take() as a Redis script, in milliseconds, so the read
+// and update happen atomically on the server. The key expires once the
+// bucket has refilled.
+const gcraScript = `
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then tat = now end
+local new_tat = tat + interval
+local allow_at = new_tat - period
+if now < allow_at then return allow_at - now end
+redis.call('SET', KEYS[1], new_tat, 'PX', new_tat - now)
+return 0
+`
+
+var gcra = redis.NewScript(gcraScript)
+
+const defaultRedisPrefix = "goalhero:ratelimit:"
+
+// Redis keeps buckets in Redis, so limits hold across serverless
+// instances.
+type Redis struct {
+	// Prefix namespaces the keys.
+	Prefix string
+
+	client *redis.Client
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{Prefix: defaultRedisPrefix, client: client}
+}
+
+func (r *Redis) Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error) {
+	reply, err := r.client.Eval(ctx, gcra, []string{r.Prefix + key},
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(limit.interval().Milliseconds(), 10),
+		strconv.FormatInt(limit.Period.Milliseconds(), 10),
+	)
+	if err != nil {
+		return 0, err
+	}
+	ms, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected reply %T to rate limit script", reply)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"goalhero-emailer/pkg/redis"
+	"goalhero-emailer/pkg/redistest"
+)
+
+// goGCRA is gcraScript in Go for redistest.
+func goGCRA(db *redistest.DB, keys, args []string) any {
+	now, _ := strconv.ParseInt(args[0], 10, 64)
+	interval, _ := strconv.ParseInt(args[1], 10, 64)
+	period, _ := strconv.ParseInt(args[2], 10, 64)
+	tat := now
+	if v, ok := db.Get(keys[0]); ok {
+		tat, _ = strconv.ParseInt(v, 10, 64)
+	}
+	if tat < now {
+		tat = now
+	}
+	next := tat + interval
+	if allowAt := next - period; now < allowAt {
+		return allowAt - now
+	}
+	db.Set(keys[0], strconv.FormatInt(next, 10))
+	return int64(0)
+}
+
+func newTestRedis(t *testing.T) (*Redis, *redistest.Server) {
+	srv := redistest.NewServer(t)
+	srv.HandleScript(gcraScript, goGCRA)
+	client, err := redis.New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRedis(client), srv
+}
+
+func TestRedis(t *testing.T) {
+	r, srv := newTestRedis(t)
+	testStore(t, r)
+
+	srv.Do(func(db *redistest.DB) {
+		if _, ok := db.Get(defaultRedisPrefix + "a"); !ok {
+			t.Errorf("no bucket under the key prefix")
+		}
+	})
+	// The first Take loads the script; the rest run it by digest.
+	calls := srv.Calls()
+	if want := []string{"EVALSHA", "EVAL", "EVALSHA"}; !slices.Equal(calls[:3], want) {
+		t.Errorf("calls = %v, want %v...", calls, want)
+	}
+	loads := 0
+	for _, c := range calls {
+		if c == "EVAL" {
+			loads++
+		}
+	}
+	if loads != 1 {
+		t.Errorf("script loaded %d times", loads)
+	}
+}
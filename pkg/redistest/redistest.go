@@ -0,0 +1,375 @@
+// Package redistest provides an in-process server speaking enough of the
+// Redis protocol to test the clients in this module. Lua scripts can't run
+// here, so tests register a Go version of each script they use.
+package redistest
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Script is the Go stand-in for a Lua script. It runs with the server
+// locked, so it is atomic like the real thing, and returns a reply: a
+// string, int64, nil or []any.
+type Script func(db *DB, keys, args []string) any
+
+// DB is the keyspace. Expiry is ignored.
+type DB struct {
+	strings map[string]string
+	hashes  map[string]map[string]string
+	zsets   map[string]map[string]float64
+}
+
+func (db *DB) Get(key string) (string, bool) {
+	v, ok := db.strings[key]
+	return v, ok
+}
+
+func (db *DB) Set(key, value string) {
+	db.strings[key] = value
+}
+
+func (db *DB) HGet(key, field string) (string, bool) {
+	v, ok := db.hashes[key][field]
+	return v, ok
+}
+
+func (db *DB) HSet(key, field, value string) {
+	if db.hashes[key] == nil {
+		db.hashes[key] = make(map[string]string)
+	}
+	db.hashes[key][field] = value
+}
+
+func (db *DB) HDel(key, field string) {
+	delete(db.hashes[key], field)
+}
+
+// HVals returns the values of hash key, sorted by field.
+func (db *DB) HVals(key string) []string {
+	fields := slices.Sorted(maps.Keys(db.hashes[key]))
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		values[i] = db.hashes[key][f]
+	}
+	return values
+}
+
+func (db *DB) ZAdd(key string, score float64, member string) {
+	if db.zsets[key] == nil {
+		db.zsets[key] = make(map[string]float64)
+	}
+	db.zsets[key][member] = score
+}
+
+func (db *DB) ZRem(key, member string) {
+	delete(db.zsets[key], member)
+}
+
+// ZRangeByScore returns the members of sorted set key scored at most max,
+// lowest first, like ZRANGEBYSCORE key -inf max.
+func (db *DB) ZRangeByScore(key string, max float64) []string {
+	var members []string
+	for m, score := range db.zsets[key] {
+		if score <= max {
+			members = append(members, m)
+		}
+	}
+	zset := db.zsets[key]
+	slices.SortFunc(members, func(a, b string) int {
+		if c := cmp.Compare(zset[a], zset[b]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return members
+}
+
+// Server is a Redis stand-in listening on 127.0.0.1.
+type Server struct {
+	// URL is the redis:// URL to connect to.
+	URL string
+
+	mu       sync.Mutex
+	password string
+	db       DB
+	scripts  map[string]Script // by SHA1 of the Lua source
+	cached   map[string]bool   // SHA1s loaded with EVAL
+	calls    []string
+	failing  map[string]string
+}
+
+// NewServer starts a server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		URL: "redis://" + ln.Addr().String(),
+		db: DB{
+			strings: make(map[string]string),
+			hashes:  make(map[string]map[string]string),
+			zsets:   make(map[string]map[string]float64),
+		},
+		scripts: make(map[string]Script),
+		cached:  make(map[string]bool),
+		failing: make(map[string]string),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+// HandleScript makes EVAL and EVALSHA of the Lua source run fn.
+func (s *Server) HandleScript(source string, fn Script) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[sha(source)] = fn
+}
+
+// RequirePassword makes connections opened from now on send AUTH with
+// password before any other command.
+func (s *Server) RequirePassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Fail makes every later command named cmd reply with the error msg.
+func (s *Server) Fail(cmd, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing[strings.ToUpper(cmd)] = msg
+}
+
+// Calls returns the names of the commands received so far.
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+// Do runs fn on the keyspace, e.g. to seed or inspect it.
+func (s *Server) Do(fn func(db *DB)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.db)
+}
+
+func sha(source string) string {
+	sum := sha1.Sum([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) serve(c net.Conn) {
+	defer c.Close()
+	rd := bufio.NewReader(c)
+	s.mu.Lock()
+	authed := s.password == ""
+	s.mu.Unlock()
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		var reply any
+		if !authed && strings.ToUpper(args[0]) != "AUTH" {
+			reply = errors.New("NOAUTH Authentication required.")
+		} else {
+			reply = s.exec(args, &authed)
+		}
+		if _, err := io.WriteString(c, encode(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(args []string, authed *bool) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cmd := strings.ToUpper(args[0])
+	s.calls = append(s.calls, cmd)
+	if msg, ok := s.failing[cmd]; ok {
+		return errors.New(msg)
+	}
+	arity := func(n int) bool { return len(args) >= n }
+
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "AUTH":
+		if !arity(2) || args[len(args)-1] != s.password {
+			return errors.New("WRONGPASS invalid username-password pair")
+		}
+		*authed = true
+		return "OK"
+	case "SELECT":
+		return "OK"
+	case "GET":
+		if !arity(2) {
+			break
+		}
+		if v, ok := s.db.Get(args[1]); ok {
+			return v
+		}
+		return nil
+	case "SET":
+		if !arity(3) {
+			break
+		}
+		s.db.Set(args[1], args[2])
+		return "OK"
+	case "HGET":
+		if !arity(3) {
+			break
+		}
+		if v, ok := s.db.HGet(args[1], args[2]); ok {
+			return v
+		}
+		return nil
+	case "HVALS":
+		if !arity(2) {
+			break
+		}
+		values := []any{}
+		for _, v := range s.db.HVals(args[1]) {
+			values = append(values, v)
+		}
+		return values
+	case "ZRANGEBYSCORE":
+		// Only the form ZRANGEBYSCORE key -inf max [LIMIT 0 count].
+		if !arity(4) || args[2] != "-inf" {
+			break
+		}
+		max, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return errors.New("ERR min or max is not a float")
+		}
+		members := s.db.ZRangeByScore(args[1], max)
+		if len(args) == 7 && strings.ToUpper(args[4]) == "LIMIT" {
+			if n, err := strconv.Atoi(args[6]); err == nil && n >= 0 && n < len(members) {
+				members = members[:n]
+			}
+		}
+		values := []any{}
+		for _, m := range members {
+			values = append(values, m)
+		}
+		return values
+	case "HMGET":
+		if !arity(3) {
+			break
+		}
+		values := make([]any, len(args)-2)
+		for i, f := range args[2:] {
+			if v, ok := s.db.HGet(args[1], f); ok {
+				values[i] = v
+			}
+		}
+		return values
+	case "HSET":
+		if !arity(4) || len(args)%2 != 0 {
+			break
+		}
+		for i := 2; i < len(args); i += 2 {
+			s.db.HSet(args[1], args[i], args[i+1])
+		}
+		return int64((len(args) - 2) / 2)
+	case "EVAL", "EVALSHA":
+		if !arity(3) {
+			break
+		}
+		digest := args[1]
+		if cmd == "EVAL" {
+			digest = sha(args[1])
+			s.cached[digest] = true
+		} else if !s.cached[digest] {
+			return errors.New("NOSCRIPT No matching script. Please use EVAL.")
+		}
+		fn, ok := s.scripts[digest]
+		if !ok {
+			return fmt.Errorf("ERR redistest has no Go version of script %s", digest)
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 || 3+n > len(args) {
+			return errors.New("ERR Number of keys can't be greater than number of args")
+		}
+		return fn(&s.db, args[3:3+n], args[3+n:])
+	default:
+		return fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 || line[0] != '*' {
+		return nil, fmt.Errorf("redistest: expected an array, got %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil || line[0] != '$' {
+			return nil, fmt.Errorf("redistest: expected a bulk string, got %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func encode(reply any) string {
+	switch v := reply.(type) {
+	case nil:
+		return "$-1\r\n"
+	case error:
+		return "-" + v.Error() + "\r\n"
+	case int64:
+		return ":" + strconv.FormatInt(v, 10) + "\r\n"
+	case int:
+		return ":" + strconv.Itoa(v) + "\r\n"
+	case string:
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	case []any:
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%d\r\n", len(v))
+		for _, item := range v {
+			b.WriteString(encode(item))
+		}
+		return b.String()
+	}
+	return fmt.Sprintf("-ERR redistest cannot encode %T\r\n", reply)
+}
@@ -0,0 +1,200 @@
+package registration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"goalhero-emailer/pkg/docstore"
+)
+
+// Document is a Store kept in a single JSON document shared by every
+// instance, see docstore. Each call reads the current document, so
+// registrations made on one instance are seen by all of them.
+type Document struct {
+	doc docstore.Store
+}
+
+func NewDocument(doc docstore.Store) *Document {
+	return &Document{doc: doc}
+}
+
+// load decodes raw, which may be nil for a new document.
+func load(raw []byte) (db, error) {
+	d := newDB()
+	if raw == nil {
+		return d, nil
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &sections); err != nil {
+		return d, fmt.Errorf("parse registrations: %v", err)
+	}
+	if err := d.decode(sections); err != nil {
+		return d, fmt.Errorf("parse registrations: %v", err)
+	}
+	return d, nil
+}
+
+// decode reads a Document. Documents written before the outbox existed
+// are a bare map of registrations; keys there are addresses, so they can't
+// clash with the section names.
+func (d *db) decode(raw map[string]json.RawMessage) error {
+	_, hasRegs := raw["registrations"]
+	_, hasOutbox := raw["outbox"]
+	if !hasRegs && !hasOutbox {
+		for k, v := range raw {
+			var r Registration
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			d.Registrations[k] = r
+		}
+		return nil
+	}
+	for name, dst := range map[string]any{"registrations": &d.Registrations, "outbox": &d.Outbox} {
+		if v, ok := raw[name]; ok {
+			if err := json.Unmarshal(v, dst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// errUnchanged tells update that fn changed nothing worth saving.
+var errUnchanged = errors.New("unchanged")
+
+// view reads the document.
+func (d *Document) view(ctx context.Context) (db, error) {
+	raw, err := d.doc.Load(ctx)
+	if err != nil {
+		return db{}, err
+	}
+	return load(raw)
+}
+
+// update applies fn to the current document and saves it; nothing is
+// saved if fn fails.
+func (d *Document) update(ctx context.Context, fn func(state *db) error) error {
+	err := d.doc.Update(ctx, func(raw []byte) ([]byte, error) {
+		state, err := load(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(&state); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(state, "", "  ")
+	})
+	if errors.Is(err, errUnchanged) {
+		return nil
+	}
+	return err
+}
+
+func (d *Document) Create(ctx context.Context, r *Registration, jobs ...JobKind) error {
+	return d.update(ctx, func(state *db) error {
+		return state.create(r, time.Now().UTC(), jobs)
+	})
+}
+
+func (d *Document) Get(ctx context.Context, key string) (*Registration, error) {
+	state, err := d.view(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := state.Registrations[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (d *Document) SetEmailStatus(ctx context.Context, key string, status EmailStatus, messageID string) error {
+	return d.update(ctx, func(state *db) error {
+		return state.setEmailStatus(key, status, messageID, time.Now().UTC())
+	})
+}
+
+func (d *Document) Confirm(ctx context.Context, key string, at time.Time, jobs ...JobKind) error {
+	return d.update(ctx, func(state *db) error {
+		return state.confirm(key, at, jobs)
+	})
+}
+
+func (d *Document) Renew(ctx context.Context, key string, expires time.Time, jobs ...JobKind) error {
+	return d.update(ctx, func(state *db) error {
+		return state.renew(key, expires, jobs, time.Now().UTC())
+	})
+}
+
+func (d *Document) List(ctx context.Context) ([]Registration, error) {
+	state, err := d.view(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return state.list(), nil
+}
+
+func (d *Document) Enqueue(ctx context.Context, key string, kind JobKind) error {
+	return d.update(ctx, func(state *db) error {
+		return state.enqueue(key, kind, time.Now().UTC())
+	})
+}
+
+func (d *Document) ClaimJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Job, error) {
+	var jobs []Job
+	err := d.update(ctx, func(state *db) error {
+		purged := state.purgeExpired(now)
+		if jobs = state.claim("", now, lease, limit); len(jobs) == 0 && !purged {
+			return errUnchanged
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+func (d *Document) ClaimJobsFor(ctx context.Context, key string, now time.Time, lease time.Duration) ([]Job, error) {
+	var jobs []Job
+	err := d.update(ctx, func(state *db) error {
+		if jobs = state.claim(key, now, lease, 0); len(jobs) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+func (d *Document) CompleteJob(ctx context.Context, id string, status EmailStatus, messageID string) error {
+	return d.update(ctx, func(state *db) error {
+		return state.completeJob(id, status, messageID, time.Now().UTC())
+	})
+}
+
+func (d *Document) RetryJob(ctx context.Context, id string, at time.Time, lastErr string) error {
+	return d.update(ctx, func(state *db) error {
+		return state.retryJob(id, at, lastErr, time.Now().UTC())
+	})
+}
+
+func (d *Document) BuryJob(ctx context.Context, id, lastErr string) error {
+	return d.update(ctx, func(state *db) error {
+		return state.buryJob(id, lastErr, time.Now().UTC())
+	})
+}
+
+func (d *Document) RequeueJob(ctx context.Context, id string) error {
+	return d.update(ctx, func(state *db) error {
+		return state.requeueJob(id, time.Now().UTC())
+	})
+}
+
+func (d *Document) Jobs(ctx context.Context) ([]Job, error) {
+	state, err := d.view(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return state.jobs(), nil
+}
@@ -0,0 +1,106 @@
+package registration
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Store for tests and local development.
+type Memory struct {
+	mu sync.Mutex
+	db db
+}
+
+func NewMemory() *Memory {
+	return &Memory{db: newDB()}
+}
+
+func (m *Memory) Create(ctx context.Context, r *Registration, jobs ...JobKind) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.create(r, time.Now().UTC(), jobs)
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (*Registration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.db.Registrations[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (m *Memory) SetEmailStatus(ctx context.Context, key string, status EmailStatus, messageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.setEmailStatus(key, status, messageID, time.Now().UTC())
+}
+
+func (m *Memory) Confirm(ctx context.Context, key string, at time.Time, jobs ...JobKind) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.confirm(key, at, jobs)
+}
+
+func (m *Memory) Renew(ctx context.Context, key string, expires time.Time, jobs ...JobKind) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.renew(key, expires, jobs, time.Now().UTC())
+}
+
+func (m *Memory) List(ctx context.Context) ([]Registration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.list(), nil
+}
+
+func (m *Memory) Enqueue(ctx context.Context, key string, kind JobKind) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.enqueue(key, kind, time.Now().UTC())
+}
+
+func (m *Memory) ClaimJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.db.purgeExpired(now)
+	return m.db.claim("", now, lease, limit), nil
+}
+
+func (m *Memory) ClaimJobsFor(ctx context.Context, key string, now time.Time, lease time.Duration) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.claim(key, now, lease, 0), nil
+}
+
+func (m *Memory) CompleteJob(ctx context.Context, id string, status EmailStatus, messageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.completeJob(id, status, messageID, time.Now().UTC())
+}
+
+func (m *Memory) RetryJob(ctx context.Context, id string, at time.Time, lastErr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.retryJob(id, at, lastErr, time.Now().UTC())
+}
+
+func (m *Memory) BuryJob(ctx context.Context, id, lastErr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.buryJob(id, lastErr, time.Now().UTC())
+}
+
+func (m *Memory) RequeueJob(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.requeueJob(id, time.Now().UTC())
+}
+
+func (m *Memory) Jobs(ctx context.Context) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.jobs(), nil
+}
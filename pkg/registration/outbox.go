@@ -0,0 +1,277 @@
+package registration
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// JobKind names the email a Job sends.
+type JobKind string
+
+const (
+	JobWelcome JobKind = "welcome"
+	JobConfirm JobKind = "confirm"
+)
+
+// JobState is where a Job is in the outbox.
+type JobState string
+
+const (
+	// JobQueued jobs wait for NextAttemptAt.
+	JobQueued JobState = "queued"
+	// JobSending jobs are leased to a worker until NextAttemptAt; a job
+	// whose worker died becomes due again when the lease runs out.
+	JobSending JobState = "sending"
+	// JobDead jobs ran out of attempts and wait for an operator.
+	JobDead JobState = "dead"
+)
+
+// Job is an email waiting in the outbox. Jobs live in the same store as
+// registrations so a signup and its email are written together; delivered
+// jobs are removed.
+type Job struct {
+	ID            string    `json:"id"`
+	Key           string    `json:"key"`
+	Kind          JobKind   `json:"kind"`
+	State         JobState  `json:"state"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+var (
+	ErrJobNotFound = errors.New("outbox job not found")
+	ErrJobLeased   = errors.New("outbox job is being sent")
+)
+
+// db is the state behind both stores. The JSON shape is the Document format.
+type db struct {
+	Registrations map[string]Registration `json:"registrations"`
+	Outbox        map[string]Job          `json:"outbox"`
+}
+
+func newDB() db {
+	return db{Registrations: make(map[string]Registration), Outbox: make(map[string]Job)}
+}
+
+func (d *db) create(r *Registration, now time.Time, jobs []JobKind) error {
+	d.purgeExpired(now)
+	key := keyOf(r)
+	if _, ok := d.Registrations[key]; ok {
+		return ErrExists
+	}
+	prepare(r, now)
+	d.Registrations[key] = *r
+	for _, kind := range jobs {
+		d.enqueue(key, kind, now)
+	}
+	return nil
+}
+
+// purgeExpired drops pending registrations past their window along with
+// their jobs, so a new signup for the address neither finds its queued
+// confirmation nor brings back a dead one. It reports whether it dropped
+// any.
+func (d *db) purgeExpired(now time.Time) bool {
+	purged := make(map[string]bool)
+	for k, r := range d.Registrations {
+		if r.Expired(now) {
+			delete(d.Registrations, k)
+			purged[k] = true
+		}
+	}
+	for id, j := range d.Outbox {
+		if purged[j.Key] {
+			delete(d.Outbox, id)
+		}
+	}
+	return len(purged) > 0
+}
+
+func (d *db) setEmailStatus(key string, status EmailStatus, messageID string, now time.Time) error {
+	r, ok := d.Registrations[key]
+	if !ok {
+		return ErrNotFound
+	}
+	r.EmailStatus = status
+	r.MessageID = messageID
+	r.UpdatedAt = now
+	d.Registrations[key] = r
+	return nil
+}
+
+func (d *db) confirm(key string, at time.Time, jobs []JobKind) error {
+	if err := confirm(d.Registrations, key, at); err != nil {
+		return err
+	}
+	for _, kind := range jobs {
+		d.enqueue(key, kind, at)
+	}
+	return nil
+}
+
+func (d *db) renew(key string, expires time.Time, jobs []JobKind, now time.Time) error {
+	if err := renew(d.Registrations, key, expires, now); err != nil {
+		return err
+	}
+	for _, kind := range jobs {
+		d.enqueue(key, kind, now)
+		d.expedite(key, kind, now)
+	}
+	return nil
+}
+
+// expedite makes a queued kind job for key that is backing off due now,
+// so a renewed signup isn't kept waiting for the retry.
+func (d *db) expedite(key string, kind JobKind, now time.Time) {
+	for id, j := range d.Outbox {
+		if j.Key == key && j.Kind == kind && j.State == JobQueued && j.NextAttemptAt.After(now) {
+			j.NextAttemptAt = now
+			j.UpdatedAt = now
+			d.Outbox[id] = j
+		}
+	}
+}
+
+func (d *db) list() []Registration {
+	rs := make([]Registration, 0, len(d.Registrations))
+	for _, r := range d.Registrations {
+		rs = append(rs, r)
+	}
+	return sortRegistrations(rs)
+}
+
+// enqueue queues a kind email for key, due now, unless one is already
+// queued or sending. A dead-lettered one is revived.
+func (d *db) enqueue(key string, kind JobKind, now time.Time) error {
+	if _, ok := d.Registrations[key]; !ok {
+		return ErrNotFound
+	}
+	for _, j := range d.Outbox {
+		if j.Key == key && j.Kind == kind {
+			if j.State == JobDead {
+				return d.requeueJob(j.ID, now)
+			}
+			return nil
+		}
+	}
+	j := Job{
+		ID:            newID(),
+		Key:           key,
+		Kind:          kind,
+		State:         JobQueued,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	d.Outbox[j.ID] = j
+	return nil
+}
+
+// claim leases up to limit due jobs, earliest first, and counts the
+// attempt. A non-empty key claims only that registration's jobs; a limit
+// of 0 claims them all.
+func (d *db) claim(key string, now time.Time, lease time.Duration, limit int) []Job {
+	var due []Job
+	for _, j := range d.Outbox {
+		if (key == "" || j.Key == key) && j.State != JobDead && !j.NextAttemptAt.After(now) {
+			due = append(due, j)
+		}
+	}
+	sortJobs(due)
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].State = JobSending
+		due[i].Attempts++
+		due[i].NextAttemptAt = now.Add(lease)
+		due[i].UpdatedAt = now
+		d.Outbox[due[i].ID] = due[i]
+	}
+	return due
+}
+
+// completeJob removes a delivered job and records the email outcome on
+// its registration, if that still exists and status is set.
+func (d *db) completeJob(id string, status EmailStatus, messageID string, now time.Time) error {
+	j, ok := d.Outbox[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	delete(d.Outbox, id)
+	if status == "" {
+		return nil
+	}
+	if err := d.setEmailStatus(j.Key, status, messageID, now); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (d *db) retryJob(id string, at time.Time, lastErr string, now time.Time) error {
+	j, ok := d.Outbox[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	j.State = JobQueued
+	j.NextAttemptAt = at
+	j.LastError = lastErr
+	j.UpdatedAt = now
+	d.Outbox[id] = j
+	return nil
+}
+
+// buryJob dead-letters a job and marks its registration's email failed.
+func (d *db) buryJob(id, lastErr string, now time.Time) error {
+	j, ok := d.Outbox[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	j.State = JobDead
+	j.LastError = lastErr
+	j.UpdatedAt = now
+	d.Outbox[id] = j
+	if r, ok := d.Registrations[j.Key]; ok {
+		d.setEmailStatus(j.Key, EmailFailed, r.MessageID, now)
+	}
+	return nil
+}
+
+// requeueJob makes a job due now with a fresh set of attempts.
+func (d *db) requeueJob(id string, now time.Time) error {
+	j, ok := d.Outbox[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if j.State == JobSending {
+		return ErrJobLeased
+	}
+	j.State = JobQueued
+	j.Attempts = 0
+	j.NextAttemptAt = now
+	j.UpdatedAt = now
+	d.Outbox[id] = j
+	return nil
+}
+
+func (d *db) jobs() []Job {
+	js := make([]Job, 0, len(d.Outbox))
+	for _, j := range d.Outbox {
+		js = append(js, j)
+	}
+	sortJobs(js)
+	return js
+}
+
+func sortJobs(js []Job) {
+	sort.Slice(js, func(i, j int) bool {
+		if !js[i].NextAttemptAt.Equal(js[j].NextAttemptAt) {
+			return js[i].NextAttemptAt.Before(js[j].NextAttemptAt)
+		}
+		return js[i].ID < js[j].ID
+	})
+}
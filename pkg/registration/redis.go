@@ -0,0 +1,439 @@
+package registration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"goalhero-emailer/pkg/redis"
+)
+
+// saveScript replaces the record of one registration if it still is what
+// the caller read, and points the indexes at it and its jobs.
+//
+// KEYS: records hash, due sorted set, job index hash, expiry sorted set.
+// ARGV: key, the record read (empty if there was none), the new record
+// (empty to delete it), when a pending registration expires in
+// milliseconds (empty if it doesn't), the number of jobs n, n pairs of
+// job ID and due time in milliseconds (empty for a dead job, which is
+// never due), then the IDs of jobs to forget.
+const saveScript = `
+local key, old, new = ARGV[1], ARGV[2], ARGV[3]
+if old == '' then
+  if new ~= '' and redis.call('HSETNX', KEYS[1], key, new) == 0 then return 0 end
+else
+  if redis.call('HGET', KEYS[1], key) ~= old then return 0 end
+  if new == '' then
+    redis.call('HDEL', KEYS[1], key)
+  else
+    redis.call('HSET', KEYS[1], key, new)
+  end
+end
+if ARGV[4] == '' then
+  redis.call('ZREM', KEYS[4], key)
+else
+  redis.call('ZADD', KEYS[4], ARGV[4], key)
+end
+local n = tonumber(ARGV[5])
+for i = 6, 5 + 2 * n, 2 do
+  if ARGV[i + 1] == '' then
+    redis.call('ZREM', KEYS[2], ARGV[i])
+  else
+    redis.call('ZADD', KEYS[2], ARGV[i + 1], ARGV[i])
+  end
+  redis.call('HSET', KEYS[3], ARGV[i], key)
+end
+for i = 6 + 2 * n, #ARGV do
+  redis.call('ZREM', KEYS[2], ARGV[i])
+  redis.call('HDEL', KEYS[3], ARGV[i])
+end
+return 1
+`
+
+var saveRecord = redis.NewScript(saveScript)
+
+const (
+	defaultRedisPrefix = "goalhero:registrations:"
+
+	maxSaveAttempts = 10
+	saveBackoff     = 5 * time.Millisecond
+)
+
+// ErrConflict means a registration kept changing under an update.
+var ErrConflict = errors.New("registration changed concurrently, giving up")
+
+// Redis is a Store for instances sharing a Redis server. Each registration
+// is one field of a hash, keyed by Registration.Key and holding its outbox
+// jobs too, so a signup and its email are still written together. A write
+// reads that field and swaps it back only if it is unchanged: only writes
+// for the same address contend, and a signup claims its key with HSETNX. A
+// sorted set orders the jobs that aren't dead by due time, a hash maps job
+// IDs to their registration, and another sorted set orders pending
+// registrations by expiry so ClaimJobs can find the expired ones.
+type Redis struct {
+	client   *redis.Client
+	records  string
+	due      string
+	jobKeys  string
+	expiries string
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{
+		client:   client,
+		records:  defaultRedisPrefix + "records",
+		due:      defaultRedisPrefix + "due",
+		jobKeys:  defaultRedisPrefix + "jobs",
+		expiries: defaultRedisPrefix + "expiries",
+	}
+}
+
+// record is what Redis stores for a registration.
+type record struct {
+	Registration Registration `json:"registration"`
+	Jobs         []Job        `json:"jobs,omitempty"`
+}
+
+// decodeRecord returns raw as a db holding key's registration and jobs;
+// an empty raw gives an empty db.
+func decodeRecord(key, raw string) (db, error) {
+	if raw == "" {
+		return newDB(), nil
+	}
+	var rec record
+	if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+		return newDB(), fmt.Errorf("parse registration %s: %v", key, err)
+	}
+	return rec.db(), nil
+}
+
+func (rec record) db() db {
+	d := newDB()
+	d.Registrations[rec.Registration.Key] = rec.Registration
+	for _, j := range rec.Jobs {
+		d.Outbox[j.ID] = j
+	}
+	return d
+}
+
+// encodeRecord is the inverse of decodeRecord. It returns "" once the
+// registration is gone.
+func encodeRecord(key string, d db) (string, error) {
+	r, ok := d.Registrations[key]
+	if !ok {
+		return "", nil
+	}
+	raw, err := json.Marshal(record{Registration: r, Jobs: d.jobs()})
+	return string(raw), err
+}
+
+// update applies fn to the registration with key and its jobs, and saves
+// them unless fn fails.
+func (s *Redis) update(ctx context.Context, key string, fn func(state *db) error) error {
+	for attempt := 1; ; attempt++ {
+		raw, err := s.get(ctx, key)
+		if err != nil {
+			return err
+		}
+		state, err := decodeRecord(key, raw)
+		if err != nil {
+			return err
+		}
+		before := state.jobs()
+		if err := fn(&state); errors.Is(err, errUnchanged) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		ok, err := s.save(ctx, key, raw, before, state)
+		if err != nil || ok {
+			return err
+		}
+		if attempt == maxSaveAttempts {
+			return ErrConflict
+		}
+		select {
+		case <-time.After(rand.N(time.Duration(attempt) * saveBackoff)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Redis) get(ctx context.Context, key string) (string, error) {
+	reply, err := s.client.Do(ctx, "HGET", s.records, key)
+	if err != nil {
+		return "", err
+	}
+	raw, _ := reply.(string)
+	return raw, nil
+}
+
+// save writes state over old, the record it was read from, and reports
+// whether old was still current. before are the jobs old held.
+func (s *Redis) save(ctx context.Context, key, old string, before []Job, state db) (bool, error) {
+	next, err := encodeRecord(key, state)
+	if err != nil {
+		return false, err
+	}
+	if next == old {
+		return true, nil
+	}
+	expires := ""
+	if r, ok := state.Registrations[key]; ok && r.State == StatePending && r.ExpiresAt != nil {
+		expires = strconv.FormatInt(r.ExpiresAt.UnixMilli(), 10)
+	}
+	args := []string{key, old, next, expires, strconv.Itoa(len(state.Outbox))}
+	for _, j := range state.jobs() {
+		due := ""
+		if j.State != JobDead {
+			due = strconv.FormatInt(j.NextAttemptAt.UnixMilli(), 10)
+		}
+		args = append(args, j.ID, due)
+	}
+	for _, j := range before {
+		if _, ok := state.Outbox[j.ID]; !ok {
+			args = append(args, j.ID)
+		}
+	}
+	reply, err := s.client.Eval(ctx, saveRecord, []string{s.records, s.due, s.jobKeys, s.expiries}, args...)
+	if err != nil {
+		return false, err
+	}
+	saved, ok := reply.(int64)
+	if !ok {
+		return false, errors.New("redis: unexpected reply to registration save script")
+	}
+	return saved == 1, nil
+}
+
+// all reads every record.
+func (s *Redis) all(ctx context.Context) ([]db, error) {
+	reply, err := s.client.Do(ctx, "HVALS", s.records)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T to HVALS %s", reply, s.records)
+	}
+	states := make([]db, 0, len(values))
+	for _, v := range values {
+		raw, _ := v.(string)
+		var rec record
+		if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+			return nil, fmt.Errorf("parse registrations: %v", err)
+		}
+		states = append(states, rec.db())
+	}
+	return states, nil
+}
+
+// keyOfJob returns the key of the registration holding job id.
+func (s *Redis) keyOfJob(ctx context.Context, id string) (string, error) {
+	reply, err := s.client.Do(ctx, "HGET", s.jobKeys, id)
+	if err != nil {
+		return "", err
+	}
+	key, ok := reply.(string)
+	if !ok {
+		return "", ErrJobNotFound
+	}
+	return key, nil
+}
+
+func (s *Redis) updateJob(ctx context.Context, id string, fn func(state *db) error) error {
+	key, err := s.keyOfJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, key, fn)
+}
+
+func (s *Redis) Create(ctx context.Context, r *Registration, jobs ...JobKind) error {
+	return s.update(ctx, keyOf(r), func(state *db) error {
+		return state.create(r, time.Now().UTC(), jobs)
+	})
+}
+
+func (s *Redis) Get(ctx context.Context, key string) (*Registration, error) {
+	raw, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	state, err := decodeRecord(key, raw)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := state.Registrations[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (s *Redis) SetEmailStatus(ctx context.Context, key string, status EmailStatus, messageID string) error {
+	return s.update(ctx, key, func(state *db) error {
+		return state.setEmailStatus(key, status, messageID, time.Now().UTC())
+	})
+}
+
+func (s *Redis) Confirm(ctx context.Context, key string, at time.Time, jobs ...JobKind) error {
+	return s.update(ctx, key, func(state *db) error {
+		return state.confirm(key, at, jobs)
+	})
+}
+
+func (s *Redis) Renew(ctx context.Context, key string, expires time.Time, jobs ...JobKind) error {
+	return s.update(ctx, key, func(state *db) error {
+		return state.renew(key, expires, jobs, time.Now().UTC())
+	})
+}
+
+func (s *Redis) List(ctx context.Context) ([]Registration, error) {
+	states, err := s.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]Registration, 0, len(states))
+	for _, state := range states {
+		rs = append(rs, state.list()...)
+	}
+	return sortRegistrations(rs), nil
+}
+
+func (s *Redis) Enqueue(ctx context.Context, key string, kind JobKind) error {
+	return s.update(ctx, key, func(state *db) error {
+		return state.enqueue(key, kind, time.Now().UTC())
+	})
+}
+
+// ClaimJobs drops the expired pending registrations, then takes the
+// earliest due jobs from the sorted set and claims them registration by
+// registration.
+func (s *Redis) ClaimJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Job, error) {
+	if err := s.purgeExpired(ctx, now); err != nil {
+		return nil, err
+	}
+	cmd := []string{"ZRANGEBYSCORE", s.due, "-inf", strconv.FormatInt(now.UnixMilli(), 10)}
+	if limit > 0 {
+		cmd = append(cmd, "LIMIT", "0", strconv.Itoa(limit))
+	}
+	reply, err := s.client.Do(ctx, cmd...)
+	if err != nil {
+		return nil, err
+	}
+	ids, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T to ZRANGEBYSCORE %s", reply, s.due)
+	}
+
+	var claimed []Job
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		key, err := s.keyOfJob(ctx, fmt.Sprint(id))
+		if errors.Is(err, ErrJobNotFound) {
+			continue
+		} else if err != nil {
+			return claimed, err
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		left := 0
+		if limit > 0 {
+			if left = limit - len(claimed); left <= 0 {
+				break
+			}
+		}
+		jobs, err := s.claim(ctx, key, now, lease, left)
+		if err != nil {
+			return claimed, err
+		}
+		claimed = append(claimed, jobs...)
+	}
+	sortJobs(claimed)
+	return claimed, nil
+}
+
+// purgeExpired drops the pending registrations that expired by now, and
+// their jobs.
+func (s *Redis) purgeExpired(ctx context.Context, now time.Time) error {
+	reply, err := s.client.Do(ctx, "ZRANGEBYSCORE", s.expiries, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	if err != nil {
+		return err
+	}
+	keys, ok := reply.([]any)
+	if !ok {
+		return fmt.Errorf("redis: unexpected reply %T to ZRANGEBYSCORE %s", reply, s.expiries)
+	}
+	for _, k := range keys {
+		err := s.update(ctx, fmt.Sprint(k), func(state *db) error {
+			if !state.purgeExpired(now) {
+				return errUnchanged
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Redis) ClaimJobsFor(ctx context.Context, key string, now time.Time, lease time.Duration) ([]Job, error) {
+	return s.claim(ctx, key, now, lease, 0)
+}
+
+func (s *Redis) claim(ctx context.Context, key string, now time.Time, lease time.Duration, limit int) ([]Job, error) {
+	var jobs []Job
+	err := s.update(ctx, key, func(state *db) error {
+		if jobs = state.claim(key, now, lease, limit); len(jobs) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+func (s *Redis) CompleteJob(ctx context.Context, id string, status EmailStatus, messageID string) error {
+	return s.updateJob(ctx, id, func(state *db) error {
+		return state.completeJob(id, status, messageID, time.Now().UTC())
+	})
+}
+
+func (s *Redis) RetryJob(ctx context.Context, id string, at time.Time, lastErr string) error {
+	return s.updateJob(ctx, id, func(state *db) error {
+		return state.retryJob(id, at, lastErr, time.Now().UTC())
+	})
+}
+
+func (s *Redis) BuryJob(ctx context.Context, id, lastErr string) error {
+	return s.updateJob(ctx, id, func(state *db) error {
+		return state.buryJob(id, lastErr, time.Now().UTC())
+	})
+}
+
+func (s *Redis) RequeueJob(ctx context.Context, id string) error {
+	return s.updateJob(ctx, id, func(state *db) error {
+		return state.requeueJob(id, time.Now().UTC())
+	})
+}
+
+func (s *Redis) Jobs(ctx context.Context) ([]Job, error) {
+	states, err := s.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var js []Job
+	for _, state := range states {
+		js = append(js, state.jobs()...)
+	}
+	sortJobs(js)
+	return js, nil
+}
@@ -0,0 +1,179 @@
+// Package registration stores beta signups so they can be invited when
+// the app ships.
+package registration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EmailStatus tracks the welcome email for a registration.
+type EmailStatus string
+
+const (
+	EmailPending    EmailStatus = "pending"
+	EmailSent       EmailStatus = "sent"
+	EmailFailed     EmailStatus = "failed"
+	EmailSuppressed EmailStatus = "suppressed"
+)
+
+// State is where a registration is in the (optional) double opt-in flow.
+type State string
+
+const (
+	// StateActive registrations are confirmed, or never needed confirming.
+	StateActive State = "active"
+	// StatePending registrations await confirmation until ExpiresAt.
+	StatePending State = "pending"
+)
+
+type Registration struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	// Key is the normalized address used to detect duplicates, see
+	// emailaddr.Normalize. Create derives it from Email when empty.
+	Key            string      `json:"key"`
+	Language       string      `json:"language"`
+	LanguageSource string      `json:"language_source"`
+	Source         string      `json:"source"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	EmailStatus    EmailStatus `json:"email_status"`
+	MessageID      string      `json:"message_id,omitempty"`
+
+	State       State      `json:"state"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// Expired reports whether r is pending past its confirmation window.
+func (r *Registration) Expired(now time.Time) bool {
+	return r.State == StatePending && r.ExpiresAt != nil && now.After(*r.ExpiresAt)
+}
+
+var (
+	ErrExists     = errors.New("registration already exists")
+	ErrNotFound   = errors.New("registration not found")
+	ErrNotPending = errors.New("registration is not pending")
+)
+
+// Store persists registrations keyed by Registration.Key, along with the
+// outbox of emails to send them.
+type Store interface {
+	// Create stores r, assigning ID, Key and timestamps if unset, and
+	// queues jobs for it in the same write, as Enqueue would. It returns
+	// ErrExists if the key is already registered; expired pending
+	// registrations don't count and are replaced.
+	Create(ctx context.Context, r *Registration, jobs ...JobKind) error
+	// Get returns the registration for key or ErrNotFound.
+	Get(ctx context.Context, key string) (*Registration, error)
+	// SetEmailStatus records the outcome of the welcome email.
+	SetEmailStatus(ctx context.Context, key string, status EmailStatus, messageID string) error
+	// Confirm activates a pending registration and queues jobs for it.
+	Confirm(ctx context.Context, key string, at time.Time, jobs ...JobKind) error
+	// Renew moves the expiry of a pending registration to expires and
+	// queues jobs for it in the same write, making any of them that are
+	// backing off due now. It returns ErrNotPending for a registration
+	// that was confirmed meanwhile.
+	Renew(ctx context.Context, key string, expires time.Time, jobs ...JobKind) error
+	// List returns all registrations, oldest first.
+	List(ctx context.Context) ([]Registration, error)
+
+	// Enqueue queues a kind email for the registration with key, unless
+	// one is already queued or sending; a dead-lettered one is requeued.
+	Enqueue(ctx context.Context, key string, kind JobKind) error
+	// ClaimJobs leases up to limit due jobs until now+lease and counts
+	// the attempt. It first drops the pending registrations that expired
+	// by now, with their jobs.
+	ClaimJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Job, error)
+	// ClaimJobsFor leases the due jobs of the registration with key, as
+	// ClaimJobs does.
+	ClaimJobsFor(ctx context.Context, key string, now time.Time, lease time.Duration) ([]Job, error)
+	// CompleteJob removes a delivered job and, if status is set, records
+	// it and messageID on the registration.
+	CompleteJob(ctx context.Context, id string, status EmailStatus, messageID string) error
+	// RetryJob puts a job back in the queue, due at.
+	RetryJob(ctx context.Context, id string, at time.Time, lastErr string) error
+	// BuryJob dead-letters a job and marks the registration's email
+	// failed.
+	BuryJob(ctx context.Context, id, lastErr string) error
+	// RequeueJob makes a queued or dead job due now with fresh attempts.
+	RequeueJob(ctx context.Context, id string) error
+	// Jobs returns the outbox, earliest due first.
+	Jobs(ctx context.Context) ([]Job, error)
+}
+
+func newID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// keyOf returns r.Key, deriving it from the address if unset.
+func keyOf(r *Registration) string {
+	if r.Key == "" {
+		r.Key = strings.ToLower(strings.TrimSpace(r.Email))
+	}
+	return r.Key
+}
+
+// prepare fills in the defaults Create promises.
+func prepare(r *Registration, now time.Time) {
+	if r.ID == "" {
+		r.ID = newID()
+	}
+
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = now
+	}
+	r.UpdatedAt = now
+	if r.EmailStatus == "" {
+		r.EmailStatus = EmailPending
+	}
+	if r.State == "" {
+		r.State = StateActive
+	}
+}
+
+// confirm activates regs[key].
+func confirm(regs map[string]Registration, key string, at time.Time) error {
+	r, ok := regs[key]
+	if !ok {
+		return ErrNotFound
+	}
+	r.State = StateActive
+	r.ConfirmedAt = &at
+	r.ExpiresAt = nil
+	r.UpdatedAt = at
+	regs[key] = r
+	return nil
+}
+
+func renew(regs map[string]Registration, key string, expires, now time.Time) error {
+	r, ok := regs[key]
+	switch {
+	case !ok || r.Expired(now):
+		return ErrNotFound
+	case r.State != StatePending:
+		return ErrNotPending
+	}
+	r.ExpiresAt = &expires
+	r.UpdatedAt = now
+	regs[key] = r
+	return nil
+}
+
+func sortRegistrations(rs []Registration) []Registration {
+	sort.Slice(rs, func(i, j int) bool {
+		if !rs[i].CreatedAt.Equal(rs[j].CreatedAt) {
+			return rs[i].CreatedAt.Before(rs[j].CreatedAt)
+		}
+		return rs[i].ID < rs[j].ID
+	})
+	return rs
+}
@@ -0,0 +1,502 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/docstore"
+	"goalhero-emailer/pkg/redis"
+	"goalhero-emailer/pkg/redistest"
+)
+
+// goSave is saveScript in Go for redistest.
+func goSave(db *redistest.DB, keys, args []string) any {
+	key, old, next := args[0], args[1], args[2]
+	cur, exists := db.HGet(keys[0], key)
+	if old == "" {
+		if next != "" {
+			if exists {
+				return int64(0)
+			}
+			db.HSet(keys[0], key, next)
+		}
+	} else {
+		if cur != old {
+			return int64(0)
+		}
+		if next == "" {
+			db.HDel(keys[0], key)
+		} else {
+			db.HSet(keys[0], key, next)
+		}
+	}
+	if args[3] == "" {
+		db.ZRem(keys[3], key)
+	} else {
+		expires, _ := strconv.ParseFloat(args[3], 64)
+		db.ZAdd(keys[3], expires, key)
+	}
+	n, _ := strconv.Atoi(args[4])
+	for i := 5; i < 5+2*n; i += 2 {
+		if args[i+1] == "" {
+			db.ZRem(keys[1], args[i])
+		} else {
+			due, _ := strconv.ParseFloat(args[i+1], 64)
+			db.ZAdd(keys[1], due, args[i])
+		}
+		db.HSet(keys[2], args[i], key)
+	}
+	for _, id := range args[5+2*n:] {
+		db.ZRem(keys[1], id)
+		db.HDel(keys[2], id)
+	}
+	return int64(1)
+}
+
+func newTestRedis(t *testing.T) (*Redis, *redistest.Server) {
+	srv := redistest.NewServer(t)
+	srv.HandleScript(saveScript, goSave)
+	client, err := redis.New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRedis(client), srv
+}
+
+// stores returns a fresh Memory, Document and Redis for the same tests.
+func stores(t *testing.T) map[string]Store {
+	doc, err := docstore.NewFile(filepath.Join(t.TempDir(), "registrations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb, _ := newTestRedis(t)
+	return map[string]Store{"memory": NewMemory(), "document": NewDocument(doc), "redis": rdb}
+}
+
+func TestCreate(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			r := &Registration{Email: "Jane@Example.com", Language: "es"}
+			if err := s.Create(ctx, r, JobWelcome); err != nil {
+				t.Fatal(err)
+			}
+			if r.ID == "" || r.Key != "jane@example.com" || r.State != StateActive || r.EmailStatus != EmailPending {
+				t.Errorf("Create did not fill in defaults: %+v", r)
+			}
+			if err := s.Create(ctx, &Registration{Email: "jane@example.com"}); !errors.Is(err, ErrExists) {
+				t.Errorf("duplicate Create = %v, want ErrExists", err)
+			}
+
+			got, err := s.Get(ctx, "jane@example.com")
+			if err != nil || got.ID != r.ID || got.Language != "es" {
+				t.Errorf("Get = %+v, %v", got, err)
+			}
+			if _, err := s.Get(ctx, "nobody@example.com"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get of an unknown key = %v, want ErrNotFound", err)
+			}
+			if err := s.SetEmailStatus(ctx, "jane@example.com", EmailSent, "msg-1"); err != nil {
+				t.Fatal(err)
+			}
+			list, err := s.List(ctx)
+			if err != nil || len(list) != 1 || list[0].EmailStatus != EmailSent || list[0].MessageID != "msg-1" {
+				t.Errorf("List = %+v, %v", list, err)
+			}
+		})
+	}
+}
+
+func TestExpiredPendingIsReplaced(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			past := time.Now().Add(-time.Hour)
+			pending := &Registration{Email: "jane@example.com", State: StatePending, ExpiresAt: &past}
+			if err := s.Create(ctx, pending, JobConfirm); err != nil {
+				t.Fatal(err)
+			}
+			// One of its jobs is dead, the other still leased. ClaimJobs
+			// would drop the registration, so claim them for its key.
+			if err := s.Enqueue(ctx, "jane@example.com", JobWelcome); err != nil {
+				t.Fatal(err)
+			}
+			jobs, _ := s.ClaimJobsFor(ctx, "jane@example.com", time.Now(), time.Minute)
+			if len(jobs) != 2 {
+				t.Fatalf("claimed %+v", jobs)
+			}
+			if err := s.BuryJob(ctx, jobs[0].ID, "rejected"); err != nil {
+				t.Fatal(err)
+			}
+
+			again := &Registration{Email: "jane@example.com"}
+			if err := s.Create(ctx, again, JobConfirm); err != nil {
+				t.Fatalf("Create over an expired registration: %v", err)
+			}
+			if got, _ := s.Get(ctx, "jane@example.com"); got == nil || got.ID != again.ID {
+				t.Errorf("Get = %+v, want the new registration", got)
+			}
+			// Only the new signup's job is left.
+			jobs, err := s.Jobs(ctx)
+			if err != nil || len(jobs) != 1 || jobs[0].State != JobQueued || jobs[0].Attempts != 0 {
+				t.Fatalf("Jobs = %+v, %v", jobs, err)
+			}
+			if _, err := s.ClaimJobs(ctx, time.Now(), time.Minute, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CompleteJob(ctx, jobs[0].ID, EmailSent, "msg-1"); err != nil {
+				t.Fatal(err)
+			}
+			if jobs, _ := s.Jobs(ctx); len(jobs) != 0 {
+				t.Errorf("stale jobs left: %+v", jobs)
+			}
+		})
+	}
+}
+
+func TestClaimJobsPurgesExpired(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			soon := time.Now().Add(time.Minute)
+			pending := &Registration{Email: "jane@example.com", State: StatePending, ExpiresAt: &soon}
+			if err := s.Create(ctx, pending, JobConfirm); err != nil {
+				t.Fatal(err)
+			}
+			jobs, _ := s.ClaimJobs(ctx, time.Now(), time.Minute, 0)
+			if len(jobs) != 1 {
+				t.Fatalf("claimed %+v", jobs)
+			}
+			if err := s.BuryJob(ctx, jobs[0].ID, "rejected"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Create(ctx, &Registration{Email: "john@example.com"}, JobWelcome); err != nil {
+				t.Fatal(err)
+			}
+
+			// Past the window, the pending registration and its dead job go,
+			// while the active one and its job stay.
+			jobs, err := s.ClaimJobs(ctx, soon.Add(time.Second), time.Minute, 0)
+			if err != nil || len(jobs) != 1 || jobs[0].Key != "john@example.com" {
+				t.Fatalf("ClaimJobs = %+v, %v", jobs, err)
+			}
+			if rs, _ := s.List(ctx); len(rs) != 1 || rs[0].Key != "john@example.com" {
+				t.Errorf("List = %+v, want only john@example.com", rs)
+			}
+			if jobs, _ := s.Jobs(ctx); len(jobs) != 1 || jobs[0].Key != "john@example.com" {
+				t.Errorf("Jobs = %+v, want only john@example.com's", jobs)
+			}
+		})
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			future := time.Now().Add(time.Hour)
+			r := &Registration{Email: "jane@example.com", State: StatePending, ExpiresAt: &future}
+			if err := s.Create(ctx, r, JobConfirm); err != nil {
+				t.Fatal(err)
+			}
+			// A second enqueue of a queued kind is a no-op.
+			if err := s.Enqueue(ctx, "jane@example.com", JobConfirm); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Enqueue(ctx, "nobody@example.com", JobConfirm); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Enqueue for an unknown key = %v, want ErrNotFound", err)
+			}
+
+			now := time.Now()
+			jobs, err := s.ClaimJobs(ctx, now, time.Minute, 10)
+			if err != nil || len(jobs) != 1 || jobs[0].Kind != JobConfirm || jobs[0].Attempts != 1 || jobs[0].State != JobSending {
+				t.Fatalf("ClaimJobs = %+v, %v", jobs, err)
+			}
+			if again, _ := s.ClaimJobs(ctx, now, time.Minute, 10); len(again) != 0 {
+				t.Errorf("leased job claimed twice: %+v", again)
+			}
+			if err := s.RequeueJob(ctx, jobs[0].ID); !errors.Is(err, ErrJobLeased) {
+				t.Errorf("RequeueJob of a leased job = %v, want ErrJobLeased", err)
+			}
+
+			if err := s.RetryJob(ctx, jobs[0].ID, now.Add(time.Second), "greylisted"); err != nil {
+				t.Fatal(err)
+			}
+			if due, _ := s.ClaimJobs(ctx, now, time.Minute, 10); len(due) != 0 {
+				t.Errorf("job claimed before its retry time: %+v", due)
+			}
+			jobs, _ = s.ClaimJobs(ctx, now.Add(2*time.Second), time.Minute, 10)
+			if len(jobs) != 1 || jobs[0].Attempts != 2 || jobs[0].LastError != "greylisted" {
+				t.Fatalf("ClaimJobs after retry = %+v", jobs)
+			}
+
+			if err := s.BuryJob(ctx, jobs[0].ID, "mailbox unavailable"); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := s.Get(ctx, "jane@example.com"); got.EmailStatus != EmailFailed {
+				t.Errorf("email status after BuryJob = %s, want failed", got.EmailStatus)
+			}
+			if err := s.RequeueJob(ctx, jobs[0].ID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.Confirm(ctx, "jane@example.com", now, JobWelcome); err != nil {
+				t.Fatal(err)
+			}
+			jobs, _ = s.ClaimJobs(ctx, now.Add(2*time.Second), time.Minute, 10)
+			if len(jobs) != 2 {
+				t.Fatalf("ClaimJobs after Confirm = %+v, want confirm and welcome", jobs)
+			}
+			for _, j := range jobs {
+				if err := s.CompleteJob(ctx, j.ID, EmailSent, "msg-"+string(j.Kind)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.CompleteJob(ctx, jobs[0].ID, EmailSent, ""); !errors.Is(err, ErrJobNotFound) {
+				t.Errorf("CompleteJob twice = %v, want ErrJobNotFound", err)
+			}
+			left, _ := s.Jobs(ctx)
+			got, _ := s.Get(ctx, "jane@example.com")
+			if len(left) != 0 || got.State != StateActive || got.EmailStatus != EmailSent {
+				t.Errorf("after delivery: jobs %+v, registration %+v", left, got)
+			}
+		})
+	}
+}
+
+func TestClaimJobsFor(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, email := range []string{"jane@example.com", "bob@example.com"} {
+				if err := s.Create(ctx, &Registration{Email: email}, JobWelcome); err != nil {
+					t.Fatal(err)
+				}
+			}
+			now := time.Now()
+			jobs, err := s.ClaimJobsFor(ctx, "jane@example.com", now, time.Minute)
+			if err != nil || len(jobs) != 1 || jobs[0].Key != "jane@example.com" || jobs[0].State != JobSending {
+				t.Fatalf("ClaimJobsFor = %+v, %v", jobs, err)
+			}
+			if again, _ := s.ClaimJobsFor(ctx, "jane@example.com", now, time.Minute); len(again) != 0 {
+				t.Errorf("leased job claimed twice: %+v", again)
+			}
+			if rest, _ := s.ClaimJobs(ctx, now, time.Minute, 10); len(rest) != 1 || rest[0].Key != "bob@example.com" {
+				t.Errorf("ClaimJobs = %+v, want bob's job only", rest)
+			}
+		})
+	}
+}
+
+func TestRenew(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			soon := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			pending := &Registration{Email: "jane@example.com", State: StatePending, ExpiresAt: &soon}
+			if err := s.Create(ctx, pending, JobConfirm); err != nil {
+				t.Fatal(err)
+			}
+			jobs, _ := s.ClaimJobs(ctx, time.Now(), time.Minute, 10)
+			if len(jobs) != 1 {
+				t.Fatalf("claimed %d jobs", len(jobs))
+			}
+			s.CompleteJob(ctx, jobs[0].ID, "", "")
+
+			later := soon.Add(47 * time.Hour)
+			if err := s.Renew(ctx, "jane@example.com", later, JobConfirm); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := s.Get(ctx, "jane@example.com")
+			if got == nil || got.ExpiresAt == nil || !got.ExpiresAt.Equal(later) {
+				t.Errorf("Get = %+v, want expiry %v", got, later)
+			}
+			if jobs, _ := s.Jobs(ctx); len(jobs) != 1 || jobs[0].Kind != JobConfirm {
+				t.Errorf("Jobs = %+v, want a new confirm job", jobs)
+			}
+
+			// A confirmation backing off after a failed send is due again.
+			jobs, _ = s.ClaimJobs(ctx, time.Now(), time.Minute, 10)
+			if len(jobs) != 1 {
+				t.Fatalf("claimed %d jobs", len(jobs))
+			}
+			if err := s.RetryJob(ctx, jobs[0].ID, time.Now().Add(time.Hour), "timeout"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Renew(ctx, "jane@example.com", later, JobConfirm); err != nil {
+				t.Fatal(err)
+			}
+			if jobs, _ := s.ClaimJobs(ctx, time.Now(), time.Minute, 10); len(jobs) != 1 || jobs[0].Attempts != 2 {
+				t.Errorf("after Renew, claimed %+v, want the retried confirm job", jobs)
+			}
+
+			if err := s.Renew(ctx, "nobody@example.com", later); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Renew of an unknown key = %v, want ErrNotFound", err)
+			}
+			if err := s.Confirm(ctx, "jane@example.com", time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Renew(ctx, "jane@example.com", later); !errors.Is(err, ErrNotPending) {
+				t.Errorf("Renew of a confirmed registration = %v, want ErrNotPending", err)
+			}
+		})
+	}
+}
+
+// TestDocumentInstances signs up concurrently through several Documents on
+// one file, as separate instances would, and checks every signup and its
+// job were kept.
+func TestDocumentInstances(t *testing.T) {
+	const instances, perInstance = 4, 10
+	path := filepath.Join(t.TempDir(), "registrations.json")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := range instances {
+		doc, err := docstore.NewFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewDocument(doc)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range perInstance {
+				r := &Registration{Email: fmt.Sprintf("user%d-%d@example.com", i, j)}
+				if err := s.Create(ctx, r, JobWelcome); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	doc, err := docstore.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDocument(doc)
+	list, _ := s.List(ctx)
+	jobs, _ := s.Jobs(ctx)
+	if len(list) != instances*perInstance || len(jobs) != instances*perInstance {
+		t.Errorf("%d registrations and %d jobs, want %d each", len(list), len(jobs), instances*perInstance)
+	}
+}
+
+// TestRedisInstances signs up concurrently through several Redis stores,
+// as separate instances would, and checks every signup and its job were
+// kept without a retry, since no two of them share an address.
+func TestRedisInstances(t *testing.T) {
+	const instances, perInstance = 4, 10
+	srv := redistest.NewServer(t)
+	srv.HandleScript(saveScript, goSave)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := range instances {
+		client, err := redis.New(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewRedis(client)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range perInstance {
+				r := &Registration{Email: fmt.Sprintf("user%d-%d@example.com", i, j)}
+				if err := s.Create(ctx, r, JobWelcome); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	client, _ := redis.New(srv.URL)
+	s := NewRedis(client)
+	list, _ := s.List(ctx)
+	jobs, _ := s.Jobs(ctx)
+	if len(list) != instances*perInstance || len(jobs) != instances*perInstance {
+		t.Errorf("%d registrations and %d jobs, want %d each", len(list), len(jobs), instances*perInstance)
+	}
+	saves := 0
+	for _, c := range srv.Calls() {
+		if c == "EVALSHA" {
+			saves++
+		}
+	}
+	if saves != instances*perInstance {
+		t.Errorf("%d save attempts for %d signups", saves, instances*perInstance)
+	}
+}
+
+// TestRedisDueIndex checks the sorted set ClaimJobs reads follows the jobs:
+// a dead job leaves it and a completed one is forgotten.
+func TestRedisDueIndex(t *testing.T) {
+	s, srv := newTestRedis(t)
+	ctx := context.Background()
+	if err := s.Create(ctx, &Registration{Email: "jane@example.com"}, JobWelcome); err != nil {
+		t.Fatal(err)
+	}
+	due := func() []string {
+		var ids []string
+		srv.Do(func(db *redistest.DB) { ids = db.ZRangeByScore(s.due, float64(time.Now().Add(time.Hour).UnixMilli())) })
+		return ids
+	}
+	jobs, _ := s.ClaimJobs(ctx, time.Now(), time.Minute, 10)
+	if len(jobs) != 1 || len(due()) != 1 {
+		t.Fatalf("claimed %+v; due %v", jobs, due())
+	}
+	if err := s.BuryJob(ctx, jobs[0].ID, "mailbox unavailable"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := due(); len(ids) != 0 {
+		t.Errorf("dead job still due: %v", ids)
+	}
+	if err := s.RequeueJob(ctx, jobs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	jobs, _ = s.ClaimJobs(ctx, time.Now(), time.Minute, 10)
+	if len(jobs) != 1 {
+		t.Fatalf("requeued job not claimed: %+v", jobs)
+	}
+	if err := s.CompleteJob(ctx, jobs[0].ID, EmailSent, "msg-1"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := due(); len(ids) != 0 {
+		t.Errorf("completed job still due: %v", ids)
+	}
+	if err := s.RetryJob(ctx, jobs[0].ID, time.Now(), ""); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("RetryJob of a completed job = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestDocumentReadsLegacyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registrations.json")
+	legacy := `{"jane@example.com": {"id": "abc", "email": "jane@example.com", "email_status": "sent"}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := docstore.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDocument(doc)
+	ctx := context.Background()
+	if r, err := s.Get(ctx, "jane@example.com"); err != nil || r.ID != "abc" {
+		t.Fatalf("Get = %+v, %v", r, err)
+	}
+	// The next write upgrades the file to the sectioned format.
+	if err := s.Create(ctx, &Registration{Email: "bob@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(ctx); len(list) != 2 {
+		t.Errorf("List = %+v, want both registrations", list)
+	}
+}
@@ -0,0 +1,140 @@
+// Package reqbody decodes request bodies with a size cap, Content-Type
+// checks and, in strict mode, rejection of unknown fields.
+package reqbody
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultMaxBytes caps bodies when Options.MaxBytes is zero.
+const DefaultMaxBytes = 16 << 10
+
+var (
+	// ErrUnsupportedMediaType is returned for a missing or unaccepted
+	// Content-Type.
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrMalformed is returned for bodies that don't parse, including
+	// JSON followed by anything but whitespace.
+	ErrMalformed = errors.New("malformed request body")
+)
+
+// TooLargeError is returned for bodies over the limit.
+type TooLargeError struct {
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("request body exceeds %d bytes", e.Limit)
+}
+
+// UnknownFieldError is returned in strict mode for a field the target
+// struct doesn't have.
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+type Options struct {
+	// MaxBytes caps the body; DefaultMaxBytes when zero.
+	MaxBytes int64
+	// Strict rejects JSON fields the target doesn't declare. Form bodies
+	// are exempt: a form posts every named control, such as its submit
+	// button, which the page may not control.
+	Strict bool
+	// AllowForm accepts application/x-www-form-urlencoded bodies, as
+	// posted by HTML forms without JavaScript, besides JSON.
+	AllowForm bool
+	// FormAliases maps form field names to JSON field names, for names a
+	// form can't choose, such as those CAPTCHA widgets inject.
+	FormAliases map[string]string
+}
+
+// Decode reads r's body into dst, a pointer to a struct. Form bodies are
+// mapped onto dst's JSON field names, so only string fields can be set
+// from a form.
+func Decode(w http.ResponseWriter, r *http.Request, dst any, opts Options) error {
+	limit := opts.MaxBytes
+	if limit <= 0 {
+		limit = DefaultMaxBytes
+	}
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isJSON := err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+	isForm := err == nil && opts.AllowForm && mediaType == "application/x-www-form-urlencoded"
+	if charset := params["charset"]; charset != "" && !strings.EqualFold(charset, "utf-8") {
+		isJSON, isForm = false, false
+	}
+	if !isJSON && !isForm {
+		accept := "application/json"
+		if opts.AllowForm {
+			accept += ", application/x-www-form-urlencoded"
+		}
+		w.Header().Set("Accept-Post", accept)
+		return ErrUnsupportedMediaType
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &TooLargeError{Limit: limit}
+		}
+		return err
+	}
+
+	if isForm {
+		if body, err = formToJSON(body, opts.FormAliases); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if opts.Strict && !isForm {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(dst); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return &UnknownFieldError{Field: strings.Trim(field, `"`)}
+		}
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("%w: trailing data after JSON value", ErrMalformed)
+	}
+	return nil
+}
+
+// formToJSON re-encodes a form body as a JSON object of strings, taking
+// the first value of repeated fields, so that it decodes with the same
+// rules as JSON. A field sent under its own name wins over an alias.
+func formToJSON(body []byte, aliases map[string]string) ([]byte, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	fields := make(map[string]string, len(values))
+	for name, v := range values {
+		if _, ok := aliases[name]; !ok {
+			fields[name] = v[0]
+		}
+	}
+	for name, alias := range aliases {
+		if v, ok := values[name]; ok {
+			if _, set := fields[alias]; !set {
+				fields[alias] = v[0]
+			}
+		}
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,118 @@
+package reqbody
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Email        string `json:"email"`
+	Language     string `json:"language"`
+	CaptchaToken string `json:"captcha_token"`
+}
+
+func TestDecode(t *testing.T) {
+	aliases := map[string]string{"cf-turnstile-response": "captcha_token"}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        Options
+		want        signup
+		err         error
+		// tooLarge is the limit of the TooLargeError expected, unknown the
+		// field of the UnknownFieldError.
+		tooLarge  int64
+		unknown   string
+		acceptHdr string
+	}{
+		{name: "json", contentType: "application/json", body: `{"email": "jane@example.com", "language": "es"}`,
+			want: signup{Email: "jane@example.com", Language: "es"}},
+		{name: "json charset", contentType: "application/json; charset=UTF-8", body: `{"email": "jane@example.com"}`,
+			want: signup{Email: "jane@example.com"}},
+		{name: "json suffix", contentType: "application/merge-patch+json", body: `{"email": "jane@example.com"}`,
+			want: signup{Email: "jane@example.com"}},
+		{name: "trailing whitespace", contentType: "application/json", body: "{\"email\": \"jane@example.com\"}\n\t ",
+			want: signup{Email: "jane@example.com"}},
+		{name: "unknown field ignored", contentType: "application/json", body: `{"email": "jane@example.com", "extra": 1}`,
+			want: signup{Email: "jane@example.com"}},
+
+		{name: "missing content type", body: `{}`, err: ErrUnsupportedMediaType, acceptHdr: "application/json"},
+		{name: "text", contentType: "text/plain", body: `{}`, err: ErrUnsupportedMediaType, acceptHdr: "application/json"},
+		{name: "other charset", contentType: "application/json; charset=latin1", body: `{}`, err: ErrUnsupportedMediaType},
+		{name: "form not allowed", contentType: "application/x-www-form-urlencoded", body: "email=jane%40example.com",
+			err: ErrUnsupportedMediaType, acceptHdr: "application/json"},
+		{name: "form other charset", contentType: "application/x-www-form-urlencoded; charset=iso-8859-1", body: "email=x",
+			opts: Options{AllowForm: true}, err: ErrUnsupportedMediaType, acceptHdr: "application/json, application/x-www-form-urlencoded"},
+
+		{name: "too large", contentType: "application/json", body: `{"email": "` + strings.Repeat("a", 2048) + `"}`,
+			opts: Options{MaxBytes: 1024}, tooLarge: 1024},
+		{name: "default limit", contentType: "application/json", body: `{"email": "` + strings.Repeat("a", DefaultMaxBytes) + `"}`,
+			tooLarge: DefaultMaxBytes},
+
+		{name: "malformed", contentType: "application/json", body: `{"email": `, err: ErrMalformed},
+		{name: "wrong type", contentType: "application/json", body: `{"email": 42}`, err: ErrMalformed},
+		{name: "trailing value", contentType: "application/json", body: `{"email": "a@example.com"} {"email": "b@example.com"}`, err: ErrMalformed},
+		{name: "trailing garbage", contentType: "application/json", body: `{"email": "a@example.com"}x`, err: ErrMalformed},
+
+		{name: "strict", contentType: "application/json", body: `{"email": "jane@example.com"}`, opts: Options{Strict: true},
+			want: signup{Email: "jane@example.com"}},
+		{name: "strict unknown field", contentType: "application/json", body: `{"email": "jane@example.com", "extra": 1}`,
+			opts: Options{Strict: true}, unknown: "extra"},
+
+		{name: "form", contentType: "application/x-www-form-urlencoded", body: "email=jane%40example.com&language=es",
+			opts: Options{AllowForm: true}, want: signup{Email: "jane@example.com", Language: "es"}},
+		{name: "form repeated field", contentType: "application/x-www-form-urlencoded", body: "email=a%40example.com&email=b%40example.com",
+			opts: Options{AllowForm: true}, want: signup{Email: "a@example.com"}},
+		{name: "form alias", contentType: "application/x-www-form-urlencoded", body: "email=a%40example.com&cf-turnstile-response=tok",
+			opts: Options{AllowForm: true, FormAliases: aliases}, want: signup{Email: "a@example.com", CaptchaToken: "tok"}},
+		{name: "form own name wins over alias", contentType: "application/x-www-form-urlencoded", body: "captcha_token=own&cf-turnstile-response=tok",
+			opts: Options{AllowForm: true, FormAliases: aliases}, want: signup{CaptchaToken: "own"}},
+		{name: "strict form ignores extra fields", contentType: "application/x-www-form-urlencoded", body: "email=a%40example.com&submit=Sign+up",
+			opts: Options{AllowForm: true, Strict: true}, want: signup{Email: "a@example.com"}},
+		{name: "malformed form", contentType: "application/x-www-form-urlencoded", body: "email=%zz",
+			opts: Options{AllowForm: true}, err: ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			var got signup
+			err := Decode(w, r, &got, tt.opts)
+
+			var tooLarge *TooLargeError
+			var unknown *UnknownFieldError
+			switch {
+			case tt.tooLarge != 0:
+				if !errors.As(err, &tooLarge) || tooLarge.Limit != tt.tooLarge {
+					t.Fatalf("Decode = %v, want a TooLargeError with limit %d", err, tt.tooLarge)
+				}
+			case tt.unknown != "":
+				if !errors.As(err, &unknown) || unknown.Field != tt.unknown {
+					t.Fatalf("Decode = %v, want unknown field %q", err, tt.unknown)
+				}
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Decode = %v, want %v", err, tt.err)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("decoded %+v, want %+v", got, tt.want)
+				}
+			}
+			if tt.acceptHdr != "" {
+				if h := w.Header().Get("Accept-Post"); h != tt.acceptHdr {
+					t.Errorf("Accept-Post = %q, want %q", h, tt.acceptHdr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package signing
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	s := New("0123456789abcdef0123456789abcdef")
+	payload := []byte("jane@example.com")
+	token := s.Sign("unsubscribe", payload)
+
+	got, err := s.Verify("unsubscribe", token)
+	if err != nil || string(got) != string(payload) {
+		t.Fatalf("Verify = %q, %v; want %q", got, err, payload)
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Errorf("token %q is not URL-safe", token)
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	s := New("0123456789abcdef0123456789abcdef")
+	token := s.Sign("unsubscribe", []byte("jane@example.com"))
+	p, m, _ := strings.Cut(token, ".")
+	enc := base64.RawURLEncoding
+
+	// flip changes the first character of an encoded part.
+	flip := func(part string) string {
+		if part[0] == 'A' {
+			return "B" + part[1:]
+		}
+		return "A" + part[1:]
+	}
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+		signer  *Signer
+	}{
+		{name: "other purpose", purpose: "beta-confirm", token: token},
+		{name: "other secret", purpose: "unsubscribe", token: token, signer: New("another secret")},
+		{name: "tampered payload", purpose: "unsubscribe", token: enc.EncodeToString([]byte("john@example.com")) + "." + m},
+		{name: "tampered mac", purpose: "unsubscribe", token: p + "." + flip(m)},
+		{name: "truncated mac", purpose: "unsubscribe", token: p + "." + m[:len(m)-2]},
+		{name: "no mac", purpose: "unsubscribe", token: p},
+		{name: "empty", purpose: "unsubscribe", token: ""},
+		{name: "bad encoding", purpose: "unsubscribe", token: p + ".!!" + m[2:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := s
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+			if got, err := signer.Verify(tt.purpose, tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify = %q, %v; want ErrInvalidToken", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package suppression
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"goalhero-emailer/pkg/docstore"
+)
+
+// Document is a Store kept in a single JSON document shared by every
+// instance, see docstore.
+type Document struct {
+	doc docstore.Store
+}
+
+func NewDocument(doc docstore.Store) *Document {
+	return &Document{doc: doc}
+}
+
+// errUnchanged tells update that fn changed nothing worth saving.
+var errUnchanged = errors.New("unchanged")
+
+func (d *Document) view(ctx context.Context) (map[string]Entry, error) {
+	raw, err := d.doc.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return load(raw)
+}
+
+// update applies fn to the current entries and saves them; nothing is
+// saved if fn fails.
+func (d *Document) update(ctx context.Context, fn func(entries map[string]Entry) error) error {
+	err := d.doc.Update(ctx, func(raw []byte) ([]byte, error) {
+		entries, err := load(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(entries); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(entries, "", "  ")
+	})
+	if errors.Is(err, errUnchanged) {
+		return nil
+	}
+	return err
+}
+
+func load(raw []byte) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	if raw == nil {
+		return entries, nil
+	}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("parse suppression list: %v", err)
+	}
+	return entries, nil
+}
+
+func (d *Document) Add(ctx context.Context, e Entry) error {
+	e.Email = Key(e.Email)
+	return d.update(ctx, func(entries map[string]Entry) error {
+		entries[e.Email] = e
+		return nil
+	})
+}
+
+func (d *Document) Remove(ctx context.Context, email string) error {
+	key := Key(email)
+	return d.update(ctx, func(entries map[string]Entry) error {
+		if _, ok := entries[key]; !ok {
+			return errUnchanged
+		}
+		delete(entries, key)
+		return nil
+	})
+}
+
+func (d *Document) Get(ctx context.Context, email string) (*Entry, error) {
+	entries, err := d.view(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := entries[Key(email)]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (d *Document) List(ctx context.Context) ([]Entry, error) {
+	entries, err := d.view(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	return sortEntries(list), nil
+}
@@ -0,0 +1,95 @@
+package suppression
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"goalhero-emailer/pkg/docstore"
+)
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.Add(ctx, Entry{Email: " Jane@Example.com", Reason: ReasonUnsubscribed}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(ctx, Entry{Email: "bob@example.com", Reason: ReasonBounced}); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := s.Get(ctx, "jane@example.com")
+	if err != nil || e == nil || e.Email != "jane@example.com" || e.Reason != ReasonUnsubscribed {
+		t.Errorf("Get = %+v, %v", e, err)
+	}
+	if e, err := s.Get(ctx, "nobody@example.com"); e != nil || err != nil {
+		t.Errorf("Get of an unknown address = %+v, %v", e, err)
+	}
+
+	if err := s.Remove(ctx, "JANE@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(ctx, "jane@example.com"); err != nil {
+		t.Errorf("removing an absent address: %v", err)
+	}
+	list, err := s.List(ctx)
+	if err != nil || len(list) != 1 || list[0].Email != "bob@example.com" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.json")
+	doc, err := docstore.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, NewDocument(doc))
+
+	// Another instance on the same file sees the changes.
+	other, err := docstore.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := NewDocument(other).Get(context.Background(), "bob@example.com"); e == nil || err != nil {
+		t.Errorf("second instance: Get = %+v, %v", e, err)
+	}
+}
+
+// failingDoc is a document whose updates fail, like a full disk.
+type failingDoc struct {
+	docstore.Store
+}
+
+func (failingDoc) Update(context.Context, func([]byte) ([]byte, error)) error {
+	return errors.New("disk full")
+}
+
+func TestDocumentFailedSave(t *testing.T) {
+	ctx := context.Background()
+	doc, err := docstore.NewFile(filepath.Join(t.TempDir(), "suppressions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDocument(doc).Add(ctx, Entry{Email: "kept@example.com", Reason: ReasonManual}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewDocument(failingDoc{doc})
+	if err := s.Add(ctx, Entry{Email: "new@example.com", Reason: ReasonBounced}); err == nil {
+		t.Fatal("Add succeeded with a failing document")
+	}
+	if e, _ := s.Get(ctx, "new@example.com"); e != nil {
+		t.Errorf("failed Add left %+v behind", e)
+	}
+	if err := s.Remove(ctx, "kept@example.com"); err == nil {
+		t.Fatal("Remove succeeded with a failing document")
+	}
+	if e, _ := s.Get(ctx, "kept@example.com"); e == nil {
+		t.Error("failed Remove deleted the entry")
+	}
+}
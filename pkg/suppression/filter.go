@@ -0,0 +1,30 @@
+package suppression
+
+import (
+	"context"
+	"fmt"
+
+	"goalhero-emailer/pkg/mailer"
+)
+
+type filter struct {
+	store Store
+	next  mailer.Mailer
+}
+
+// Filter wraps next so that every send first consults store. Suppressed
+// recipients are not mailed and the send fails with ErrSuppressed.
+func Filter(store Store, next mailer.Mailer) mailer.Mailer {
+	return &filter{store: store, next: next}
+}
+
+func (f *filter) Send(ctx context.Context, msg *mailer.Message) (string, error) {
+	e, err := f.store.Get(ctx, msg.To.Email)
+	if err != nil {
+		return "", fmt.Errorf("check suppression list: %v", err)
+	}
+	if e != nil {
+		return "", fmt.Errorf("%w (%s since %s)", ErrSuppressed, e.Reason, e.CreatedAt.Format("2006-01-02"))
+	}
+	return f.next.Send(ctx, msg)
+}
@@ -0,0 +1,65 @@
+package suppression
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"goalhero-emailer/pkg/mailer"
+)
+
+type countingMailer struct {
+	sends int
+}
+
+func (m *countingMailer) Send(ctx context.Context, msg *mailer.Message) (string, error) {
+	m.sends++
+	return "id", nil
+}
+
+// brokenStore is a Store whose lookups fail.
+type brokenStore struct {
+	Store
+}
+
+func (brokenStore) Get(context.Context, string) (*Entry, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestFilter(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+	if err := store.Add(ctx, Entry{Email: "gone@example.com", Reason: ReasonBounced}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		store     Store
+		to        string
+		wantSends int
+		wantErr   error
+	}{
+		{name: "allowed", store: store, to: "jane@example.com", wantSends: 1},
+		{name: "suppressed", store: store, to: "gone@example.com", wantErr: ErrSuppressed},
+		{name: "suppressed other case", store: store, to: "Gone@Example.com", wantErr: ErrSuppressed},
+		{name: "store down", store: brokenStore{store}, to: "jane@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &countingMailer{}
+			_, err := Filter(tt.store, next).Send(ctx, &mailer.Message{To: mailer.Address{Email: tt.to}})
+			if next.sends != tt.wantSends {
+				t.Errorf("%d sends, want %d", next.sends, tt.wantSends)
+			}
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("Send = %v, want %v", err, tt.wantErr)
+			case tt.wantSends == 1 && err != nil:
+				t.Errorf("Send = %v", err)
+			case tt.wantSends == 0 && err == nil:
+				t.Errorf("Send succeeded without mailing")
+			}
+		})
+	}
+}
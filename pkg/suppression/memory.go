@@ -0,0 +1,51 @@
+package suppression
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Store for tests and local development.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]Entry)}
+}
+
+func (m *Memory) Add(ctx context.Context, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.Email = Key(e.Email)
+	m.entries[e.Email] = e
+	return nil
+}
+
+func (m *Memory) Remove(ctx context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, Key(email))
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, email string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[Key(email)]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (m *Memory) List(ctx context.Context) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	return sortEntries(entries), nil
+}
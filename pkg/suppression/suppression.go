@@ -0,0 +1,64 @@
+// Package suppression records addresses that must not be mailed again.
+package suppression
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Reason string
+
+const (
+	ReasonUnsubscribed Reason = "unsubscribed"
+	ReasonBounced      Reason = "bounced"
+	ReasonComplained   Reason = "complained"
+	ReasonManual       Reason = "manual"
+)
+
+// Valid reports whether r is one of the known reasons.
+func (r Reason) Valid() bool {
+	switch r {
+	case ReasonUnsubscribed, ReasonBounced, ReasonComplained, ReasonManual:
+		return true
+	}
+	return false
+}
+
+type Entry struct {
+	Email     string    `json:"email"`
+	Reason    Reason    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ErrSuppressed is returned by a Filter-wrapped Mailer for addresses on
+// the list.
+var ErrSuppressed = errors.New("recipient is suppressed")
+
+// Store persists suppression entries keyed by normalized address.
+type Store interface {
+	Add(ctx context.Context, e Entry) error
+	// Remove deletes an entry; removing an absent address is not an error.
+	Remove(ctx context.Context, email string) error
+	// Get returns the entry for email, or nil if it isn't suppressed.
+	Get(ctx context.Context, email string) (*Entry, error)
+	// List returns all entries, oldest first.
+	List(ctx context.Context) ([]Entry, error)
+}
+
+// Key normalizes an address for lookups.
+func Key(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func sortEntries(entries []Entry) []Entry {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+		}
+		return entries[i].Email < entries[j].Email
+	})
+	return entries
+}
@@ -0,0 +1,491 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	styleBlockPattern = regexp.MustCompile(`(?is)<style[^>]*>(.*?)</style>`)
+	cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	compoundPattern   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9]*|\*)?((?:[.#][-_a-zA-Z0-9]+)*)$`)
+	simpleSelector    = regexp.MustCompile(`[.#][-_a-zA-Z0-9]+`)
+	openTagPattern    = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*?)(/?)>`)
+	classAttrPattern  = regexp.MustCompile(`(?is)\sclass\s*=\s*"([^"]*)"`)
+	idAttrPattern     = regexp.MustCompile(`(?is)\sid\s*=\s*"([^"]*)"`)
+	styleAttrPattern  = regexp.MustCompile(`(?is)\s+style\s*=\s*"([^"]*)"`)
+)
+
+// voidTags never have a closing tag, so they are not pushed on the stack.
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true,
+}
+
+// unsupportedCSS lists properties that Gmail and/or Outlook drop, with
+// an optional value substring that makes them unsupported.
+var unsupportedCSS = []struct {
+	property, value string
+}{
+	{"display", "flex"},
+	{"display", "grid"},
+	{"gap", ""},
+	{"flex-direction", ""},
+	{"flex-wrap", ""},
+	{"justify-content", ""},
+	{"align-items", ""},
+	{"position", ""},
+	{"transition", ""},
+	{"transform", ""},
+	{"animation", ""},
+	{"box-shadow", ""},
+	{"object-fit", ""},
+	{"background", "gradient"},
+}
+
+type compound struct {
+	tag     string
+	classes []string
+	id      string
+}
+
+type cssRule struct {
+	selector    []compound // descendant chain, outermost first
+	specificity [3]int
+	order       int
+	decls       []declaration
+}
+
+type declaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+type element struct {
+	tag     string
+	classes []string
+	id      string
+}
+
+// InlineCSS moves the rules of every <style> block onto the style
+// attributes of the elements they match, so clients that strip <style>
+// (Gmail, Outlook) still render the design. Rules that can't be inlined –
+// @media queries, :hover and other pseudo selectors, combinators other
+// than descendant – stay in a single <style> block in the head, with
+// @media declarations marked !important so they still override the
+// inlined ones. Existing inline styles win over stylesheet rules. The
+// returned warnings name properties that major clients ignore even when
+// inlined.
+func InlineCSS(src string) (string, []string) {
+	var (
+		rules    []cssRule
+		leftover []string
+		warnings []string
+		seen     = map[string]bool{}
+	)
+
+	blocks := styleBlockPattern.FindAllStringSubmatch(src, -1)
+	for _, b := range blocks {
+		r, l := parseStylesheet(b[1], len(rules))
+		rules = append(rules, r...)
+		leftover = append(leftover, l...)
+	}
+	if len(rules) == 0 {
+		return src, nil
+	}
+
+	// Replace the first <style> with what is left and drop the others.
+	first := true
+	src = styleBlockPattern.ReplaceAllStringFunc(src, func(string) string {
+		if !first || len(leftover) == 0 {
+			return ""
+		}
+		first = false
+		return "<style>\n" + strings.Join(leftover, "\n") + "\n    </style>"
+	})
+
+	var (
+		out   strings.Builder
+		stack []element
+		pos   int
+		// Nothing in the head or a <style> is styled. They are tracked
+		// apart so a </style> inside the head doesn't end it.
+		headDepth, styleDepth int
+	)
+	for _, m := range openTagPattern.FindAllStringSubmatchIndex(src, -1) {
+		out.WriteString(src[pos:m[0]])
+		pos = m[1]
+		tag := src[m[0]:m[1]]
+		closing := m[3] > m[2]
+		name := strings.ToLower(src[m[4]:m[5]])
+		attrs := src[m[6]:m[7]]
+		selfClosing := m[9] > m[8]
+
+		switch name {
+		case "head":
+			headDepth = nest(headDepth, closing)
+		case "style":
+			styleDepth = nest(styleDepth, closing)
+		}
+		skip := headDepth > 0 || styleDepth > 0
+		if closing {
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].tag == name {
+					stack = stack[:i]
+					break
+				}
+			}
+			out.WriteString(tag)
+			continue
+		}
+
+		el := element{tag: name}
+		if c := classAttrPattern.FindStringSubmatch(attrs); c != nil {
+			el.classes = strings.Fields(c[1])
+		}
+		if id := idAttrPattern.FindStringSubmatch(attrs); id != nil {
+			el.id = id[1]
+		}
+
+		if !skip && name != "html" {
+			var matched []cssRule
+			for _, r := range rules {
+				if r.matches(el, stack) {
+					matched = append(matched, r)
+				}
+			}
+			if len(matched) > 0 {
+				style := cascade(matched, existingStyle(attrs))
+				for _, d := range style {
+					if w := checkSupport(d); w != "" && !seen[w] {
+						seen[w] = true
+						warnings = append(warnings, w)
+					}
+				}
+				tag = rewriteStyle(src[m[0]:m[1]], attrs, m[6]-m[0], style)
+			}
+		}
+		out.WriteString(tag)
+
+		if !voidTags[name] && !selfClosing {
+			stack = append(stack, el)
+		}
+	}
+	out.WriteString(src[pos:])
+
+	sort.Strings(warnings)
+	return out.String(), warnings
+}
+
+// nest returns the depth of an element after one of its tags.
+func nest(depth int, closing bool) int {
+	if closing {
+		return max(depth-1, 0)
+	}
+	return depth + 1
+}
+
+// parseStylesheet splits css into inlinable rules and verbatim leftovers.
+func parseStylesheet(css string, order int) ([]cssRule, []string) {
+	css = cssCommentPattern.ReplaceAllString(css, "")
+
+	var rules []cssRule
+	var leftover []string
+	for {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(css[:open])
+
+		// Find the matching brace so nested @media blocks stay intact.
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		body := css[open+1 : end]
+		css = css[end+1:]
+
+		if strings.HasPrefix(prelude, "@") {
+			if strings.HasPrefix(prelude, "@media") {
+				// Inlined styles would otherwise beat the responsive
+				// overrides, so they have to be forced.
+				body = importantMedia(body)
+			}
+			leftover = append(leftover, "        "+prelude+" {"+body+"}")
+			continue
+		}
+
+		decls := parseDeclarations(body)
+		var kept []string
+		for _, sel := range strings.Split(prelude, ",") {
+			sel = strings.TrimSpace(sel)
+			chain, spec, ok := parseSelector(sel)
+			if !ok {
+				kept = append(kept, sel)
+				continue
+			}
+			rules = append(rules, cssRule{selector: chain, specificity: spec, order: order, decls: decls})
+			order++
+		}
+		if len(kept) > 0 {
+			leftover = append(leftover, "        "+strings.Join(kept, ", ")+" {"+body+"}")
+		}
+	}
+	return rules, leftover
+}
+
+func parseSelector(sel string) ([]compound, [3]int, bool) {
+	var chain []compound
+	var spec [3]int
+	for _, part := range strings.Fields(sel) {
+		m := compoundPattern.FindStringSubmatch(part)
+		if m == nil {
+			return nil, spec, false
+		}
+		c := compound{tag: strings.ToLower(m[1])}
+		if c.tag == "*" {
+			c.tag = ""
+		} else if c.tag != "" {
+			spec[2]++
+		}
+		for _, s := range simpleSelector.FindAllString(m[2], -1) {
+			if s[0] == '#' {
+				c.id = s[1:]
+				spec[0]++
+			} else {
+				c.classes = append(c.classes, s[1:])
+				spec[1]++
+			}
+		}
+		chain = append(chain, c)
+	}
+	return chain, spec, len(chain) > 0
+}
+
+func parseDeclarations(body string) []declaration {
+	var decls []declaration
+	for _, d := range splitDeclarations(body) {
+		prop, value, ok := strings.Cut(d, ":")
+		if !ok {
+			continue
+		}
+		prop = strings.ToLower(strings.TrimSpace(prop))
+		value = strings.TrimSpace(value)
+		important := false
+		if v, found := strings.CutSuffix(value, "!important"); found {
+			value, important = strings.TrimSpace(v), true
+		}
+		if prop == "" || value == "" {
+			continue
+		}
+		decls = append(decls, declaration{property: prop, value: value, important: important})
+	}
+	return decls
+}
+
+// splitDeclarations splits a declaration block on the semicolons between
+// declarations, skipping those inside parentheses or quotes such as in
+// url(data:image/png;base64,...).
+func splitDeclarations(body string) []string {
+	var (
+		parts []string
+		depth int
+		quote byte
+		start int
+	)
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ';' && depth == 0:
+			parts = append(parts, body[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, body[start:])
+}
+
+// importantMedia marks every declaration in the rules of an @media body
+// !important, including the last one of a rule when it has no trailing
+// semicolon.
+func importantMedia(body string) string {
+	var out strings.Builder
+	for {
+		open := strings.Index(body, "{")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(body[open:], "}")
+		if end < 0 {
+			break
+		}
+		end += open
+		out.WriteString(body[:open+1])
+		for i, d := range splitDeclarations(body[open+1 : end]) {
+			if i > 0 {
+				out.WriteString(";")
+			}
+			decl := strings.TrimRight(d, " \t\r\n")
+			if strings.Contains(decl, ":") && !strings.Contains(decl, "!important") {
+				decl += " !important"
+			}
+			out.WriteString(decl + d[len(strings.TrimRight(d, " \t\r\n")):])
+		}
+		out.WriteString("}")
+		body = body[end+1:]
+	}
+	out.WriteString(body)
+	return out.String()
+}
+
+func (c compound) matches(el element) bool {
+	if c.tag != "" && c.tag != el.tag {
+		return false
+	}
+	if c.id != "" && c.id != el.id {
+		return false
+	}
+	for _, want := range c.classes {
+		found := false
+		for _, have := range el.classes {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r cssRule) matches(el element, ancestors []element) bool {
+	last := len(r.selector) - 1
+	if !r.selector[last].matches(el) {
+		return false
+	}
+	i := len(ancestors) - 1
+	for s := last - 1; s >= 0; s-- {
+		for i >= 0 && !r.selector[s].matches(ancestors[i]) {
+			i--
+		}
+		if i < 0 {
+			return false
+		}
+		i--
+	}
+	return true
+}
+
+// cascade orders matched declarations by importance, specificity and
+// source order, with the element's own style attribute applied last.
+func cascade(rules []cssRule, inline []declaration) []declaration {
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.specificity != b.specificity {
+			for k := range a.specificity {
+				if a.specificity[k] != b.specificity[k] {
+					return a.specificity[k] < b.specificity[k]
+				}
+			}
+		}
+		return a.order < b.order
+	})
+
+	var result []declaration
+	index := map[string]int{}
+	apply := func(d declaration) {
+		if i, ok := index[d.property]; ok {
+			if result[i].important && !d.important {
+				return
+			}
+			// Re-append rather than overwrite in place so a later
+			// shorthand still beats an earlier longhand (margin vs
+			// margin-bottom) in the final attribute.
+			result = append(result[:i], result[i+1:]...)
+			for p, j := range index {
+				if j > i {
+					index[p] = j - 1
+				}
+			}
+		}
+		index[d.property] = len(result)
+		result = append(result, d)
+	}
+	for _, r := range rules {
+		for _, d := range r.decls {
+			apply(d)
+		}
+	}
+	for _, d := range inline {
+		apply(d)
+	}
+	return result
+}
+
+func existingStyle(attrs string) []declaration {
+	m := styleAttrPattern.FindStringSubmatch(attrs)
+	if m == nil {
+		return nil
+	}
+	return parseDeclarations(m[1])
+}
+
+func rewriteStyle(tag, attrs string, attrsOffset int, decls []declaration) string {
+	parts := make([]string, len(decls))
+	for i, d := range decls {
+		v := d.value
+		if d.important {
+			v += " !important"
+		}
+		parts[i] = d.property + ": " + strings.ReplaceAll(v, `"`, "'")
+	}
+	style := ` style="` + strings.Join(parts, "; ") + `;"`
+
+	cleaned := styleAttrPattern.ReplaceAllString(attrs, "")
+	trailing := ""
+	if strings.HasSuffix(cleaned, " ") {
+		cleaned = strings.TrimRight(cleaned, " ")
+		trailing = " "
+	}
+	return tag[:attrsOffset] + cleaned + style + trailing + tag[attrsOffset+len(attrs):]
+}
+
+func checkSupport(d declaration) string {
+	for _, u := range unsupportedCSS {
+		if d.property == u.property && strings.Contains(d.value, u.value) {
+			if u.value != "" {
+				return fmt.Sprintf("%s: %s is not supported by Gmail/Outlook", d.property, u.value)
+			}
+			return fmt.Sprintf("%s is not supported by Gmail/Outlook", d.property)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInlineCSS(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want []string // substrings of the result
+		not  []string // substrings that must not appear
+	}{
+		{
+			name: "class and id rules",
+			html: `<style>.btn { color: red; } #main p { margin: 0 }</style><div id="main"><p class="btn">Hi</p></div>`,
+			want: []string{`<p class="btn" style="color: red; margin: 0;">`},
+		},
+		{
+			name: "inline style wins",
+			html: `<style>p { color: red; padding: 4px }</style><p style="color: blue">Hi</p>`,
+			want: []string{`<p style="padding: 4px; color: blue;">`},
+			not:  []string{`style="color: blue"`},
+		},
+		{
+			name: "data-style is not the style attribute",
+			html: `<style>p { color: red }</style><p data-style="keep: me" data-class="x" data-id="y">Hi</p>`,
+			want: []string{`<p data-style="keep: me" data-class="x" data-id="y" style="color: red;">`},
+		},
+		{
+			name: "semicolon inside url",
+			html: `<style>td { background: url(data:image/png;base64,iVBORw0KGgo=) no-repeat; color: red }</style><td>x</td>`,
+			want: []string{`style="background: url(data:image/png;base64,iVBORw0KGgo=) no-repeat; color: red;"`},
+		},
+		{
+			name: "semicolon inside quotes",
+			html: `<style>p { font-family: "A;B", sans-serif; color: red }</style><p>x</p>`,
+			want: []string{`style="font-family: 'A;B', sans-serif; color: red;"`},
+		},
+		{
+			name: "media declarations forced",
+			html: `<style>p { color: red } @media (max-width: 600px) { p { margin: 0; color: blue } a:hover { color: green; } }</style><p>x</p>`,
+			want: []string{
+				"p { margin: 0 !important; color: blue !important }",
+				"a:hover { color: green !important; }",
+			},
+			not: []string{"a:hover !important"},
+		},
+		{
+			name: "media url and existing important",
+			html: `<style>p { color: red } @media (max-width: 600px) { p { background: url(data:a;b) !important; color: blue } }</style><p>x</p>`,
+			want: []string{"p { background: url(data:a;b) !important; color: blue !important }"},
+		},
+		{
+			name: "head after style not styled",
+			html: `<html><head><style>* { color: red } a:hover { color: blue }</style><title>T</title><meta name="x"></head><body><p>x</p></body></html>`,
+			want: []string{"<title>T</title>", `<meta name="x">`, `<p style="color: red;">`},
+		},
+		{
+			name: "style in body",
+			html: `<html><head><title>T</title></head><body><style>p { color: red }</style><p>x</p></body></html>`,
+			want: []string{"<title>T</title>", `<p style="color: red;">`},
+		},
+		{
+			name: "pseudo selectors kept",
+			html: `<style>a { color: red } a:hover { color: blue }</style><a href="#">x</a>`,
+			want: []string{"a:hover {", `<a href="#" style="color: red;">`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := InlineCSS(tt.html)
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("missing %q in\n%s", w, got)
+				}
+			}
+			for _, n := range tt.not {
+				if strings.Contains(got, n) {
+					t.Errorf("unexpected %q in\n%s", n, got)
+				}
+			}
+		})
+	}
+}
+
+func TestInlineCSSWarnings(t *testing.T) {
+	_, warnings := InlineCSS(`<style>div { display: flex; box-shadow: 0 0 1px red }</style><div>x</div>`)
+	want := []string{
+		"box-shadow is not supported by Gmail/Outlook",
+		"display: flex is not supported by Gmail/Outlook",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings = %q, want %q", warnings, want)
+	}
+}
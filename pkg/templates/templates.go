@@ -0,0 +1,127 @@
+// Package templates renders the transactional emails. Markup lives in
+// embedded files: layout.html and partials/ are shared by every email and
+// each email directory holds a content.html arranging the partials. All
+// copy comes from the i18n catalogs through the template functions:
+//
+//	t "common.team"       string from the catalog
+//	tf "confirm.expiry" "hours" 48  string with {hours} filled in
+//	list "welcome.features" list from the catalog
+//	key "heading"         "<template>.heading", for shared partials
+//	lang                  the resolved locale tag
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"sync"
+
+	"goalhero-emailer/pkg/i18n"
+)
+
+//go:embed layout.html partials/*.html welcome/*.html confirm/*.html
+var files embed.FS
+
+// Names lists the templates that can be rendered.
+var Names = []string{"welcome", "confirm"}
+
+// Data is the per-recipient input to a template.
+type Data struct {
+	ContactEmail string
+	// UnsubscribeURL is the recipient's signed unsubscribe link; the
+	// footer omits the link when it is empty.
+	UnsubscribeURL string
+	// ConfirmURL and ConfirmHours fill the double opt-in email.
+	ConfirmURL   string
+	ConfirmHours int
+}
+
+// Rendered is a template executed for one locale.
+type Rendered struct {
+	Subject string
+	HTML    string
+	Text    string
+	// Warnings lists CSS that email clients will ignore, see InlineCSS.
+	Warnings []string
+}
+
+// registry holds one parsed, never executed, template set per name.
+// Render clones it so the locale-bound functions can be swapped in.
+var registry = mustLoad()
+
+// warned remembers which template/locale pairs already logged their CSS
+// warnings so they appear once per process rather than once per email.
+var warned sync.Map
+
+// placeholderFuncs lets the templates parse; Render replaces them.
+var placeholderFuncs = template.FuncMap{
+	"t":    func(string) string { return "" },
+	"tf":   func(string, ...any) string { return "" },
+	"list": func(string) []string { return nil },
+	"key":  func(string) string { return "" },
+	"lang": func() string { return "" },
+}
+
+func mustLoad() map[string]*template.Template {
+	base := template.Must(template.New("").Funcs(placeholderFuncs).ParseFS(files, "layout.html", "partials/*.html"))
+
+	registry := make(map[string]*template.Template)
+	for _, name := range Names {
+		registry[name] = template.Must(template.Must(base.Clone()).ParseFS(files, name+"/*.html"))
+	}
+	return registry
+}
+
+// Render executes template name for locale, which should come from
+// i18n.Match.
+func Render(name, locale string, data Data) (*Rendered, error) {
+	master, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q", name)
+	}
+
+	l := i18n.New(locale)
+	t, err := master.Clone()
+	if err != nil {
+		return nil, err
+	}
+	t.Funcs(template.FuncMap{
+		"t":    l.T,
+		"tf":   l.Format,
+		"list": l.List,
+		"key":  func(k string) string { return name + "." + k },
+		"lang": func() string { return l.Locale },
+	})
+
+	var body bytes.Buffer
+	if err := t.ExecuteTemplate(&body, "layout", data); err != nil {
+		return nil, fmt.Errorf("render %s/%s: %v", name, locale, err)
+	}
+
+	inlined, warnings := InlineCSS(body.String())
+	if _, seen := warned.LoadOrStore(name+"/"+locale, true); !seen {
+		for _, w := range warnings {
+			log.Printf("template %s/%s: %s", name, locale, w)
+		}
+	}
+
+	return &Rendered{
+		Subject:  l.T(name + ".subject"),
+		HTML:     inlined,
+		Text:     HTMLToText(inlined),
+		Warnings: warnings,
+	}, nil
+}
+
+// Sample returns placeholder data for rendering a template outside a real
+// send, e.g. for previews, with the configured contact address.
+func Sample(contactEmail string) Data {
+	return Data{
+		ContactEmail:   contactEmail,
+		UnsubscribeURL: "https://example.com/api/unsubscribe?token=sample",
+		ConfirmURL:     "https://example.com/api/beta-confirm?token=sample",
+		ConfirmHours:   48,
+	}
+}
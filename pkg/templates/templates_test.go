@@ -0,0 +1,72 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"goalhero-emailer/pkg/i18n"
+)
+
+func TestRender(t *testing.T) {
+	data := Sample("beta@example.com")
+	for _, name := range Names {
+		for _, locale := range i18n.Supported() {
+			t.Run(name+"/"+locale, func(t *testing.T) {
+				r, err := Render(name, locale, data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				l := i18n.New(locale)
+				if want := l.T(name + ".subject"); r.Subject != want || want == "" {
+					t.Errorf("Subject = %q, want %q", r.Subject, want)
+				}
+				if !strings.Contains(r.HTML, `lang="`+locale+`"`) {
+					t.Errorf("HTML is not marked as %s", locale)
+				}
+				for _, want := range []string{data.ContactEmail, data.UnsubscribeURL, l.T("common.unsubscribe")} {
+					if !strings.Contains(r.HTML, want) {
+						t.Errorf("HTML is missing %q", want)
+					}
+				}
+				if !strings.Contains(r.HTML, ` style="`) || !strings.Contains(r.HTML, "@media") {
+					t.Errorf("HTML was not inlined with its media queries kept")
+				}
+				if r.Text == "" || strings.Contains(r.Text, "<") {
+					t.Errorf("Text = %q", r.Text)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderConfirm(t *testing.T) {
+	data := Sample("beta@example.com")
+	r, err := Render("confirm", "es", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := i18n.New("es").Format("confirm.expiry", "hours", data.ConfirmHours)
+	for _, s := range []string{data.ConfirmURL, want} {
+		if !strings.Contains(r.Text, s) {
+			t.Errorf("Text is missing %q:\n%s", s, r.Text)
+		}
+	}
+}
+
+func TestRenderWithoutUnsubscribe(t *testing.T) {
+	data := Sample("beta@example.com")
+	data.UnsubscribeURL = ""
+	r, err := Render("welcome", "en", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(r.HTML, i18n.New("en").T("common.unsubscribe")) {
+		t.Errorf("footer links to unsubscribe without a URL")
+	}
+}
+
+func TestRenderUnknown(t *testing.T) {
+	if _, err := Render("nope", "en", Data{}); err == nil {
+		t.Errorf("Render of an unknown template succeeded")
+	}
+}
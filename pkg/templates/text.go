@@ -0,0 +1,135 @@
+package templates
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	tagPattern  = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*)>|<!--.*?-->|<!DOCTYPE[^>]*>`)
+	hrefPattern = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	spaceRun    = regexp.MustCompile(`[ \t\r\n]+`)
+)
+
+// blockTags start and end on their own line.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "ul": true, "ol": true, "table": true, "tr": true,
+	"header": true, "footer": true, "section": true, "blockquote": true,
+}
+
+// skipTags have content that is never shown as text.
+var skipTags = map[string]bool{"head": true, "style": true, "script": true, "title": true}
+
+// HTMLToText converts rendered email HTML into a readable plain-text
+// alternative. It is deterministic so the same template always yields the
+// same text: block elements become paragraphs, <li> becomes a "- " bullet,
+// <br> a line break, and links are kept as "label (url)" unless the label
+// already is the URL.
+func HTMLToText(src string) string {
+	// link is an open <a>; its label collects the text written since,
+	// independently of line, which is flushed on <br> and block tags.
+	type link struct {
+		href  string
+		label strings.Builder
+	}
+	var (
+		out   strings.Builder
+		line  strings.Builder
+		skip  string
+		links []*link
+	)
+
+	write := func(s string) {
+		line.WriteString(s)
+		for _, l := range links {
+			l.label.WriteString(s)
+		}
+	}
+	flushLine := func() {
+		text := strings.TrimSpace(spaceRun.ReplaceAllString(line.String(), " "))
+		line.Reset()
+		for _, l := range links {
+			l.label.WriteString(" ")
+		}
+		if text != "" {
+			out.WriteString(text)
+			out.WriteString("\n")
+		}
+	}
+	paragraph := func() {
+		flushLine()
+		if s := out.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	pos := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(src, -1) {
+		if skip == "" {
+			write(html.UnescapeString(src[pos:m[0]]))
+		}
+		pos = m[1]
+
+		if m[4] < 0 {
+			// comment or doctype
+			continue
+		}
+		closing := m[3] > m[2]
+		name := strings.ToLower(src[m[4]:m[5]])
+		attrs := src[m[6]:m[7]]
+
+		if skip != "" {
+			if closing && name == skip {
+				skip = ""
+			}
+			continue
+		}
+		if skipTags[name] && !closing {
+			skip = name
+			continue
+		}
+
+		switch {
+		case name == "br":
+			flushLine()
+		case name == "li" && !closing:
+			flushLine()
+			write("- ")
+		case name == "li":
+			flushLine()
+		case name == "a" && !closing:
+			links = append(links, &link{href: extractHref(attrs)})
+		case name == "a" && len(links) > 0:
+			l := links[len(links)-1]
+			links = links[:len(links)-1]
+			label := strings.TrimSpace(spaceRun.ReplaceAllString(l.label.String(), " "))
+			target := strings.TrimPrefix(l.href, "mailto:")
+			if l.href != "" && l.href != "#" && label != target {
+				write(" (" + target + ")")
+			}
+		case blockTags[name]:
+			paragraph()
+		}
+	}
+	if skip == "" {
+		write(html.UnescapeString(src[pos:]))
+	}
+	flushLine()
+
+	return strings.TrimSpace(out.String()) + "\n"
+}
+
+func extractHref(attrs string) string {
+	m := hrefPattern.FindStringSubmatch(attrs)
+	if m == nil {
+		return ""
+	}
+	for _, v := range m[1:] {
+		if v != "" {
+			return html.UnescapeString(v)
+		}
+	}
+	return ""
+}
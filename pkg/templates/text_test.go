@@ -0,0 +1,74 @@
+package templates
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs",
+			html: "<p>Hello   there,\n friend.</p><p>Second &amp; last.</p>",
+			want: "Hello there, friend.\n\nSecond & last.\n",
+		},
+		{
+			name: "link with label",
+			html: `<p>Read the <a href="https://goalhero.eu/faq">FAQ</a> first.</p>`,
+			want: "Read the FAQ (https://goalhero.eu/faq) first.\n",
+		},
+		{
+			name: "link labelled with its url",
+			html: `<p><a href="https://goalhero.eu">https://goalhero.eu</a></p>`,
+			want: "https://goalhero.eu\n",
+		},
+		{
+			name: "mailto link",
+			html: `<p>Write to <a href='mailto:support@goalhero.eu'>support@goalhero.eu</a>.</p>`,
+			want: "Write to support@goalhero.eu.\n",
+		},
+		{
+			name: "placeholder link",
+			html: `<p><a href="#">Nowhere</a></p>`,
+			want: "Nowhere\n",
+		},
+		{
+			name: "br inside link",
+			html: `<p>Hello there <a href="https://x">foo<br>bar</a></p>`,
+			want: "Hello there foo\nbar (https://x)\n",
+		},
+		{
+			name: "block inside link",
+			html: `<a href="https://x"><div><h1>Title</h1><p>Body</p></div></a>`,
+			want: "Title\n\nBody\n\n(https://x)\n",
+		},
+		{
+			name: "lists",
+			html: "<ul><li>One</li><li>Two <a href=\"https://x/2\">more</a></li></ul><p>After</p>",
+			want: "- One\n- Two more (https://x/2)\n\nAfter\n",
+		},
+		{
+			name: "nested blocks",
+			html: "<div><div><p>Deep</p></div><table><tr><td>Cell</td></tr></table></div>",
+			want: "Deep\n\nCell\n",
+		},
+		{
+			name: "skipped elements",
+			html: "<!DOCTYPE html><html><head><title>T</title><style>p { color: red }</style></head><body><!-- note --><p>Shown</p><script>alert(1)</script></body></html>",
+			want: "Shown\n",
+		},
+		{
+			name: "unclosed link",
+			html: `<p><a href="https://x">dangling</p>`,
+			want: "dangling\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToText(tt.html); got != tt.want {
+				t.Errorf("HTMLToText(%q)\n got %q\nwant %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Package unsubscribe mints and checks the per-recipient tokens behind
+// unsubscribe links and List-Unsubscribe headers.
+package unsubscribe
+
+import (
+	"net/url"
+
+	"goalhero-emailer/pkg/signing"
+)
+
+const purpose = "unsubscribe"
+
+// Path is where the unsubscribe endpoint is served.
+const Path = "/api/unsubscribe"
+
+// Token returns a signed token identifying email. Tokens don't expire:
+// an unsubscribe link must keep working for as long as the email exists.
+func Token(s *signing.Signer, email string) string {
+	return s.Sign(purpose, []byte(email))
+}
+
+// Verify returns the address a token was minted for.
+func Verify(s *signing.Signer, token string) (string, error) {
+	email, err := s.Verify(purpose, token)
+	if err != nil {
+		return "", err
+	}
+	return string(email), nil
+}
+
+// URL builds the unsubscribe link for email under baseURL.
+func URL(s *signing.Signer, baseURL, email string) string {
+	return baseURL + Path + "?token=" + url.QueryEscape(Token(s, email))
+}
+
+// Headers returns the List-Unsubscribe headers for email, advertising
+// RFC 8058 one-click unsubscribe as required by Gmail and Yahoo for bulk
+// senders.
+func Headers(s *signing.Signer, baseURL, email string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + URL(s, baseURL, email) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
@@ -0,0 +1,51 @@
+package unsubscribe
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"goalhero-emailer/pkg/confirm"
+	"goalhero-emailer/pkg/signing"
+)
+
+func TestToken(t *testing.T) {
+	s := signing.New("0123456789abcdef0123456789abcdef")
+	email, err := Verify(s, Token(s, "jane@example.com"))
+	if err != nil || email != "jane@example.com" {
+		t.Fatalf("Verify = %q, %v", email, err)
+	}
+}
+
+func TestCrossPurpose(t *testing.T) {
+	s := signing.New("0123456789abcdef0123456789abcdef")
+	expires := time.Now().Add(time.Hour)
+
+	// A confirmation link must not unsubscribe anyone, nor an unsubscribe
+	// link confirm a signup.
+	if email, err := Verify(s, confirm.Token(s, "jane@example.com", expires)); !errors.Is(err, signing.ErrInvalidToken) {
+		t.Errorf("confirm token unsubscribed %q, %v", email, err)
+	}
+	if key, err := confirm.Verify(s, Token(s, "jane@example.com"), time.Now()); !errors.Is(err, signing.ErrInvalidToken) {
+		t.Errorf("unsubscribe token confirmed %q, %v", key, err)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	s := signing.New("0123456789abcdef0123456789abcdef")
+	h := Headers(s, "https://goalhero.eu", "jane@example.com")
+	if h["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", h["List-Unsubscribe-Post"])
+	}
+
+	link := strings.TrimSuffix(strings.TrimPrefix(h["List-Unsubscribe"], "<"), ">")
+	u, err := url.Parse(link)
+	if err != nil || u.Host != "goalhero.eu" || u.Path != Path {
+		t.Fatalf("List-Unsubscribe = %q", h["List-Unsubscribe"])
+	}
+	if email, err := Verify(s, u.Query().Get("token")); err != nil || email != "jane@example.com" {
+		t.Errorf("link token: %q, %v", email, err)
+	}
+}